@@ -0,0 +1,49 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// agentClient returns an HTTP client for the agent that started the
+// current terminal. It dials MLCREMOTE_API_SOCKET if the agent runs in
+// socket mode and pins MLCREMOTE_API_FINGERPRINT if it serves TLS.
+func agentClient(timeout time.Duration) *http.Client {
+	transport := &http.Transport{}
+	if sock := os.Getenv("MLCREMOTE_API_SOCKET"); sock != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		}
+	}
+	if fp := strings.ToLower(os.Getenv("MLCREMOTE_API_FINGERPRINT")); fp != "" {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 {
+					return errors.New("agent sent no certificate")
+				}
+				sum := sha256.Sum256(rawCerts[0])
+				if got := hex.EncodeToString(sum[:]); got != fp {
+					return fmt.Errorf("agent certificate fingerprint %s does not match %s", got, fp)
+				}
+				return nil
+			},
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
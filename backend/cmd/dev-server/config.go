@@ -0,0 +1,150 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
+	"lightdev/internal/config"
+	"lightdev/internal/handlers"
+	"lightdev/internal/logging"
+	"lightdev/internal/util"
+)
+
+// loadConfig loads the INI configuration from path, or from the standard
+// locations if path is empty.
+func loadConfig(path string) (*config.Config, error) {
+	if path != "" {
+		return config.LoadFile(path)
+	}
+	return config.Load()
+}
+
+// runConfigCommand implements "dev-server config check [-config path]".
+// It prints the effective file configuration and exits non-zero if the
+// file contains unknown keys or invalid values.
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "Usage: dev-server config check [-config path]")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("config check", flag.ExitOnError)
+	path := fs.String("config", "", "path to config.ini (default: standard locations)")
+	_ = fs.Parse(args[1:])
+
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	source := cfg.Source
+	if source == "" {
+		source = "(none found, using defaults)"
+	}
+	password := ""
+	if cfg.Password != "" {
+		password = "(set)"
+	}
+	metricsToken := ""
+	if cfg.MetricsToken != "" {
+		metricsToken = "(set)"
+	}
+	trashDir := cfg.TrashDir
+	if trashDir == "" {
+		trashDir = "(default ~/.trash)"
+	}
+	root := cfg.Root
+	if root == "" {
+		root = "(default $HOME)"
+	}
+	sessions := auth.NewSessionStore(cfg.SessionIdle, cfg.SessionMaxLifetime)
+	logins := auth.NewLoginLimiter(cfg.LoginMaxFailures, cfg.LoginLockout)
+	auditLog := newAuditLog(cfg)
+	confinement, _ := util.ParseConfinement(cfg.Confinement)
+	logLevel, _ := logging.ParseLevel(cfg.LogLevel)
+	maxUploadSize := int64(cfg.MaxUploadSizeMB) << 20
+	if maxUploadSize <= 0 {
+		maxUploadSize = handlers.DefaultMaxUploadSize
+	}
+	logDir := cfg.LogDir
+	if logDir == "" {
+		logDir = logging.DefaultDir()
+	}
+	logMaxSize, logMaxBackups, logMaxAge := int64(cfg.LogMaxSizeMB)<<20, cfg.LogMaxBackups, cfg.LogMaxAge
+	if logMaxSize <= 0 {
+		logMaxSize = logging.DefaultMaxSize
+	}
+	if logMaxBackups <= 0 {
+		logMaxBackups = logging.DefaultMaxBackups
+	}
+	if logMaxAge <= 0 {
+		logMaxAge = logging.DefaultMaxAge
+	}
+
+	fmt.Printf("Config file:  %s\n", source)
+	fmt.Printf("port          = %d\n", cfg.Port)
+	fmt.Printf("host          = %s\n", cfg.Host)
+	fmt.Printf("root          = %s\n", root)
+	fmt.Printf("static_dir    = %s\n", cfg.StaticDir)
+	fmt.Printf("openapi       = %s\n", cfg.OpenAPIPath)
+	fmt.Printf("tls           = %t\n", cfg.TLS)
+	fmt.Printf("tls_cert      = %s\n", cfg.TLSCert)
+	fmt.Printf("tls_key       = %s\n", cfg.TLSKey)
+	fmt.Printf("socket        = %t\n", cfg.Socket)
+	fmt.Printf("socket_path   = %s\n", cfg.SocketPath)
+	fmt.Printf("allowed_origins = %s\n", strings.Join(cfg.AllowedOrigins, ", "))
+	fmt.Printf("idle_timeout  = %s\n", cfg.IdleTimeout)
+	fmt.Printf("max_lifetime  = %s\n", cfg.MaxLifetime)
+	fmt.Printf("read_only     = %t\n", cfg.ReadOnly)
+	fmt.Printf("no_auth       = %t\n", cfg.NoAuth)
+	fmt.Printf("password      = %s\n", password)
+	fmt.Printf("metrics_token = %s\n", metricsToken)
+	fmt.Printf("session_idle  = %s\n", sessions.Idle)
+	fmt.Printf("session_max_lifetime = %s\n", sessions.MaxLifetime)
+	fmt.Printf("login_max_failures = %d\n", logins.MaxFailures)
+	fmt.Printf("login_lockout = %s\n", logins.Lockout)
+	fmt.Printf("allow_delete  = %t\n", cfg.AllowDelete)
+	fmt.Printf("trash_dir     = %s\n", trashDir)
+	fmt.Printf("backup        = %t\n", cfg.Backup)
+	fmt.Printf("confinement   = %s\n", confinement)
+	fmt.Printf("max_upload_size_mb = %d\n", maxUploadSize>>20)
+	for _, rule := range cfg.AccessRules {
+		fmt.Printf("rule          = %s\n", rule)
+	}
+	fmt.Printf("log_level     = %s\n", logLevel)
+	fmt.Printf("log_dir       = %s\n", logDir)
+	fmt.Printf("log_max_size_mb = %d\n", logMaxSize>>20)
+	fmt.Printf("log_max_backups = %d\n", logMaxBackups)
+	fmt.Printf("log_max_age   = %s\n", logMaxAge)
+	fmt.Printf("audit_file    = %s\n", auditLog.Path)
+	fmt.Printf("audit_max_size_mb = %d\n", auditLog.MaxSize>>20)
+	fmt.Printf("audit_max_backups = %d\n", auditLog.MaxBackups)
+
+	if len(cfg.Issues) == 0 {
+		fmt.Println("OK: no problems found")
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "%d problem(s) found:\n", len(cfg.Issues))
+	for _, issue := range cfg.Issues {
+		fmt.Fprintf(os.Stderr, "  %s\n", issue)
+	}
+	os.Exit(1)
+}
+
+// newAuditLog returns the audit log configured by cfg.
+func newAuditLog(cfg *config.Config) *audit.Log {
+	path := cfg.AuditFile
+	if path == "" {
+		path = audit.DefaultPath()
+	}
+	return audit.New(path, int64(cfg.AuditMaxSizeMB)<<20, cfg.AuditMaxBackups)
+}
@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -22,8 +23,14 @@ import (
 	"syscall"
 	"time"
 
+	"lightdev/internal/access"
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
+	"lightdev/internal/config"
+	"lightdev/internal/logging"
 	"lightdev/internal/server"
 	"lightdev/internal/stats"
+	"lightdev/internal/util"
 )
 
 func generateToken() string {
@@ -45,9 +52,28 @@ func main() {
 	showVersion := flag.Bool("version", false, "print version and exit")
 
 	tokenFlag := flag.String("token", "", "auth token (if empty and no-auth is false, one will be generated)")
+	configPath := flag.String("config", "", "path to config.ini (default: ~/.mlcremote/config.ini, then /etc/mlcremote/config.ini)")
+	allowDelete := flag.Bool("allow-delete", true, "allow deleting files (moves them to the trash)")
+	backup := flag.Bool("backup", false, "keep the previous content of saved files as <name>.bak")
+	readOnly := flag.Bool("read-only", false, "refuse file changes and new terminals (an admin can lift this temporarily via /api/read-only)")
+	confinementFlag := flag.String("confinement", "", "paths the file API may access: jail (root only), home (root and home directory) or unrestricted (default unrestricted)")
+	trashDirFlag := flag.String("trash-dir", "", "directory where deleted files are moved (default ~/.trash)")
+	socketFlag := flag.Bool("socket", false, "listen on a Unix socket in ~/.mlcremote instead of a TCP port")
+	socketPath := flag.String("socket-path", "", "Unix socket path to listen on (implies -socket)")
+	tlsFlag := flag.Bool("tls", false, "serve HTTPS (self-signed certificate in ~/.mlcremote/tls unless -tls-cert/-tls-key are given)")
+	tlsCert := flag.String("tls-cert", "", "path to PEM certificate for HTTPS (implies -tls)")
+	tlsKey := flag.String("tls-key", "", "path to PEM private key for HTTPS (implies -tls)")
+	logLevel := flag.String("log-level", "", "minimum log level after startup: debug, info, warn or error (default info)")
+	metricsToken := flag.String("metrics-token", "", "token that only grants access to /metrics (for Prometheus scrapers)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "shut down after this long without requests, terminals or event subscribers (0 = never)")
+	maxLifetime := flag.Duration("max-lifetime", 0, "shut down this long after starting (0 = never)")
+	allowedOrigins := flag.String("allowed-origins", "", "comma-separated browser origins allowed to call the API besides the server itself and the desktop app")
 	// Handle simplified CLI commands before flags
 	if len(os.Args) > 1 {
-		if os.Args[1] == "cmd" {
+		if os.Args[1] == "config" {
+			// usage: dev-server config check [-config path]
+			runConfigCommand(os.Args[2:])
+		} else if os.Args[1] == "cmd" {
 			// usage: dev-server cmd <command> [json_args | key=value ...]
 			if len(os.Args) < 3 {
 				fmt.Fprintln(os.Stderr, "Usage: dev-server cmd <command> [args...]")
@@ -112,7 +138,7 @@ func main() {
 			req.Header.Set("Content-Type", "application/json")
 			req.Header.Set("X-Auth-Token", token)
 
-			client := &http.Client{Timeout: 2 * time.Second}
+			client := agentClient(2 * time.Second)
 			resp, err := client.Do(req)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Request failed: %v\n", err)
@@ -179,7 +205,7 @@ func main() {
 			req.Header.Set("Content-Type", "application/json")
 			req.Header.Set("X-Auth-Token", token)
 
-			client := &http.Client{Timeout: 2 * time.Second}
+			client := agentClient(2 * time.Second)
 			resp, err := client.Do(req)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Request failed: %v\n", err)
@@ -233,7 +259,7 @@ func main() {
 
 	if len(flag.Args()) > 0 {
 		fmt.Fprintf(os.Stderr, "Unknown command or argument: %s\n", flag.Args()[0])
-		fmt.Fprintf(os.Stderr, "Usage:\n  dev-server [flags]\n  dev-server cmd <command> [args]\n  dev-server cwd [path]\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n  dev-server [flags]\n  dev-server cmd <command> [args]\n  dev-server cwd [path]\n  dev-server config check [-config path]\n")
 		os.Exit(1)
 	}
 
@@ -248,10 +274,95 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Ensure logs go to stdout so the deployment script can capture them in current.log
+	// Ensure logs go to stdout so the deployment script can capture them in
+	// current.log. setupLogging adds the rotating log file later.
 	log.SetOutput(os.Stdout)
 
 	log.Printf("MLCRemote v%s starting", version)
+
+	// Load config.ini and merge it with the flags. Flags given explicitly
+	// on the command line always win over the file.
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Source != "" {
+		log.Printf("Loaded config from %s", cfg.Source)
+	}
+	for _, issue := range cfg.Issues {
+		log.Printf("[WARNING] config %s: %s", cfg.Source, issue)
+	}
+	setFlags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if !setFlags["log-level"] {
+		*logLevel = cfg.LogLevel
+	}
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	logger, logFile := setupLogging(cfg)
+	if !setFlags["port"] {
+		*port = cfg.Port
+	}
+	if !setFlags["host"] {
+		*host = cfg.Host
+	}
+	if !setFlags["root"] {
+		*root = cfg.Root
+	}
+	if !setFlags["static-dir"] {
+		*staticDir = cfg.StaticDir
+	}
+	if !setFlags["openapi"] {
+		*openapi = cfg.OpenAPIPath
+	}
+	if !setFlags["no-auth"] {
+		*noAuth = cfg.NoAuth
+	}
+	if !setFlags["allow-delete"] {
+		*allowDelete = cfg.AllowDelete
+	}
+	if !setFlags["backup"] {
+		*backup = cfg.Backup
+	}
+	if !setFlags["read-only"] {
+		*readOnly = cfg.ReadOnly
+	}
+	if !setFlags["confinement"] {
+		*confinementFlag = cfg.Confinement
+	}
+	if !setFlags["trash-dir"] {
+		*trashDirFlag = cfg.TrashDir
+	}
+	if !setFlags["tls"] {
+		*tlsFlag = cfg.TLS
+	}
+	if !setFlags["tls-cert"] {
+		*tlsCert = cfg.TLSCert
+	}
+	if !setFlags["tls-key"] {
+		*tlsKey = cfg.TLSKey
+	}
+	if !setFlags["socket"] {
+		*socketFlag = cfg.Socket
+	}
+	if !setFlags["socket-path"] {
+		*socketPath = cfg.SocketPath
+	}
+	if !setFlags["metrics-token"] {
+		*metricsToken = cfg.MetricsToken
+	}
+	if !setFlags["idle-timeout"] {
+		*idleTimeout = cfg.IdleTimeout
+	}
+	if !setFlags["max-lifetime"] {
+		*maxLifetime = cfg.MaxLifetime
+	}
+	if !setFlags["allowed-origins"] {
+		*allowedOrigins = strings.Join(cfg.AllowedOrigins, ",")
+	}
+
 	if *root == "" {
 		*root = os.Getenv("HOME")
 	}
@@ -267,23 +378,75 @@ func main() {
 
 	token := *tokenFlag
 	if token == "" && !*noAuth {
-		token = generateToken()
+		// an upgraded agent keeps the generated token of the one it replaces
+		token = os.Getenv(server.EnvInheritToken)
+		if token == "" {
+			token = generateToken()
+		}
 	}
+	os.Unsetenv(server.EnvInheritToken)
 
-	// Dev server doesn't use config file password for now
-	// AllowDelete = true for dev server
-	trashDir := filepath.Join(os.Getenv("HOME"), ".trash")
+	trashDir := *trashDirFlag
+	if trashDir == "" {
+		trashDir = filepath.Join(os.Getenv("HOME"), ".trash")
+	}
+	if !*allowDelete {
+		log.Printf("Security: file deletion DISABLED")
+	}
+	if *readOnly {
+		log.Printf("Security: read-only mode, file changes and terminals DISABLED")
+	}
+	confinement, err := util.ParseConfinement(*confinementFlag)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	util.SetConfinement(confinement)
+	util.SetBackups(*backup)
+	if *backup {
+		log.Printf("[INFO] saved files are backed up as <name>.bak")
+	}
+	log.Printf("Security: path confinement %s (root %s)", confinement, *root)
+	access.SetDefault(access.New(*root, cfg.AccessRules))
+	for _, rule := range cfg.AccessRules {
+		log.Printf("Security: access rule %s", rule)
+	}
 
-	s := server.New(*host, *root, *staticDir, *openapi, token, "", true, trashDir, *debugTerminal)
+	s := server.New(*host, *root, *staticDir, *openapi, token, cfg.Password, *allowDelete, trashDir, *debugTerminal)
 
 	if fallback {
 		s.RootFallback = true
 	}
+	s.Sessions = auth.NewSessionStore(cfg.SessionIdle, cfg.SessionMaxLifetime)
+	s.Logins = auth.NewLoginLimiter(cfg.LoginMaxFailures, cfg.LoginLockout)
+	audit.SetDefault(newAuditLog(cfg))
+	s.TLS = *tlsFlag || *tlsCert != "" || *tlsKey != ""
+	s.TLSCert = *tlsCert
+	s.TLSKey = *tlsKey
+	if *socketPath != "" {
+		s.SocketPath = *socketPath
+	} else if *socketFlag {
+		s.SocketPath = server.DefaultSocketPath()
+	}
+	s.AllowedOrigins = config.SplitList(*allowedOrigins)
+	s.MetricsToken = *metricsToken
+	s.LogFile = logFile
+	s.IdleTimeout = *idleTimeout
+	s.MaxLifetime = *maxLifetime
+	s.ReadOnly = *readOnly
+	s.MaxUploadSize = int64(cfg.MaxUploadSizeMB) << 20
+	inherited, err := server.InheritedListener()
+	if err != nil {
+		log.Fatalf("inherited listener: %v", err)
+	}
+	s.Listener = inherited
 
 	s.Routes()
 
-	if *host != "127.0.0.1" && *host != "localhost" {
+	if s.SocketPath == "" && *host != "127.0.0.1" && *host != "localhost" {
 		log.Printf("[WARNING] Server is listening on EXTERNAL interface (%s). Only do this in a container!", *host)
+		if !s.TLS {
+			log.Printf("[WARNING] TLS is disabled, tokens are sent in clear text. Consider -tls.")
+		}
 	}
 	// Start server (returns actual port)
 	actualPort, err := s.Start(*port)
@@ -297,34 +460,89 @@ func main() {
 	}
 	// Use actualPort for display
 	displayAddr := fmt.Sprintf("%s:%d", displayHost, actualPort)
+	startedOn := fmt.Sprintf("%s://localhost:%d", s.Scheme(), actualPort)
+	if s.SocketPath != "" {
+		// socket mode: the URL is only meaningful through a forward
+		displayAddr = "localhost"
+		startedOn = "unix://" + s.SocketPath
+		log.Printf("Security: listening on Unix socket %s (mode 0600)", s.SocketPath)
+	}
 
 	if token != "" {
 		log.Printf("Security: Authentication ENABLED")
-		log.Printf("Access URL: http://%s/?token=%s", displayAddr, token)
+		log.Printf("Access URL: %s://%s/?token=%s", s.Scheme(), displayAddr, token)
 	} else {
 		log.Printf("Security: Authentication DISABLED")
-		log.Printf("Access URL: http://%s/", displayAddr)
+		log.Printf("Access URL: %s://%s/", s.Scheme(), displayAddr)
 	}
 
 	// log binary size
 	if exe, err := os.Executable(); err == nil {
 		if fi, err := os.Stat(exe); err == nil {
-			log.Printf("Server started on http://localhost:%d, binary=%s size=%d bytes", actualPort, filepath.Base(exe), fi.Size())
+			log.Printf("Server started on %s, binary=%s size=%d bytes", startedOn, filepath.Base(exe), fi.Size())
 		} else {
-			log.Printf("Server started on http://localhost:%d", actualPort)
+			log.Printf("Server started on %s", startedOn)
 		}
 	} else {
-		log.Printf("Server started on http://localhost:%d", actualPort)
+		log.Printf("Server started on %s", startedOn)
 	}
+	// Startup messages are always logged, the desktop app parses them.
+	if level != logging.LevelInfo {
+		log.Printf("[INFO] log level %s", level)
+	}
+	logger.SetLevel(level)
+	server.NotifyReady()
+
 	// wait for interrupt (Ctrl-C) or termination signal
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
-	log.Println("shutdown signal received, shutting down server...")
+	if s.IdleTimeout > 0 {
+		log.Printf("[INFO] idle timeout %s", s.IdleTimeout)
+	}
+	if s.MaxLifetime > 0 {
+		log.Printf("[INFO] maximum lifetime %s", s.MaxLifetime)
+	}
+	select {
+	case <-sig:
+		log.Println("shutdown signal received, shutting down server...")
+	case reason := <-s.Expired():
+		log.Printf("[INFO] shutting down: %s", reason)
+	case <-s.Retired():
+		log.Printf("[INFO] upgrade: replaced by the new agent, waiting for open terminals to close")
+		s.DrainTerminals(sig)
+		log.Printf("[INFO] upgrade: old agent exiting")
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := s.Shutdown(ctx); err != nil {
 		log.Printf("shutdown error: %v", err)
 	}
+	s.RemoveRunFiles()
+	log.Printf("[INFO] agent exited")
+}
+
+// setupLogging sends the standard logger to stdout and a rotating file in
+// the configured log directory, and makes it available to /api/logs. It
+// returns the logger and the path of the log file ("" if it could not be
+// opened).
+func setupLogging(cfg *config.Config) (*logging.Logger, string) {
+	dir := cfg.LogDir
+	if dir == "" {
+		dir = logging.DefaultDir()
+	}
+	outputs := []io.Writer{os.Stdout}
+	logFile := ""
+	rf, err := logging.OpenRotatingFile(dir, int64(cfg.LogMaxSizeMB)<<20, cfg.LogMaxBackups, cfg.LogMaxAge)
+	if err != nil {
+		log.Printf("[WARNING] cannot open log file in %s: %v", dir, err)
+	} else {
+		outputs = append(outputs, rf)
+		logFile = rf.Path()
+	}
+	logger := logging.New(logging.LevelInfo, outputs...)
+	log.SetOutput(logger)
+	logging.SetDefault(logger)
+	return logger, logFile
 }
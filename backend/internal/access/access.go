@@ -0,0 +1,238 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package access implements per-path rules from the agent configuration.
+// A rule pairs a glob pattern with a mode:
+//
+//	/etc/**        read-only   readable, but not written, renamed or deleted
+//	**/.ssh/**     deny        listed, but neither read nor written
+//	**/*.pem       hidden      not listed and treated as missing
+//
+// "**" matches any number of path elements, "*" and "?" match within one.
+// Patterns starting with "/" (or a drive letter) are absolute, patterns
+// starting with "**" match anywhere, others are relative to the root.
+// When several rules match a path the most restrictive one applies.
+// Where the filesystem is usually case-insensitive (macOS, Windows) rules
+// match regardless of case, so "/ETC/passwd" cannot get around "/etc/**".
+package access
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// foldCase is set where file names are compared case-insensitively by
+// default.
+var foldCase = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// Mode is what a rule allows. Modes are ordered by restrictiveness.
+type Mode int
+
+const (
+	// None means no rule applies.
+	None Mode = iota
+	ReadOnly
+	Deny
+	Hidden
+)
+
+var modeNames = []string{"", "read-only", "deny", "hidden"}
+
+func (m Mode) String() string {
+	if m < None || m > Hidden {
+		return fmt.Sprintf("mode(%d)", int(m))
+	}
+	return modeNames[m]
+}
+
+// ParseMode parses "read-only", "deny" or "hidden".
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "read-only", "readonly", "ro":
+		return ReadOnly, nil
+	case "deny", "denied":
+		return Deny, nil
+	case "hidden", "hide":
+		return Hidden, nil
+	}
+	return None, fmt.Errorf("unknown access mode %q (use read-only, deny or hidden)", s)
+}
+
+// Rule is a pattern and the mode applied to matching paths.
+type Rule struct {
+	Pattern string
+	Mode    Mode
+}
+
+func (r Rule) String() string {
+	return r.Pattern + " " + r.Mode.String()
+}
+
+// ParseRule parses "<pattern> <mode>", e.g. "**/.ssh/** deny".
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexAny(s, " \t")
+	if i < 0 {
+		return Rule{}, fmt.Errorf("invalid rule %q (use <pattern> <mode>)", s)
+	}
+	pattern := strings.TrimSpace(s[:i])
+	mode, err := ParseMode(s[i+1:])
+	if err != nil {
+		return Rule{}, err
+	}
+	if pattern == "" {
+		return Rule{}, fmt.Errorf("invalid rule %q: empty pattern", s)
+	}
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return Rule{}, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	return Rule{Pattern: pattern, Mode: mode}, nil
+}
+
+// Policy matches paths against a list of rules.
+type Policy struct {
+	rules    []Rule
+	compiled [][]string
+	// fold makes matching case-insensitive
+	fold bool
+}
+
+// New returns a policy for rules. Relative patterns are anchored at root.
+func New(root string, rules []Rule) *Policy {
+	p := &Policy{rules: rules, fold: foldCase}
+	// handlers match resolved paths
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	rootSlash := strings.TrimSuffix(filepath.ToSlash(root), "/")
+	for _, r := range rules {
+		pat := r.Pattern
+		if !isAbs(pat) && !strings.HasPrefix(pat, "**") {
+			pat = rootSlash + "/" + pat
+		}
+		if p.fold {
+			pat = strings.ToLower(pat)
+		}
+		p.compiled = append(p.compiled, split(pat))
+	}
+	return p
+}
+
+// Lookup returns the most restrictive rule matching the absolute path
+// name, or nil if none does.
+func (p *Policy) Lookup(name string) *Rule {
+	if p == nil || len(p.rules) == 0 {
+		return nil
+	}
+	name = filepath.ToSlash(name)
+	if p.fold {
+		name = strings.ToLower(name)
+	}
+	elems := split(name)
+	var best *Rule
+	for i, pat := range p.compiled {
+		if match(pat, elems) && (best == nil || p.rules[i].Mode > best.Mode) {
+			best = &p.rules[i]
+		}
+	}
+	return best
+}
+
+// Mode returns the mode of the rule matching name, None if there is none.
+func (p *Policy) Mode(name string) Mode {
+	if r := p.Lookup(name); r != nil {
+		return r.Mode
+	}
+	return None
+}
+
+// errFound stops the walk in Within.
+var errFound = errors.New("found")
+
+// Within returns a rule of at least mode min matching dir or anything
+// below it, or nil. Operations on a whole directory (delete, rename,
+// copy, download) use it so they cannot bypass rules on its contents.
+func (p *Policy) Within(dir string, min Mode) *Rule {
+	if p == nil || len(p.rules) == 0 {
+		return nil
+	}
+	var found *Rule
+	filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if r := p.Lookup(name); r != nil && r.Mode >= min {
+			found = r
+			return errFound
+		}
+		return nil
+	})
+	return found
+}
+
+func isAbs(pattern string) bool {
+	return strings.HasPrefix(pattern, "/") || filepath.IsAbs(filepath.FromSlash(pattern))
+}
+
+// split returns the elements of a slash-separated path.
+func split(name string) []string {
+	var out []string
+	for _, e := range strings.Split(name, "/") {
+		if e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// match reports whether the path elements match the pattern elements.
+func match(pat, elems []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			// "**" absorbs zero or more elements
+			for i := 0; i <= len(elems); i++ {
+				if match(pat[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], elems[0]); !ok {
+			return false
+		}
+		pat, elems = pat[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+var (
+	stdMu sync.RWMutex
+	std   *Policy
+)
+
+// SetDefault makes p the policy used by the file handlers.
+func SetDefault(p *Policy) {
+	stdMu.Lock()
+	std = p
+	stdMu.Unlock()
+}
+
+// Default returns the policy set with SetDefault, or nil.
+func Default() *Policy {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
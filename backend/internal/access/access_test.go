@@ -0,0 +1,96 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package access
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"/etc/passwd", "/etc/passwd", true},
+		{"/etc/passwd", "/etc/passwd.bak", false},
+		{"/etc/*", "/etc/hosts", true},
+		{"/etc/*", "/etc/ssl/certs", false},
+		{"/etc/**", "/etc", true},
+		{"/etc/**", "/etc/ssl/certs/ca.pem", true},
+		{"/etc/**", "/etcetera/x", false},
+		{"**/.ssh/**", "/home/u/.ssh", true},
+		{"**/.ssh/**", "/home/u/.ssh/id_rsa", true},
+		{"**/.ssh/**", "/home/u/ssh/id_rsa", false},
+		{"**/*.pem", "/key.pem", true},
+		{"**/*.pem", "/a/b/c/key.pem", true},
+		{"**/*.pem", "/a/key.pem.txt", false},
+		{"/a/**/z", "/a/z", true},
+		{"/a/**/z", "/a/b/c/z", true},
+		{"/a/**/z", "/a/b/c/z/y", false},
+		{"/a/?.txt", "/a/b.txt", true},
+		{"/a/?.txt", "/a/bc.txt", false},
+		{"/a/[bc].txt", "/a/c.txt", true},
+		{"/a/*", "/a", false},
+	}
+	for _, tt := range tests {
+		if got := match(split(tt.pattern), split(tt.name)); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	p := New("/srv/root", []Rule{
+		{"secrets/**", Deny},
+		{"**/*.pem", Hidden},
+		{"/etc/**", ReadOnly},
+		{"secrets/public/**", ReadOnly},
+	})
+	tests := []struct {
+		name string
+		want Mode
+	}{
+		{"/srv/root/readme.md", None},
+		{"/srv/root/secrets", Deny},
+		{"/srv/root/secrets/db.txt", Deny},
+		{"/srv/root/secrets/public/a.txt", Deny},
+		{"/srv/root/secrets/key.pem", Hidden},
+		{"/srv/other/secrets/db.txt", None},
+		{"/etc/hosts", ReadOnly},
+		{"/etc/ssl/server.pem", Hidden},
+	}
+	for _, tt := range tests {
+		if got := p.Mode(tt.name); got != tt.want {
+			t.Errorf("Mode(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	var nilPolicy *Policy
+	if got := nilPolicy.Mode("/etc/hosts"); got != None {
+		t.Errorf("nil policy Mode = %v, want none", got)
+	}
+}
+
+func TestLookupFoldCase(t *testing.T) {
+	defer func(f bool) { foldCase = f }(foldCase)
+	rules := []Rule{{"/etc/**", ReadOnly}, {"**/.ssh/**", Deny}}
+
+	foldCase = true
+	p := New("/srv/root", rules)
+	for name, want := range map[string]Mode{
+		"/ETC/passwd":         ReadOnly,
+		"/Etc/Hosts":          ReadOnly,
+		"/home/u/.SSH/id_rsa": Deny,
+		"/home/u/ssh/id_rsa":  None,
+	} {
+		if got := p.Mode(name); got != want {
+			t.Errorf("folding: Mode(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	foldCase = false
+	p = New("/srv/root", rules)
+	if got := p.Mode("/ETC/passwd"); got != None {
+		t.Errorf("case-sensitive: Mode(/ETC/passwd) = %v, want none", got)
+	}
+}
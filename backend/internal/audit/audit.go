@@ -0,0 +1,285 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package audit records who changed what through the API. Events are
+// appended as JSON lines to a size-rotated log file.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log. Filters match an action exactly or
+// by its dotted prefix, so "file" selects every file.* action.
+const (
+	ActionLogin          = "auth.login"
+	ActionLockout        = "auth.lockout"
+	ActionLockoutClear   = "auth.lockout.clear"
+	ActionLogout         = "auth.logout"
+	ActionSessionRevoke  = "auth.session.revoke"
+	ActionPasswordChange = "auth.password"
+	ActionTokenCreate    = "token.create"
+	ActionTokenRevoke    = "token.revoke"
+	ActionFileWrite      = "file.write"
+	ActionFileUpload     = "file.upload"
+	ActionFileDelete     = "file.delete"
+	ActionFileRename     = "file.rename"
+	ActionFileCopy       = "file.copy"
+	ActionFileReplace    = "file.replace"
+	ActionTrashRestore   = "trash.restore"
+	ActionTrashEmpty     = "trash.empty"
+	ActionSettingsChange = "settings.change"
+	ActionTerminalOpen   = "terminal.open"
+	ActionTerminalClose  = "terminal.close"
+	ActionAgentUpgrade   = "agent.upgrade"
+	ActionPathDenied     = "path.denied"
+	ActionReadOnlyUnlock = "readonly.unlock"
+	ActionReadOnlyLock   = "readonly.lock"
+)
+
+// Results of an audited operation.
+const (
+	ResultOK     = "ok"
+	ResultDenied = "denied"
+	ResultError  = "error"
+)
+
+// Event is a single audit record.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Actor is the id of the credential used (token id, session id,
+	// "master" or "anonymous"); ActorName is its human readable name.
+	Actor      string `json:"actor,omitempty"`
+	ActorName  string `json:"actorName,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	Action     string `json:"action"`
+	Path       string `json:"path,omitempty"`
+	// Target is the destination of renames, copies and restores.
+	Target string `json:"target,omitempty"`
+	Result string `json:"result"`
+	// Status is the HTTP status the request was answered with.
+	Status int    `json:"status,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Defaults for the rotation of the audit log.
+const (
+	DefaultMaxSize    = 10 << 20
+	DefaultMaxBackups = 5
+)
+
+// Log appends events to a JSONL file. When the file grows beyond MaxSize
+// it is renamed to Path.1 (shifting older backups up to MaxBackups) and a
+// new file is started.
+type Log struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu sync.Mutex
+}
+
+// New returns a Log writing to path. Non-positive limits use the defaults.
+func New(path string, maxSize int64, maxBackups int) *Log {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	return &Log{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+}
+
+// DefaultPath returns ~/.mlcremote/audit.jsonl.
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.TempDir()
+	}
+	return filepath.Join(home, ".mlcremote", "audit.jsonl")
+}
+
+var (
+	stdMu sync.RWMutex
+	std   = New(DefaultPath(), DefaultMaxSize, DefaultMaxBackups)
+)
+
+// SetDefault replaces the log used by Record and Default.
+func SetDefault(l *Log) {
+	stdMu.Lock()
+	std = l
+	stdMu.Unlock()
+}
+
+// Default returns the log used by Record.
+func Default() *Log {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
+
+// Record writes ev to the default log. Failures are logged, never returned,
+// so auditing cannot break the request being audited.
+func Record(ev Event) {
+	if err := Default().Write(ev); err != nil {
+		log.Printf("[WARNING] audit: %v", err)
+	}
+}
+
+// Write appends ev, setting its time if unset.
+func (l *Log) Write(ev Event) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	if ev.Result == "" {
+		ev.Result = ResultOK
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(l.Path); err == nil && fi.Size()+int64(len(line)) > l.MaxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotate %s: %w", l.Path, err)
+		}
+	}
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(line)
+	return err
+}
+
+// rotate shifts Path.N-1 to Path.N, ..., Path to Path.1 and drops the
+// oldest backup.
+func (l *Log) rotate() error {
+	os.Remove(l.backup(l.MaxBackups))
+	for i := l.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(l.backup(i), l.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(l.Path, l.backup(1))
+}
+
+func (l *Log) backup(n int) string {
+	return fmt.Sprintf("%s.%d", l.Path, n)
+}
+
+// Filter selects events in Query. Zero fields match everything.
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Actions []string
+	// Path matches events whose path or target is Path or lies below it.
+	Path   string
+	Actor  string
+	Result string
+	// Limit keeps only the newest Limit matches.
+	Limit int
+}
+
+// Match reports whether ev passes the filter.
+func (f Filter) Match(ev Event) bool {
+	if !f.Since.IsZero() && ev.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && ev.Time.After(f.Until) {
+		return false
+	}
+	if f.Actor != "" && ev.Actor != f.Actor {
+		return false
+	}
+	if f.Result != "" && ev.Result != f.Result {
+		return false
+	}
+	if len(f.Actions) > 0 {
+		ok := false
+		for _, a := range f.Actions {
+			if ev.Action == a || strings.HasPrefix(ev.Action, a+".") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.Path != "" && !underPath(ev.Path, f.Path) && !underPath(ev.Target, f.Path) {
+		return false
+	}
+	return true
+}
+
+func underPath(p, prefix string) bool {
+	if p == "" {
+		return false
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	return p == prefix || strings.HasPrefix(p, prefix+"/") || prefix == ""
+}
+
+// Query returns the matching events from the log and its backups, oldest
+// first. Lines that cannot be parsed are skipped.
+func (l *Log) Query(f Filter) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []Event
+	files := make([]string, 0, l.MaxBackups+1)
+	for i := l.MaxBackups; i >= 1; i-- {
+		files = append(files, l.backup(i))
+	}
+	files = append(files, l.Path)
+
+	for _, name := range files {
+		fh, err := os.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		sc := bufio.NewScanner(fh)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			var ev Event
+			if json.Unmarshal(sc.Bytes(), &ev) != nil {
+				continue
+			}
+			if f.Match(ev) {
+				out = append(out, ev)
+			}
+		}
+		err = sc.Err()
+		fh.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out, nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// eventSize is the length of the JSON line written for testEvent(i).
+func eventSize(t *testing.T) int64 {
+	t.Helper()
+	line, err := json.Marshal(testEvent(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(line)) + 1
+}
+
+func testEvent(i int) Event {
+	ev := Event{Action: ActionFileWrite, Path: fmt.Sprintf("/f%03d", i), Result: ResultOK}
+	ev.Time = ev.Time.AddDate(2025, 0, i)
+	return ev
+}
+
+func TestLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// three events per file, two backups
+	l := New(path, 3*eventSize(t), 2)
+	for i := 0; i < 10; i++ {
+		if err := l.Write(testEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(name), err)
+		}
+		if fi.Size() > l.MaxSize {
+			t.Errorf("%s has %d bytes, more than MaxSize %d", filepath.Base(name), fi.Size(), l.MaxSize)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup beyond MaxBackups kept: %v", err)
+	}
+
+	// 10 events in files of 3: the oldest backup that was dropped held
+	// events 0-2, so 3-9 remain, oldest first
+	got, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 {
+		t.Fatalf("Query returned %d events, want 7", len(got))
+	}
+	for i, ev := range got {
+		if want := testEvent(i + 3).Path; ev.Path != want {
+			t.Errorf("event %d is %s, want %s", i, ev.Path, want)
+		}
+	}
+
+	got, err = l.Query(Filter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Path != "/f009" {
+		t.Errorf("Query with limit 2 = %+v, want the newest two events", got)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	ev := Event{Action: ActionFileRename, Path: "/src/a.go", Target: "/dst/a.go", Actor: "tok1", Result: ResultOK}
+	tests := []struct {
+		name string
+		f    Filter
+		want bool
+	}{
+		{"empty", Filter{}, true},
+		{"action", Filter{Actions: []string{ActionFileRename}}, true},
+		{"action prefix", Filter{Actions: []string{"file"}}, true},
+		{"other action", Filter{Actions: []string{"auth"}}, false},
+		{"partial prefix", Filter{Actions: []string{"file.ren"}}, false},
+		{"path", Filter{Path: "/src"}, true},
+		{"target", Filter{Path: "/dst/"}, true},
+		{"path sibling", Filter{Path: "/sr"}, false},
+		{"actor", Filter{Actor: "tok2"}, false},
+		{"result", Filter{Result: ResultDenied}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Match(ev); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPendingResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	prev := Default()
+	SetDefault(New(path, 0, 0))
+	t.Cleanup(func() { SetDefault(prev) })
+
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{0, ResultOK},
+		{http.StatusCreated, ResultOK},
+		{http.StatusUnauthorized, ResultDenied},
+		{http.StatusForbidden, ResultDenied},
+		{http.StatusConflict, ResultError},
+	}
+	for _, tt := range tests {
+		w, ev := Begin(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/file", nil), ActionFileWrite)
+		if tt.status != 0 {
+			w.WriteHeader(tt.status)
+		}
+		ev.Done()
+	}
+	got, err := Default().Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tests) {
+		t.Fatalf("%d events recorded, want %d", len(got), len(tests))
+	}
+	for i, tt := range tests {
+		if got[i].Result != tt.want {
+			t.Errorf("status %d recorded as %s, want %s", tt.status, got[i].Result, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"lightdev/internal/auth"
+)
+
+// FromRequest returns an event for action with the caller and remote
+// address of r filled in.
+func FromRequest(r *http.Request, action string) Event {
+	ev := Event{Action: action, RemoteAddr: r.RemoteAddr}
+	if p := auth.FromContext(r.Context()); p != nil {
+		ev.Actor = p.ID
+		ev.ActorName = p.Name
+	}
+	return ev
+}
+
+// Pending is an event whose result is taken from the response status.
+// Handlers fill in Path, Bytes etc. while running and call Done when
+// finished, usually deferred.
+type Pending struct {
+	Event
+	w *statusWriter
+}
+
+// Begin starts auditing a request. The returned writer must be used for
+// the response so that Done can see the status code.
+func Begin(w http.ResponseWriter, r *http.Request, action string) (http.ResponseWriter, *Pending) {
+	sw := &statusWriter{ResponseWriter: w}
+	return sw, &Pending{Event: FromRequest(r, action), w: sw}
+}
+
+// Done records the event. 401 and 403 count as denied, other statuses
+// from 400 up as errors.
+func (p *Pending) Done() {
+	status := p.w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	p.Status = status
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		p.Result = ResultDenied
+	case status >= 400:
+		p.Result = ResultError
+	default:
+		p.Result = ResultOK
+	}
+	Record(p.Event)
+}
+
+// statusWriter records the response status. It passes through Flush and
+// Hijack, so that streaming and websocket handlers can be audited.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
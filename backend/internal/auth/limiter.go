@@ -0,0 +1,217 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default login throttling parameters.
+const (
+	DefaultLoginMaxFailures = 5
+	DefaultLoginLockout     = 15 * time.Minute
+
+	loginBackoffBase = 1 * time.Second
+	loginBackoffMax  = 30 * time.Second
+	// loginPendingMax is how long an admitted attempt blocks the next
+	// one if its outcome is never reported.
+	loginPendingMax = 10 * time.Second
+)
+
+// CheckPassword compares a submitted password with the configured one in
+// constant time. Both values are hashed first so the comparison does not
+// leak the password length either.
+func CheckPassword(given, want string) bool {
+	g := sha256.Sum256([]byte(given))
+	w := sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(g[:], w[:]) == 1
+}
+
+// LockoutInfo describes the throttling state of one remote host.
+type LockoutInfo struct {
+	Host        string     `json:"host"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"lastFailure"`
+	NextAttempt time.Time  `json:"nextAttempt"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	// pending is when the attempt in progress was admitted
+	pending time.Time
+}
+
+// LoginLimiter throttles password logins per remote host. Every failure
+// doubles the wait before the next attempt (1s, 2s, 4s ... up to 30s);
+// after MaxFailures consecutive failures the host is locked out for
+// Lockout. A successful login resets the host. Only one attempt per host
+// is admitted at a time, so that parallel requests cannot all pass before
+// the first failure is recorded.
+type LoginLimiter struct {
+	mu          sync.Mutex
+	MaxFailures int
+	Lockout     time.Duration
+	hosts       map[string]*LockoutInfo
+}
+
+// NewLoginLimiter creates a limiter. Zero values select the defaults.
+func NewLoginLimiter(maxFailures int, lockout time.Duration) *LoginLimiter {
+	if maxFailures <= 0 {
+		maxFailures = DefaultLoginMaxFailures
+	}
+	if lockout <= 0 {
+		lockout = DefaultLoginLockout
+	}
+	return &LoginLimiter{
+		MaxFailures: maxFailures,
+		Lockout:     lockout,
+		hosts:       make(map[string]*LockoutInfo),
+	}
+}
+
+// HostOf strips the port from a request's RemoteAddr.
+func HostOf(remoteAddr string) string {
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return h
+	}
+	return remoteAddr
+}
+
+// Allow reports whether host may attempt a login now. If not, it returns
+// how long the caller has to wait. An admitted attempt is reserved until
+// its outcome is reported with Fail, Succeed or Release.
+func (l *LoginLimiter) Allow(host string) (bool, time.Duration) {
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pruneLocked(now)
+	e, ok := l.hosts[host]
+	if !ok {
+		e = &LockoutInfo{Host: host}
+		l.hosts[host] = e
+	}
+	if now.Before(e.NextAttempt) {
+		return false, e.NextAttempt.Sub(now)
+	}
+	if !e.pending.IsZero() && now.Sub(e.pending) < loginPendingMax {
+		return false, loginBackoffBase
+	}
+	e.pending = now
+	return true, 0
+}
+
+// Fail records a failed attempt and returns the updated state. The
+// returned info has LockedUntil set if this failure triggered a lockout.
+func (l *LoginLimiter) Fail(host string) LockoutInfo {
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.hosts[host]
+	if !ok {
+		e = &LockoutInfo{Host: host}
+		l.hosts[host] = e
+	}
+	e.pending = time.Time{}
+	e.Failures++
+	e.LastFailure = now
+	if e.Failures >= l.MaxFailures {
+		until := now.Add(l.Lockout)
+		e.LockedUntil = &until
+		e.NextAttempt = until
+	} else {
+		delay := loginBackoffBase << (e.Failures - 1)
+		if delay > loginBackoffMax {
+			delay = loginBackoffMax
+		}
+		e.NextAttempt = now.Add(delay)
+	}
+	return *e
+}
+
+// Succeed clears the failure history of host.
+func (l *LoginLimiter) Succeed(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.hosts, host)
+}
+
+// Release ends an admitted attempt that was not decided, e.g. because
+// the request was malformed.
+func (l *LoginLimiter) Release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.hosts[host]; ok {
+		e.pending = time.Time{}
+		if e.Failures == 0 {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+// Unlock clears the state of host and reports whether it had any.
+func (l *LoginLimiter) Unlock(host string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.hosts[host]; !ok {
+		return false
+	}
+	delete(l.hosts, host)
+	return true
+}
+
+// List returns all hosts with recorded failures, most recent first.
+func (l *LoginLimiter) List() []LockoutInfo {
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pruneLocked(now)
+	out := make([]LockoutInfo, 0, len(l.hosts))
+	for _, e := range l.hosts {
+		if e.Failures > 0 {
+			out = append(out, *e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastFailure.After(out[j].LastFailure) })
+	return out
+}
+
+// LockedCount returns the number of hosts currently locked out.
+func (l *LoginLimiter) LockedCount() int {
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, e := range l.hosts {
+		if e.LockedUntil != nil && now.Before(*e.LockedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// pruneLocked forgets hosts whose lockout has ended or whose last failure
+// is older than the lockout duration, and attempts whose outcome was
+// never reported. Callers must hold l.mu.
+func (l *LoginLimiter) pruneLocked(now time.Time) {
+	for h, e := range l.hosts {
+		if e.Failures == 0 {
+			if now.Sub(e.pending) >= loginPendingMax {
+				delete(l.hosts, h)
+			}
+			continue
+		}
+		if e.LockedUntil != nil {
+			if !now.Before(*e.LockedUntil) {
+				delete(l.hosts, h)
+			}
+			continue
+		}
+		if now.Sub(e.LastFailure) > l.Lockout {
+			delete(l.hosts, h)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiterSequence(t *testing.T) {
+	type step struct {
+		op      string // allow, fail, succeed, release
+		allowed bool
+		locked  bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"first attempt", []step{{op: "allow", allowed: true}}},
+		{"one attempt at a time", []step{
+			{op: "allow", allowed: true},
+			{op: "allow", allowed: false},
+		}},
+		{"backoff after failure", []step{
+			{op: "allow", allowed: true},
+			{op: "fail"},
+			{op: "allow", allowed: false},
+		}},
+		{"release frees the slot", []step{
+			{op: "allow", allowed: true},
+			{op: "release"},
+			{op: "allow", allowed: true},
+		}},
+		{"success resets", []step{
+			{op: "allow", allowed: true},
+			{op: "succeed"},
+			{op: "allow", allowed: true},
+		}},
+		{"lockout", []step{
+			{op: "fail"},
+			{op: "fail", locked: true},
+			{op: "allow", allowed: false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLoginLimiter(2, time.Minute)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if ok, _ := l.Allow("h"); ok != s.allowed {
+						t.Fatalf("step %d: Allow = %v, want %v", i, ok, s.allowed)
+					}
+				case "fail":
+					st := l.Fail("h")
+					if (st.LockedUntil != nil) != s.locked {
+						t.Fatalf("step %d: locked = %v, want %v", i, st.LockedUntil != nil, s.locked)
+					}
+				case "succeed":
+					l.Succeed("h")
+				case "release":
+					l.Release("h")
+				}
+			}
+		})
+	}
+}
+
+func TestLoginLimiterConcurrent(t *testing.T) {
+	l := NewLoginLimiter(5, time.Minute)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := l.Allow("h"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Fatalf("%d parallel attempts admitted, want 1", allowed)
+	}
+	if got := len(l.List()); got != 0 {
+		t.Fatalf("List has %d hosts without failures, want 0", got)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Scopes grant access to groups of API routes.
+const (
+	ScopeFilesRead  = "files:read"
+	ScopeFilesWrite = "files:write"
+	ScopeTerminal   = "terminal"
+	ScopeTrashEmpty = "trash:empty"
+	ScopeStats      = "stats"
+	// ScopeAdmin implies every other scope and guards token management.
+	ScopeAdmin = "admin"
+)
+
+// AllScopes lists every known scope.
+var AllScopes = []string{ScopeFilesRead, ScopeFilesWrite, ScopeTerminal, ScopeTrashEmpty, ScopeStats, ScopeAdmin}
+
+// Roles are named scope sets that can be given to a token instead of
+// listing scopes one by one.
+var Roles = map[string][]string{
+	"admin":     {ScopeAdmin},
+	"developer": {ScopeFilesRead, ScopeFilesWrite, ScopeTerminal, ScopeStats},
+	"editor":    {ScopeFilesRead, ScopeFilesWrite, ScopeStats},
+	"read-only": {ScopeFilesRead, ScopeStats},
+}
+
+// Principal kinds.
+const (
+	KindMaster    = "master"
+	KindToken     = "token"
+	KindAnonymous = "anonymous"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// ID identifies the credential (token id, or "master"/"anonymous").
+	ID     string
+	Name   string
+	Kind   string
+	Scopes []string
+}
+
+// Has reports whether the principal was granted scope. The admin scope
+// implies all others.
+func (p *Principal) Has(scope string) bool {
+	if p == nil {
+		return false
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// MasterPrincipal is the principal for the agent's startup token.
+func MasterPrincipal() *Principal {
+	return &Principal{ID: KindMaster, Name: "master token", Kind: KindMaster, Scopes: []string{ScopeAdmin}}
+}
+
+// AnonymousPrincipal is used when authentication is disabled (-no-auth).
+func AnonymousPrincipal() *Principal {
+	return &Principal{ID: KindAnonymous, Name: "anonymous", Kind: KindAnonymous, Scopes: []string{ScopeAdmin}}
+}
+
+// ResolveScopes expands an optional role and explicit scopes into a
+// sorted, de-duplicated scope list. Unknown roles or scopes are errors.
+func ResolveScopes(role string, scopes []string) ([]string, error) {
+	set := map[string]bool{}
+	if role != "" {
+		rs, ok := Roles[role]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+		for _, s := range rs {
+			set[s] = true
+		}
+	}
+	for _, s := range scopes {
+		if !isKnownScope(s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		set[s] = true
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("a role or at least one scope is required")
+	}
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionPrefix marks secrets minted by password login.
+const SessionPrefix = "mls_"
+
+// KindSession is the principal kind for login sessions.
+const KindSession = "session"
+
+// Default session lifetimes.
+const (
+	DefaultSessionIdle        = 1 * time.Hour
+	DefaultSessionMaxLifetime = 24 * time.Hour
+)
+
+// SessionInfo describes a login session. The secret is never kept, only
+// its hash.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	RemoteAddr string    `json:"remoteAddr"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeen   time.Time `json:"lastSeen"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// CSRFToken must accompany state-changing requests that authenticate
+	// with the session cookie instead of an explicit token.
+	CSRFToken string `json:"-"`
+	hash      string
+}
+
+// SessionStore is the in-memory table of login sessions. Sessions expire
+// after Idle without use (sliding) and never outlive MaxLifetime.
+type SessionStore struct {
+	mu          sync.Mutex
+	Idle        time.Duration
+	MaxLifetime time.Duration
+	byHash      map[string]*SessionInfo
+}
+
+// NewSessionStore creates a store using the given lifetimes. Zero values
+// select the defaults.
+func NewSessionStore(idle, maxLifetime time.Duration) *SessionStore {
+	if idle <= 0 {
+		idle = DefaultSessionIdle
+	}
+	if maxLifetime <= 0 {
+		maxLifetime = DefaultSessionMaxLifetime
+	}
+	return &SessionStore{
+		Idle:        idle,
+		MaxLifetime: maxLifetime,
+		byHash:      make(map[string]*SessionInfo),
+	}
+}
+
+// Create mints a new session and returns its info and secret.
+func (s *SessionStore) Create(remoteAddr, userAgent string) (SessionInfo, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return SessionInfo{}, "", err
+	}
+	raw, err := randomHex(24)
+	if err != nil {
+		return SessionInfo{}, "", err
+	}
+	csrf, err := randomHex(16)
+	if err != nil {
+		return SessionInfo{}, "", err
+	}
+	secret := SessionPrefix + raw
+	now := time.Now().UTC()
+	sess := &SessionInfo{
+		ID:         id,
+		RemoteAddr: remoteAddr,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeen:   now,
+		CSRFToken:  csrf,
+		hash:       hashSecret(secret),
+	}
+	sess.ExpiresAt = s.expiry(sess, now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	s.byHash[sess.hash] = sess
+	return *sess, secret, nil
+}
+
+// Authenticate returns the principal for a session secret and extends
+// the session's idle expiry. It returns nil for unknown or expired sessions.
+func (s *SessionStore) Authenticate(secret string) (*Principal, *SessionInfo) {
+	if !strings.HasPrefix(secret, SessionPrefix) {
+		return nil, nil
+	}
+	h := hashSecret(secret)
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.byHash[h]
+	if !ok {
+		return nil, nil
+	}
+	if !now.Before(sess.ExpiresAt) {
+		delete(s.byHash, h)
+		return nil, nil
+	}
+	sess.LastSeen = now
+	sess.ExpiresAt = s.expiry(sess, now)
+	info := *sess
+	return &Principal{ID: sess.ID, Name: "login session", Kind: KindSession, Scopes: []string{ScopeAdmin}}, &info
+}
+
+// Logout revokes the session identified by its secret.
+func (s *SessionStore) Logout(secret string) bool {
+	h := hashSecret(secret)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byHash[h]; !ok {
+		return false
+	}
+	delete(s.byHash, h)
+	return true
+}
+
+// Revoke deletes the session with the given id.
+func (s *SessionStore) Revoke(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for h, sess := range s.byHash {
+		if sess.ID == id {
+			delete(s.byHash, h)
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeAll deletes every session and returns how many were removed.
+func (s *SessionStore) RevokeAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.byHash)
+	s.byHash = make(map[string]*SessionInfo)
+	return n
+}
+
+// List returns the active sessions, oldest first.
+func (s *SessionStore) List() []SessionInfo {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	out := make([]SessionInfo, 0, len(s.byHash))
+	for _, sess := range s.byHash {
+		out = append(out, *sess)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func (s *SessionStore) expiry(sess *SessionInfo, now time.Time) time.Time {
+	exp := now.Add(s.Idle)
+	if hard := sess.CreatedAt.Add(s.MaxLifetime); hard.Before(exp) {
+		exp = hard
+	}
+	return exp
+}
+
+func (s *SessionStore) pruneLocked(now time.Time) {
+	for h, sess := range s.byHash {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.byHash, h)
+		}
+	}
+}
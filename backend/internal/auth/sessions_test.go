@@ -0,0 +1,120 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// age moves a session's clock back by d, as if it was created and last
+// used d earlier.
+func age(s *SessionStore, secret string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.byHash[hashSecret(secret)]
+	sess.CreatedAt = sess.CreatedAt.Add(-d)
+	sess.LastSeen = sess.LastSeen.Add(-d)
+	sess.ExpiresAt = s.expiry(sess, sess.LastSeen)
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := NewSessionStore(time.Hour, 3*time.Hour)
+	_, secret, err := s.Create("127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, info := s.Authenticate(secret)
+	if p == nil || p.Kind != KindSession {
+		t.Fatalf("Authenticate = %+v, want a session principal", p)
+	}
+	if got := info.ExpiresAt.Sub(info.LastSeen); got != time.Hour {
+		t.Errorf("idle expiry %v after last use, want 1h", got)
+	}
+
+	// each use slides the idle expiry forward
+	age(s, secret, 50*time.Minute)
+	if p, _ := s.Authenticate(secret); p == nil {
+		t.Fatal("session expired before its idle timeout")
+	}
+	age(s, secret, 50*time.Minute)
+	if p, _ := s.Authenticate(secret); p == nil {
+		t.Fatal("use did not extend the idle timeout")
+	}
+
+	// but never beyond the maximum lifetime
+	age(s, secret, 50*time.Minute)
+	_, info = s.Authenticate(secret)
+	if info == nil {
+		t.Fatal("session expired early")
+	}
+	if want := info.CreatedAt.Add(3 * time.Hour); !info.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want the hard limit %v", info.ExpiresAt, want)
+	}
+	age(s, secret, 31*time.Minute)
+	if p, _ := s.Authenticate(secret); p != nil {
+		t.Fatal("session outlived MaxLifetime")
+	}
+	if n := len(s.List()); n != 0 {
+		t.Errorf("%d sessions listed after expiry, want 0", n)
+	}
+
+	_, idle, err := s.Create("127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	age(s, idle, time.Hour)
+	if p, _ := s.Authenticate(idle); p != nil {
+		t.Fatal("session outlived its idle timeout")
+	}
+}
+
+func TestSessionRevocation(t *testing.T) {
+	s := NewSessionStore(0, 0)
+	if s.Idle != DefaultSessionIdle || s.MaxLifetime != DefaultSessionMaxLifetime {
+		t.Fatalf("lifetimes %v/%v, want the defaults", s.Idle, s.MaxLifetime)
+	}
+	secrets := make([]string, 3)
+	infos := make([]SessionInfo, 3)
+	for i := range secrets {
+		var err error
+		if infos[i], secrets[i], err = s.Create("127.0.0.1", "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p, _ := s.Authenticate("mlc_" + secrets[0][len(SessionPrefix):]); p != nil {
+		t.Error("secret without the session prefix accepted")
+	}
+
+	if !s.Logout(secrets[0]) {
+		t.Error("Logout of an active session returned false")
+	}
+	if s.Logout(secrets[0]) {
+		t.Error("second Logout returned true")
+	}
+	if p, _ := s.Authenticate(secrets[0]); p != nil {
+		t.Error("session usable after logout")
+	}
+
+	if !s.Revoke(infos[1].ID) {
+		t.Error("Revoke of an active session returned false")
+	}
+	if s.Revoke(infos[1].ID) {
+		t.Error("second Revoke returned true")
+	}
+	if p, _ := s.Authenticate(secrets[1]); p != nil {
+		t.Error("session usable after revocation")
+	}
+	if p, _ := s.Authenticate(secrets[2]); p == nil {
+		t.Error("revoking one session affected another")
+	}
+
+	if n := s.RevokeAll(); n != 1 {
+		t.Errorf("RevokeAll removed %d sessions, want 1", n)
+	}
+	if p, _ := s.Authenticate(secrets[2]); p != nil {
+		t.Error("session usable after RevokeAll")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+	"sync"
+)
+
+// TerminalPrefix marks secrets minted for terminal sessions.
+const TerminalPrefix = "mlt_"
+
+// KindTerminal is the principal kind for terminal session tokens.
+const KindTerminal = "terminal"
+
+// TerminalTokens holds the tokens handed to terminal sessions in
+// MLCREMOTE_TOKEN, so that helpers run in the shell (dev-server cmd, cwd)
+// can reach the agent without the credential of the user who opened the
+// terminal. They carry only the terminal scope, are kept in memory and
+// are revoked when the session ends.
+type TerminalTokens struct {
+	mu     sync.Mutex
+	byHash map[string]*Principal
+}
+
+// NewTerminalTokens creates an empty store.
+func NewTerminalTokens() *TerminalTokens {
+	return &TerminalTokens{byHash: make(map[string]*Principal)}
+}
+
+// Issue mints a token for a terminal opened by owner and returns its
+// secret.
+func (t *TerminalTokens) Issue(owner *Principal) (string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	raw, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	name := "terminal session"
+	if owner != nil {
+		name += " of " + owner.Name
+	}
+	secret := TerminalPrefix + raw
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byHash[hashSecret(secret)] = &Principal{ID: id, Name: name, Kind: KindTerminal, Scopes: []string{ScopeTerminal}}
+	return secret, nil
+}
+
+// Revoke invalidates a token returned by Issue.
+func (t *TerminalTokens) Revoke(secret string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.byHash, hashSecret(secret))
+}
+
+// Authenticate returns the principal for secret, or nil if it is not a
+// live terminal token.
+func (t *TerminalTokens) Authenticate(secret string) *Principal {
+	if !strings.HasPrefix(secret, TerminalPrefix) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.byHash[hashSecret(secret)]
+	if !ok {
+		return nil
+	}
+	c := *p
+	c.Scopes = append([]string(nil), p.Scopes...)
+	return &c
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTerminalTokens(t *testing.T) {
+	tt := NewTerminalTokens()
+	secret, err := tt.Issue(&Principal{Name: "alice", Kind: KindToken, Scopes: []string{ScopeAdmin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, TerminalPrefix) {
+		t.Fatalf("secret %q lacks prefix %q", secret, TerminalPrefix)
+	}
+
+	p := tt.Authenticate(secret)
+	if p == nil {
+		t.Fatal("issued token not accepted")
+	}
+	if p.Kind != KindTerminal || !p.Has(ScopeTerminal) || p.Has(ScopeFilesRead) || p.Has(ScopeAdmin) {
+		t.Errorf("terminal principal %+v, want only the terminal scope", p)
+	}
+	if p := tt.Authenticate(TerminalPrefix + "0123"); p != nil {
+		t.Error("unknown token accepted")
+	}
+
+	tt.Revoke(secret)
+	if p := tt.Authenticate(secret); p != nil {
+		t.Error("token usable after Revoke")
+	}
+}
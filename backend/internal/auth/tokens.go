@@ -0,0 +1,195 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPrefix marks secrets issued by the token store so they can be told
+// apart from the master token in logs and scripts.
+const TokenPrefix = "mlc_"
+
+// ErrTokenNotFound is returned when revoking an unknown token id.
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenInfo describes a named API token. The secret itself is never
+// stored, only its SHA-256 hash.
+type TokenInfo struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+	Hash      string     `json:"hash,omitempty"`
+}
+
+// TokenStore keeps named tokens in a JSON file on the agent.
+type TokenStore struct {
+	mu     sync.Mutex
+	path   string
+	tokens map[string]*TokenInfo // by id
+	byHash map[string]*TokenInfo
+}
+
+// NewTokenStore loads the token file at path. A missing file yields an
+// empty store; the file is created on the first change.
+func NewTokenStore(path string) (*TokenStore, error) {
+	s := &TokenStore{
+		path:   path,
+		tokens: make(map[string]*TokenInfo),
+		byHash: make(map[string]*TokenInfo),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	var list []*TokenInfo
+	if err := json.Unmarshal(data, &list); err != nil {
+		return s, err
+	}
+	for _, t := range list {
+		s.tokens[t.ID] = t
+		s.byHash[t.Hash] = t
+	}
+	return s, nil
+}
+
+// List returns all tokens sorted by creation time, without hashes.
+func (s *TokenStore) List() []TokenInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]TokenInfo, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		c := *t
+		c.Hash = ""
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Create issues a new token and returns its info and the secret. The
+// secret is only available at this point.
+func (s *TokenStore) Create(name, role string, scopes []string) (TokenInfo, string, error) {
+	resolved, err := ResolveScopes(role, scopes)
+	if err != nil {
+		return TokenInfo{}, "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return TokenInfo{}, "", errors.New("name is required")
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return TokenInfo{}, "", err
+	}
+	raw, err := randomHex(24)
+	if err != nil {
+		return TokenInfo{}, "", err
+	}
+	secret := TokenPrefix + raw
+
+	t := &TokenInfo{
+		ID:        id,
+		Name:      name,
+		Role:      role,
+		Scopes:    resolved,
+		CreatedAt: time.Now().UTC(),
+		Hash:      hashSecret(secret),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[t.ID] = t
+	s.byHash[t.Hash] = t
+	if err := s.saveLocked(); err != nil {
+		delete(s.tokens, t.ID)
+		delete(s.byHash, t.Hash)
+		return TokenInfo{}, "", err
+	}
+	info := *t
+	info.Hash = ""
+	return info, secret, nil
+}
+
+// Revoke deletes the token with the given id.
+func (s *TokenStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	delete(s.byHash, t.Hash)
+	return s.saveLocked()
+}
+
+// Authenticate returns the principal for secret, or nil if it is not a
+// known token.
+func (s *TokenStore) Authenticate(secret string) *Principal {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return nil
+	}
+	h := hashSecret(secret)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.byHash[h]
+	if !ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	t.LastUsed = &now
+	return &Principal{ID: t.ID, Name: t.Name, Kind: KindToken, Scopes: append([]string(nil), t.Scopes...)}
+}
+
+// saveLocked writes the store to disk. Callers must hold s.mu.
+func (s *TokenStore) saveLocked() error {
+	list := make([]*TokenInfo, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -6,15 +6,20 @@ package config
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"lightdev/internal/access"
 )
 
 // Config holds the application configuration.
 type Config struct {
 	Port        int
+	Host        string
 	Root        string
 	StaticDir   string
 	OpenAPIPath string
@@ -22,12 +27,81 @@ type Config struct {
 	Password    string
 	AllowDelete bool
 	TrashDir    string
+	// Confinement is the path policy: jail, home or unrestricted.
+	Confinement string
+	// Backup keeps the previous content of saved files as <name>.bak.
+	Backup bool
+	// MaxUploadSizeMB caps the size of a resumable upload; 0 means the
+	// default.
+	MaxUploadSizeMB int
+	// AccessRules protect paths matching glob patterns ([access] rule = ...).
+	AccessRules []access.Rule
+	TLS         bool
+	TLSCert     string
+	TLSKey      string
+	Socket      bool
+	SocketPath  string
+	// AllowedOrigins are extra browser origins allowed to call the API.
+	AllowedOrigins []string
+	// MetricsToken is an extra token that only grants access to /metrics.
+	MetricsToken string
+	// IdleTimeout shuts the agent down after this long without activity.
+	IdleTimeout time.Duration
+	// MaxLifetime shuts the agent down this long after it started.
+	MaxLifetime time.Duration
+	// ReadOnly refuses file changes and new terminals.
+	ReadOnly bool
+
+	// SessionIdle is how long a login session stays valid without use.
+	SessionIdle time.Duration
+	// SessionMaxLifetime caps the total age of a login session.
+	SessionMaxLifetime time.Duration
+	// LoginMaxFailures is the number of failed logins before a host is locked out.
+	LoginMaxFailures int
+	// LoginLockout is how long a host stays locked out.
+	LoginLockout time.Duration
+
+	// LogLevel is the minimum level logged once the agent has started.
+	LogLevel string
+	// LogDir holds agent.log and its rotated files; empty means ~/.mlcremote/logs.
+	LogDir string
+	// LogMaxSizeMB is the size at which agent.log is rotated.
+	LogMaxSizeMB int
+	// LogMaxBackups is the number of rotated log files kept.
+	LogMaxBackups int
+	// LogMaxAge is how long rotated log files are kept.
+	LogMaxAge time.Duration
+
+	// AuditFile is the JSONL audit log; empty means ~/.mlcremote/audit.jsonl.
+	AuditFile string
+	// AuditMaxSizeMB is the size at which the audit log is rotated.
+	AuditMaxSizeMB int
+	// AuditMaxBackups is the number of rotated audit logs kept.
+	AuditMaxBackups int
+
+	// Source is the path of the file the configuration was loaded from.
+	// It is empty if no configuration file was found.
+	Source string
+	// Issues lists unknown keys and invalid values found while parsing.
+	// Invalid values are ignored and the previous value is kept.
+	Issues []Issue
+}
+
+// Issue describes a problem found in a configuration file.
+type Issue struct {
+	Line    int
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
 }
 
 // DefaultConfig returns the default configuration.
 func DefaultConfig() *Config {
 	return &Config{
 		Port:        8443,
+		Host:        "127.0.0.1",
 		Root:        "", // defaults to environment or current dir in main
 		NoAuth:      false,
 		AllowDelete: true, // Default to true as requested
@@ -35,6 +109,184 @@ func DefaultConfig() *Config {
 	}
 }
 
+// keySpec describes a supported configuration key.
+// section is the INI section the key belongs to; keys are also accepted
+// outside of any section for compatibility with older flat files.
+type keySpec struct {
+	section string
+	names   []string
+	apply   func(cfg *Config, val string) error
+}
+
+var keySpecs = []keySpec{
+	{"server", []string{"port"}, func(cfg *Config, val string) error {
+		i, err := strconv.Atoi(val)
+		if err != nil || i < 0 || i > 65535 {
+			return fmt.Errorf("invalid port %q", val)
+		}
+		cfg.Port = i
+		return nil
+	}},
+	{"server", []string{"host"}, func(cfg *Config, val string) error {
+		if val == "" {
+			return fmt.Errorf("host must not be empty")
+		}
+		cfg.Host = val
+		return nil
+	}},
+	{"server", []string{"root"}, func(cfg *Config, val string) error {
+		cfg.Root = expandHome(val)
+		return nil
+	}},
+	{"server", []string{"static_dir", "staticdir"}, func(cfg *Config, val string) error {
+		cfg.StaticDir = expandHome(val)
+		return nil
+	}},
+	{"server", []string{"openapi", "openapi_path"}, func(cfg *Config, val string) error {
+		cfg.OpenAPIPath = expandHome(val)
+		return nil
+	}},
+	{"server", []string{"tls"}, func(cfg *Config, val string) error {
+		return parseBool(val, &cfg.TLS)
+	}},
+	{"server", []string{"tls_cert"}, func(cfg *Config, val string) error {
+		cfg.TLSCert = expandHome(val)
+		return nil
+	}},
+	{"server", []string{"tls_key"}, func(cfg *Config, val string) error {
+		cfg.TLSKey = expandHome(val)
+		return nil
+	}},
+	{"server", []string{"socket"}, func(cfg *Config, val string) error {
+		return parseBool(val, &cfg.Socket)
+	}},
+	{"server", []string{"socket_path"}, func(cfg *Config, val string) error {
+		cfg.SocketPath = expandHome(val)
+		return nil
+	}},
+	{"server", []string{"allowed_origins"}, func(cfg *Config, val string) error {
+		cfg.AllowedOrigins = SplitList(val)
+		return nil
+	}},
+	{"server", []string{"idle_timeout"}, func(cfg *Config, val string) error {
+		return parseDuration(val, &cfg.IdleTimeout)
+	}},
+	{"server", []string{"max_lifetime"}, func(cfg *Config, val string) error {
+		return parseDuration(val, &cfg.MaxLifetime)
+	}},
+	{"server", []string{"read_only", "readonly"}, func(cfg *Config, val string) error {
+		return parseBool(val, &cfg.ReadOnly)
+	}},
+	{"auth", []string{"metrics_token"}, func(cfg *Config, val string) error {
+		cfg.MetricsToken = val
+		return nil
+	}},
+	{"auth", []string{"no_auth", "noauth"}, func(cfg *Config, val string) error {
+		return parseBool(val, &cfg.NoAuth)
+	}},
+	{"auth", []string{"password"}, func(cfg *Config, val string) error {
+		cfg.Password = val
+		return nil
+	}},
+	{"auth", []string{"session_idle", "session_ttl"}, func(cfg *Config, val string) error {
+		return parseDuration(val, &cfg.SessionIdle)
+	}},
+	{"auth", []string{"session_max_lifetime"}, func(cfg *Config, val string) error {
+		return parseDuration(val, &cfg.SessionMaxLifetime)
+	}},
+	{"auth", []string{"login_max_failures"}, func(cfg *Config, val string) error {
+		return parsePositiveInt(val, &cfg.LoginMaxFailures)
+	}},
+	{"auth", []string{"login_lockout"}, func(cfg *Config, val string) error {
+		return parseDuration(val, &cfg.LoginLockout)
+	}},
+	{"files", []string{"allow_delete", "allowdelete"}, func(cfg *Config, val string) error {
+		return parseBool(val, &cfg.AllowDelete)
+	}},
+	{"files", []string{"trash_dir", "trashdir"}, func(cfg *Config, val string) error {
+		cfg.TrashDir = expandHome(val)
+		return nil
+	}},
+	{"files", []string{"backup", "backups"}, func(cfg *Config, val string) error {
+		return parseBool(val, &cfg.Backup)
+	}},
+	{"files", []string{"confinement"}, func(cfg *Config, val string) error {
+		switch strings.ToLower(val) {
+		case "jail", "home", "unrestricted":
+			cfg.Confinement = strings.ToLower(val)
+			return nil
+		}
+		return fmt.Errorf("invalid confinement %q (use jail, home or unrestricted)", val)
+	}},
+	{"files", []string{"max_upload_size_mb"}, func(cfg *Config, val string) error {
+		return parsePositiveInt(val, &cfg.MaxUploadSizeMB)
+	}},
+	{"access", []string{"rule"}, func(cfg *Config, val string) error {
+		rule, err := access.ParseRule(val)
+		if err != nil {
+			return err
+		}
+		cfg.AccessRules = append(cfg.AccessRules, rule)
+		return nil
+	}},
+	{"logging", []string{"log_level"}, func(cfg *Config, val string) error {
+		switch strings.ToLower(val) {
+		case "debug", "info", "warn", "warning", "error":
+			cfg.LogLevel = strings.ToLower(val)
+			return nil
+		}
+		return fmt.Errorf("invalid log level %q (use debug, info, warn or error)", val)
+	}},
+	{"logging", []string{"log_dir"}, func(cfg *Config, val string) error {
+		cfg.LogDir = expandHome(val)
+		return nil
+	}},
+	{"logging", []string{"log_max_size_mb"}, func(cfg *Config, val string) error {
+		return parsePositiveInt(val, &cfg.LogMaxSizeMB)
+	}},
+	{"logging", []string{"log_max_backups"}, func(cfg *Config, val string) error {
+		return parsePositiveInt(val, &cfg.LogMaxBackups)
+	}},
+	{"logging", []string{"log_max_age"}, func(cfg *Config, val string) error {
+		return parseDuration(val, &cfg.LogMaxAge)
+	}},
+	{"audit", []string{"audit_file"}, func(cfg *Config, val string) error {
+		cfg.AuditFile = expandHome(val)
+		return nil
+	}},
+	{"audit", []string{"audit_max_size_mb"}, func(cfg *Config, val string) error {
+		return parsePositiveInt(val, &cfg.AuditMaxSizeMB)
+	}},
+	{"audit", []string{"audit_max_backups"}, func(cfg *Config, val string) error {
+		return parsePositiveInt(val, &cfg.AuditMaxBackups)
+	}},
+}
+
+// Sections returns the names of the supported INI sections.
+func Sections() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, k := range keySpecs {
+		if !seen[k.section] {
+			seen[k.section] = true
+			out = append(out, k.section)
+		}
+	}
+	return out
+}
+
+// UserPath returns the per-user configuration file location.
+func UserPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mlcremote", "config.ini")
+}
+
+// SystemPath is the system-wide configuration file location.
+const SystemPath = "/etc/mlcremote/config.ini"
+
 // Load attempts to load configuration from the standard locations.
 // Priority:
 // 1. ~/.mlcremote/config.ini
@@ -43,26 +295,29 @@ func DefaultConfig() *Config {
 // It returns the loaded config (with defaults for missing fields) or the default config if no file is found.
 // Errors are returned only if a file exists but cannot be read/parsed.
 func Load() (*Config, error) {
-	cfg := DefaultConfig()
-
-	home, err := os.UserHomeDir()
-	if err == nil {
-		userPath := filepath.Join(home, ".mlcremote", "config.ini")
+	if userPath := UserPath(); userPath != "" {
 		if _, err := os.Stat(userPath); err == nil {
-			return parseFile(userPath, cfg)
+			return LoadFile(userPath)
 		}
 	}
 
-	sysPath := "/etc/mlcremote/config.ini"
-	if _, err := os.Stat(sysPath); err == nil {
-		return parseFile(sysPath, cfg)
+	if _, err := os.Stat(SystemPath); err == nil {
+		return LoadFile(SystemPath)
 	}
 
-	return cfg, nil
+	return DefaultConfig(), nil
+}
+
+// LoadFile loads configuration from an explicit path on top of the defaults.
+// Unlike Load, a missing file is an error.
+func LoadFile(path string) (*Config, error) {
+	return parseFile(expandHome(path), DefaultConfig())
 }
 
 // parseFile reads a simple key=value INI file.
-// Supported keys: port, root, static_dir, openapi_path, no_auth
+// Keys may appear at the top level or inside their section
+// ([server], [auth], [files]). Unknown sections, unknown keys and
+// invalid values are recorded in Config.Issues.
 func parseFile(path string, defaults *Config) (*Config, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -72,25 +327,39 @@ func parseFile(path string, defaults *Config) (*Config, error) {
 
 	// copy defaults
 	cfg := *defaults
+	cfg.Source = path
+	cfg.Issues = nil
+
+	knownSections := map[string]bool{}
+	for _, s := range Sections() {
+		knownSections[s] = true
+	}
 
+	section := ""
+	lineNo := 0
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
+		lineNo++
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
 			continue
 		}
 
-		// Handle section headers [Section] - currently ignored as we use a flat structure
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if !knownSections[section] {
+				cfg.Issues = append(cfg.Issues, Issue{lineNo, fmt.Sprintf("unknown section [%s]", section)})
+			}
 			continue
 		}
 
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
+			cfg.Issues = append(cfg.Issues, Issue{lineNo, fmt.Sprintf("expected key = value, got %q", line)})
 			continue
 		}
 
-		key := strings.TrimSpace(parts[0])
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
 		val := strings.TrimSpace(parts[1])
 
 		// remove quotes if present
@@ -98,35 +367,67 @@ func parseFile(path string, defaults *Config) (*Config, error) {
 			val = val[1 : len(val)-1]
 		}
 
-		switch strings.ToLower(key) {
-		case "port":
-			if i, err := strconv.Atoi(val); err == nil {
-				cfg.Port = i
-			}
-		case "root":
-			cfg.Root = expandHome(val)
-		case "static_dir", "staticdir":
-			cfg.StaticDir = expandHome(val)
-		case "openapi", "openapi_path":
-			cfg.OpenAPIPath = expandHome(val)
-		case "no_auth", "noauth":
-			if b, err := strconv.ParseBool(val); err == nil {
-				cfg.NoAuth = b
+		spec := lookupKey(section, key)
+		if spec == nil {
+			if section == "" {
+				cfg.Issues = append(cfg.Issues, Issue{lineNo, fmt.Sprintf("unknown key %q", key)})
+			} else {
+				cfg.Issues = append(cfg.Issues, Issue{lineNo, fmt.Sprintf("unknown key %q in section [%s]", key, section)})
 			}
-		case "password":
-			cfg.Password = val
-		case "allow_delete", "allowdelete":
-			if b, err := strconv.ParseBool(val); err == nil {
-				cfg.AllowDelete = b
-			}
-		case "trash_dir", "trashdir":
-			cfg.TrashDir = expandHome(val)
+			continue
+		}
+		if err := spec.apply(&cfg, val); err != nil {
+			cfg.Issues = append(cfg.Issues, Issue{lineNo, fmt.Sprintf("%s: %v", key, err)})
 		}
 	}
 
 	return &cfg, scanner.Err()
 }
 
+// lookupKey finds the spec for key. Outside of a section every key is
+// accepted; inside a section only the keys belonging to it are.
+func lookupKey(section, key string) *keySpec {
+	for i := range keySpecs {
+		spec := &keySpecs[i]
+		if section != "" && spec.section != section {
+			continue
+		}
+		for _, n := range spec.names {
+			if n == key {
+				return spec
+			}
+		}
+	}
+	return nil
+}
+
+func parseBool(val string, dst *bool) error {
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", val)
+	}
+	*dst = b
+	return nil
+}
+
+func parsePositiveInt(val string, dst *int) error {
+	i, err := strconv.Atoi(val)
+	if err != nil || i < 1 {
+		return fmt.Errorf("invalid count %q", val)
+	}
+	*dst = i
+	return nil
+}
+
+func parseDuration(val string, dst *time.Duration) error {
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid duration %q (use e.g. 30m, 12h)", val)
+	}
+	*dst = d
+	return nil
+}
+
 func expandHome(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, _ := os.UserHomeDir()
@@ -134,3 +435,14 @@ func expandHome(path string) string {
 	}
 	return path
 }
+
+// SplitList splits a comma-separated value into its trimmed, non-empty items.
+func SplitList(val string) []string {
+	var out []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"lightdev/internal/util"
 )
 
 // UserSettings represents the user-configurable settings persisted to disk.
@@ -92,5 +94,5 @@ func SaveSettings(path string, s *UserSettings) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	return util.WriteFileAtomic(path, data, 0644)
 }
@@ -12,6 +12,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"lightdev/internal/util"
 )
 
 // ArchiveEntry represents a file within an archive
@@ -45,16 +47,12 @@ func ListArchiveHandler(root string) http.HandlerFunc {
 			return
 		}
 
-		// Security check: join with root and clean
-		cleanRoot := filepath.Clean(root)
-		absPath := filepath.Join(root, relPath)
-		cleanPath := filepath.Clean(absPath)
-
-		// Hmm .. Case-insensitive check on Windows is complex, but standard prefix check
-		// with normalized separators should catch basic traversal attacks.
-		// We use Clean() to ensure separators are consistent (OS-specific).
-		if !strings.HasPrefix(cleanPath, cleanRoot) {
-			http.Error(w, "access denied", http.StatusForbidden)
+		absPath, err := util.SanitizePath(root, relPath)
+		if err != nil {
+			pathError(w, r, relPath, err)
+			return
+		}
+		if !allowRead(w, r, relPath, absPath, false) {
 			return
 		}
 
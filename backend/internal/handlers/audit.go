@@ -1,42 +1,82 @@
 package handlers
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
-	"sync"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
-)
-
-var auditMu sync.Mutex
-
-// WriteAuditLog appends a message to ~/.mlcremote/audit.log with a timestamp.
-func WriteAuditLog(format string, v ...interface{}) {
-	auditMu.Lock()
-	defer auditMu.Unlock()
-
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return // Silently fail if we can't find home
-	}
 
-	logDir := filepath.Join(home, ".mlcremote")
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return
-	}
+	"lightdev/internal/audit"
+)
 
-	logPath := filepath.Join(logDir, "audit.log")
-	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return
-	}
-	defer f.Close()
+// defaultAuditLimit caps /api/audit responses without an explicit limit.
+const defaultAuditLimit = 1000
 
-	msg := fmt.Sprintf(format, v...)
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	line := fmt.Sprintf("[%s] %s\n", timestamp, msg)
+// AuditHandler returns audit events matching the query.
+// @Summary Query audit log
+// @Description Returns audit events, oldest first. since/until take RFC 3339 times, action a comma-separated list of actions or prefixes (e.g. "file" or "auth.login"), path a file or directory. Only the newest limit events (default 1000) are returned. Requires the admin scope.
+// @ID queryAudit
+// @Tags system
+// @Security TokenAuth
+// @Produce json
+// @Param since query string false "Start time (RFC 3339)"
+// @Param until query string false "End time (RFC 3339)"
+// @Param action query string false "Actions or action prefixes, comma-separated"
+// @Param path query string false "Path prefix"
+// @Param actor query string false "Credential id"
+// @Param result query string false "ok, denied or error"
+// @Param limit query int false "Maximum number of events"
+// @Success 200 {array} audit.Event
+// @Failure 400
+// @Router /api/audit [get]
+func AuditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		f := audit.Filter{
+			Path:   q.Get("path"),
+			Actor:  q.Get("actor"),
+			Result: q.Get("result"),
+			Limit:  defaultAuditLimit,
+		}
+		for _, a := range strings.Split(q.Get("action"), ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				f.Actions = append(f.Actions, a)
+			}
+		}
+		var err error
+		if v := q.Get("since"); v != "" {
+			if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "invalid since", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("until"); v != "" {
+			if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "invalid until", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
 
-	if _, err := f.WriteString(line); err != nil {
-		// ignore
+		events, err := audit.Default().Query(f)
+		if err != nil {
+			http.Error(w, "failed to read audit log", http.StatusInternalServerError)
+			return
+		}
+		if events == nil {
+			events = []audit.Event{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(events)
 	}
 }
@@ -0,0 +1,133 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"lightdev/internal/access"
+	"lightdev/internal/audit"
+	"lightdev/internal/util"
+)
+
+// pathError reports a path rejected by util.SanitizePath. Paths outside
+// the confinement get 403 and are audited; other errors get 400.
+func pathError(w http.ResponseWriter, r *http.Request, reqPath string, err error) {
+	status, msg := pathErrorStatus(r, reqPath, err)
+	http.Error(w, msg, status)
+}
+
+// pathErrorStatus is pathError for handlers that report several paths in
+// one response: it audits a denied path and returns the status and
+// message for err.
+func pathErrorStatus(r *http.Request, reqPath string, err error) (int, string) {
+	if errors.Is(err, util.ErrOutsideRoot) {
+		auditDenied(r, reqPath, "")
+		return http.StatusForbidden, "forbidden: " + err.Error()
+	}
+	return http.StatusBadRequest, err.Error()
+}
+
+// auditDenied records a refused path.
+func auditDenied(r *http.Request, reqPath, detail string) {
+	ev := audit.FromRequest(r, audit.ActionPathDenied)
+	ev.Path = reqPath
+	ev.Result = audit.ResultDenied
+	ev.Status = http.StatusForbidden
+	ev.Detail = r.Method + " " + r.URL.Path
+	if detail != "" {
+		ev.Detail += " " + detail
+	}
+	audit.Record(ev)
+}
+
+// lookup returns the most restrictive rule for target, the resolved path,
+// and for the path the client asked for if that differs, so a symlink
+// cannot lift the rules on its own name. Only absolute request paths are
+// compared; the tree hands out those.
+func lookup(p *access.Policy, reqPath, target string) *access.Rule {
+	rule := p.Lookup(target)
+	if filepath.IsAbs(reqPath) {
+		if link := filepath.Clean(reqPath); link != target {
+			rule = stricter(rule, p.Lookup(link))
+		}
+	}
+	return rule
+}
+
+// stricter returns the more restrictive of two rules, either may be nil.
+func stricter(a, b *access.Rule) *access.Rule {
+	if b != nil && (a == nil || b.Mode > a.Mode) {
+		return b
+	}
+	return a
+}
+
+// allowRead reports whether the access rules let target be read. If not,
+// it answers 404 for hidden and 403 for denied paths. With recursive set
+// the contents of a directory are checked as well.
+func allowRead(w http.ResponseWriter, r *http.Request, reqPath, target string, recursive bool) bool {
+	p := access.Default()
+	rule, self := lookup(p, reqPath, target), true
+	if (rule == nil || rule.Mode < access.Deny) && recursive {
+		rule, self = p.Within(target, access.Deny), false
+	}
+	if rule == nil || rule.Mode < access.Deny {
+		return true
+	}
+	accessDenied(w, r, reqPath, rule, self)
+	return false
+}
+
+// allowStat reports whether the metadata of target may be shown, which
+// is refused for hidden paths only.
+func allowStat(w http.ResponseWriter, r *http.Request, reqPath, target string) bool {
+	if rule := lookup(access.Default(), reqPath, target); rule != nil && rule.Mode == access.Hidden {
+		accessDenied(w, r, reqPath, rule, true)
+		return false
+	}
+	return true
+}
+
+// allowWrite reports whether the access rules let target be created,
+// changed, renamed or deleted. If not, it answers like allowRead. With
+// recursive set the contents of a directory are checked as well.
+func allowWrite(w http.ResponseWriter, r *http.Request, reqPath, target string, recursive bool) bool {
+	p := access.Default()
+	rule, self := lookup(p, reqPath, target), true
+	if rule == nil && recursive {
+		rule, self = p.Within(target, access.ReadOnly), false
+	}
+	if rule == nil {
+		return true
+	}
+	accessDenied(w, r, reqPath, rule, self)
+	return false
+}
+
+func accessDenied(w http.ResponseWriter, r *http.Request, reqPath string, rule *access.Rule, self bool) {
+	auditDenied(r, reqPath, "rule "+rule.String())
+	switch {
+	case rule.Mode == access.Hidden && self:
+		http.Error(w, "not found", http.StatusNotFound)
+	case !self:
+		http.Error(w, "forbidden: directory contains protected paths (rule "+rule.String()+")", http.StatusForbidden)
+	case rule.Mode == access.ReadOnly:
+		http.Error(w, "forbidden: path is read-only (rule "+rule.String()+")", http.StatusForbidden)
+	default:
+		http.Error(w, "forbidden: access denied (rule "+rule.String()+")", http.StatusForbidden)
+	}
+}
+
+// accessInfo returns the mode and pattern of rule for dirEntry and
+// FileStat; both are empty if rule is nil.
+func accessInfo(rule *access.Rule) (mode, pattern string) {
+	if rule != nil {
+		return rule.Mode.String(), rule.Pattern
+	}
+	return "", ""
+}
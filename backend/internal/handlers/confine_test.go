@@ -0,0 +1,65 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lightdev/internal/access"
+)
+
+// TestSymlinkRules checks that rules apply to a symlink's own name and to
+// what it points to, in the tree as well as for reads.
+func TestSymlinkRules(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"data.txt": "data", "secret.pem": "key"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// notes.txt leads to a hidden file, key.pem is a hidden name for a
+	// visible one
+	for link, target := range map[string]string{"notes.txt": "secret.pem", "key.pem": "data.txt"} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+	access.SetDefault(access.New(root, []access.Rule{{Pattern: "**/*.pem", Mode: access.Hidden}}))
+	defer access.SetDefault(nil)
+
+	w := httptest.NewRecorder()
+	TreeHandler(root).ServeHTTP(w, httptest.NewRequest("GET", "/api/tree?path="+root, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("tree: status %d", w.Code)
+	}
+	var entries []dirEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, e := range entries {
+		listed = append(listed, e.Name)
+	}
+	if len(listed) != 1 || listed[0] != "data.txt" {
+		t.Errorf("tree lists %v, want only data.txt", listed)
+	}
+
+	get := GetFileHandler(root)
+	for _, name := range []string{"notes.txt", "key.pem", "secret.pem"} {
+		w := httptest.NewRecorder()
+		get.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path="+filepath.Join(root, name), nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", name, w.Code)
+		}
+	}
+}
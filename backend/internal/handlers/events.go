@@ -22,7 +22,6 @@ func EventsHandler(w *watcher.Service) http.HandlerFunc {
 		wResp.Header().Set("Content-Type", "text/event-stream")
 		wResp.Header().Set("Cache-Control", "no-cache")
 		wResp.Header().Set("Connection", "keep-alive")
-		wResp.Header().Set("Access-Control-Allow-Origin", "*")
 
 		// Flush headers immediately
 		flusher, ok := wResp.(http.Flusher)
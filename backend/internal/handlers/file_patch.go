@@ -0,0 +1,385 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/util"
+)
+
+// maxPatchSize is the largest file PatchFileHandler edits.
+const maxPatchSize = 64 << 20
+
+// Position is a zero-based line and character as in the Language Server
+// Protocol: characters are counted in UTF-16 code units, and "\n", "\r\n"
+// and "\r" end a line.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// TextEdit replaces the text between Start and End with Text.
+type TextEdit struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+	Text  string   `json:"text"`
+}
+
+// PatchRequest represents a POST /api/file/patch body. Exactly one of
+// Edits and Diff is set.
+type PatchRequest struct {
+	Path string `json:"path"`
+	// BaseVersion is the version the edits are based on. The If-Match
+	// header may be used instead.
+	BaseVersion string `json:"baseVersion"`
+	// Edits refer to the base content; they must not overlap.
+	Edits []TextEdit `json:"edits,omitempty"`
+	// Diff is a unified diff of one file, e.g. from diff -u or git diff.
+	Diff string `json:"diff,omitempty"`
+}
+
+// PatchResult describes the file after a patch.
+type PatchResult struct {
+	Version string `json:"version"`
+	Size    int64  `json:"size"`
+	// SHA256 of the new file content, to check the client's copy.
+	SHA256 string `json:"sha256"`
+}
+
+// PatchFileHandler applies text edits or a unified diff to a file.
+// @Summary Patch file
+// @Description Applies range edits or a unified diff to a text file, so that only the changes are sent. Positions and diff lines refer to the content as served by GET /api/file (decoded to UTF-8, without BOM); the file keeps its encoding and BOM. baseVersion (or If-Match) is required; if the file changed since, nothing is written and the response is 409 with the current version. The file is replaced atomically.
+// @ID patchFile
+// @Tags file
+// @Security TokenAuth
+// @Accept json
+// @Produce json
+// @Param body body PatchRequest true "Edits or diff"
+// @Param If-Match header string false "Base version (ETag)"
+// @Success 200 {object} PatchResult
+// @Failure 400 "Invalid edits, or the diff does not apply"
+// @Failure 409 {object} SaveConflict
+// @Failure 413 "File too large"
+// @Router /api/file/patch [post]
+func PatchFileHandler(root string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileWrite)
+		defer ev.Done()
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if util.IsBlocked() {
+			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req PatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		ev.Path = req.Path
+		ev.Detail = "patch"
+		target, err := util.SanitizePath(root, req.Path)
+		if err != nil {
+			pathError(w, r, req.Path, err)
+			return
+		}
+		if !allowWrite(w, r, req.Path, target, false) {
+			return
+		}
+		if (req.Edits == nil) == (req.Diff == "") {
+			http.Error(w, "either edits or diff required", http.StatusBadRequest)
+			return
+		}
+		base := util.ParseVersion(req.BaseVersion)
+		if base == "" {
+			base = util.ParseVersion(r.Header.Get("If-Match"))
+		}
+		if base == "" || base == "*" {
+			http.Error(w, "baseVersion required", http.StatusBadRequest)
+			return
+		}
+		if !checkVersion(w, target, base) {
+			ev.Detail = "patch: version conflict"
+			return
+		}
+		fi, err := os.Stat(target)
+		if err != nil || !fi.Mode().IsRegular() {
+			http.Error(w, "not a file", http.StatusBadRequest)
+			return
+		}
+		if fi.Size() > maxPatchSize {
+			http.Error(w, fmt.Sprintf("file too large to patch (max %d MB)", maxPatchSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			http.Error(w, "read failed", http.StatusInternalServerError)
+			return
+		}
+		info, ok := util.DetectText(data)
+		if !ok {
+			http.Error(w, "not a text file", http.StatusBadRequest)
+			return
+		}
+		text, err := util.DecodeText(data, info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Diff != "" {
+			text, err = applyDiff(text, req.Diff)
+		} else {
+			text, err = applyEdits(text, req.Edits)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out, err := util.EncodeText(text, info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the file may have changed while the patch was applied; other
+		// saves are held off from this check until the file is replaced
+		defer lockPath(target)()
+		if !checkVersion(w, target, base) {
+			ev.Detail = "patch: version conflict"
+			return
+		}
+		f, err := util.CreateAtomic(target, fi.Mode().Perm())
+		if err != nil {
+			http.Error(w, "write failed", http.StatusInternalServerError)
+			return
+		}
+		defer f.Abort()
+		if _, err := f.Write(out); err != nil {
+			http.Error(w, "write failed", http.StatusInternalServerError)
+			return
+		}
+		if err := f.Commit(); err != nil {
+			http.Error(w, "write failed", http.StatusInternalServerError)
+			return
+		}
+		ev.Bytes = int64(len(out))
+		sum := sha256.Sum256(out)
+		res := PatchResult{Size: int64(len(out)), SHA256: hex.EncodeToString(sum[:])}
+		if fi, err := os.Stat(target); err == nil {
+			res.Version = util.FileVersion(fi)
+			w.Header().Set("ETag", `"`+res.Version+`"`)
+			w.Header().Set("X-File-Version", res.Version)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+// lineStarts returns the byte offset of each line of text.
+func lineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+			starts = append(starts, i+1)
+		case '\n':
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// offsetOf returns the byte offset of p in text. A character past the end
+// of the line means the end of the line.
+func offsetOf(text string, starts []int, p Position) (int, error) {
+	if p.Line < 0 || p.Character < 0 || p.Line >= len(starts) {
+		return 0, fmt.Errorf("position %d:%d out of range", p.Line, p.Character)
+	}
+	i, end := starts[p.Line], len(text)
+	if p.Line+1 < len(starts) {
+		end = i + len(trimEOL(text[i:starts[p.Line+1]]))
+	}
+	for units := 0; units < p.Character && i < end; {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r >= 0x10000 {
+			// a surrogate pair in UTF-16
+			units += 2
+		} else {
+			units++
+		}
+		i += size
+	}
+	return i, nil
+}
+
+// applyEdits applies non-overlapping edits that refer to text.
+func applyEdits(text string, edits []TextEdit) (string, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	starts := lineStarts(text)
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		start, err := offsetOf(text, starts, e.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := offsetOf(text, starts, e.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", fmt.Errorf("edit ends before it starts at %d:%d", e.Start.Line, e.Start.Character)
+		}
+		spans = append(spans, span{start, end, e.Text})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var sb strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.start < pos {
+			return "", fmt.Errorf("edits overlap")
+		}
+		sb.WriteString(text[pos:s.start])
+		sb.WriteString(s.text)
+		pos = s.end
+	}
+	sb.WriteString(text[pos:])
+	return sb.String(), nil
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// applyDiff applies a unified diff of one file to text. Context and
+// removed lines must match exactly; there is no fuzz. Added lines get the
+// line ending the file mostly uses.
+func applyDiff(text, diff string) (string, error) {
+	lines := splitLines(text)
+	eol := "\n"
+	switch util.DetectEOL(text) {
+	case util.EOLCRLF:
+		eol = "\r\n"
+	case util.EOLCR:
+		eol = "\r"
+	}
+	dl := strings.Split(diff, "\n")
+	var out []string
+	pos, hunks := 0, 0
+	for i := 0; i < len(dl); i++ {
+		l := dl[i]
+		m := hunkHeader.FindStringSubmatch(l)
+		if m == nil {
+			if hunks > 0 && (strings.HasPrefix(l, "--- ") || strings.HasPrefix(l, "diff ")) {
+				return "", fmt.Errorf("diff must change a single file")
+			}
+			// file headers and trailing text
+			continue
+		}
+		hunks++
+		oldStart, _ := strconv.Atoi(m[1])
+		oldCount, newCount := 1, 1
+		if m[2] != "" {
+			oldCount, _ = strconv.Atoi(m[2])
+		}
+		if m[4] != "" {
+			newCount, _ = strconv.Atoi(m[4])
+		}
+		start := oldStart - 1
+		if oldCount == 0 {
+			// an insertion names the line it follows
+			start = oldStart
+		}
+		if start < pos || start > len(lines) {
+			return "", fmt.Errorf("hunk %d: line %d out of range or out of order", hunks, oldStart)
+		}
+		out = append(out, lines[pos:start]...)
+		pos = start
+		var last byte
+		for oldCount > 0 || newCount > 0 || (i+1 < len(dl) && strings.HasPrefix(dl[i+1], `\`)) {
+			i++
+			if i >= len(dl) {
+				return "", fmt.Errorf("hunk %d: unexpected end of diff", hunks)
+			}
+			l := strings.TrimSuffix(dl[i], "\r")
+			op, body := byte(' '), ""
+			if l != "" {
+				op, body = l[0], l[1:]
+			}
+			switch op {
+			case ' ', '-':
+				if pos >= len(lines) || trimEOL(lines[pos]) != body {
+					return "", fmt.Errorf("hunk %d does not apply at line %d", hunks, pos+1)
+				}
+				if op == ' ' {
+					out = append(out, lines[pos])
+					newCount--
+				}
+				pos++
+				oldCount--
+			case '+':
+				out = append(out, body+eol)
+				newCount--
+			case '\\':
+				// "\ No newline at end of file" after an added line
+				if last == '+' {
+					out[len(out)-1] = trimEOL(out[len(out)-1])
+				}
+			default:
+				return "", fmt.Errorf("hunk %d: invalid line %q", hunks, l)
+			}
+			if oldCount < 0 || newCount < 0 {
+				return "", fmt.Errorf("hunk %d: line counts do not match the header", hunks)
+			}
+			last = op
+		}
+	}
+	if hunks == 0 {
+		return "", fmt.Errorf("diff has no hunks")
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, ""), nil
+}
+
+// splitLines splits text after each line ending.
+func splitLines(text string) []string {
+	starts := lineStarts(text)
+	lines := make([]string, 0, len(starts))
+	for i, s := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if s < end {
+			lines = append(lines, text[s:end])
+		}
+	}
+	return lines
+}
+
+// trimEOL removes the line ending of a line from splitLines.
+func trimEOL(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2]
+	}
+	return strings.TrimRight(line, "\r\n")
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lightdev/internal/util"
+)
+
+func TestApplyDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		diff string
+		// want is ignored if wantErr is set
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "replace line",
+			text: "a\nb\nc\n",
+			diff: "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want: "a\nB\nc\n",
+		},
+		{
+			name: "added lines keep CRLF",
+			text: "a\r\nb\r\n",
+			diff: "@@ -1,2 +1,3 @@\n a\n b\n+c\n",
+			want: "a\r\nb\r\nc\r\n",
+		},
+		{
+			name: "insert at start",
+			text: "a\n",
+			diff: "@@ -0,0 +1 @@\n+x\n",
+			want: "x\na\n",
+		},
+		{
+			name: "insert after line",
+			text: "a\nb\n",
+			diff: "@@ -1,0 +2 @@\n+x\n",
+			want: "a\nx\nb\n",
+		},
+		{
+			name: "two hunks",
+			text: "1\n2\n3\n4\n5\n6\n",
+			diff: "@@ -1 +1 @@\n-1\n+one\n@@ -6 +6 @@\n-6\n+six\n",
+			want: "one\n2\n3\n4\n5\nsix\n",
+		},
+		{
+			name: "no newline at end of file",
+			text: "a\nb",
+			diff: "@@ -2 +2 @@\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+			want: "a\nc",
+		},
+		{
+			name:    "context does not match",
+			text:    "a\nb\n",
+			diff:    "@@ -1 +1 @@\n-x\n+y\n",
+			wantErr: true,
+		},
+		{
+			name:    "no hunks",
+			text:    "a\n",
+			diff:    "just text\n",
+			wantErr: true,
+		},
+		{
+			name:    "several files",
+			text:    "a\n",
+			diff:    "--- a\n+++ b\n@@ -1 +1 @@\n-a\n+b\n--- c\n+++ d\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: true,
+		},
+		{
+			name:    "hunks out of order",
+			text:    "a\nb\n",
+			diff:    "@@ -2 +2 @@\n-b\n+B\n@@ -1 +1 @@\n-a\n+A\n",
+			wantErr: true,
+		},
+		{
+			name:    "counts do not match header",
+			text:    "a\nb\n",
+			diff:    "@@ -1 +1 @@\n-a\n-b\n+A\n",
+			wantErr: true,
+		},
+		{
+			name:    "truncated hunk",
+			text:    "a\nb\n",
+			diff:    "@@ -1,2 +1,2 @@\n a",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyDiff(tt.text, tt.diff)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applyDiff = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyDiff: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("applyDiff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	edit := func(l1, c1, l2, c2 int, text string) TextEdit {
+		return TextEdit{Start: Position{l1, c1}, End: Position{l2, c2}, Text: text}
+	}
+	const text = "héllo\nwörld\n"
+	tests := []struct {
+		name    string
+		text    string
+		edits   []TextEdit
+		want    string
+		wantErr bool
+	}{
+		{"insert", text, []TextEdit{edit(0, 0, 0, 0, "X")}, "Xhéllo\nwörld\n", false},
+		{"replace non-ASCII", text, []TextEdit{edit(0, 1, 0, 2, "e")}, "hello\nwörld\n", false},
+		{"surrogate pair", "a😀b\n", []TextEdit{edit(0, 3, 0, 4, "")}, "a😀\n", false},
+		{"join lines", text, []TextEdit{edit(0, 5, 1, 0, " ")}, "héllo wörld\n", false},
+		{"past end of line", text, []TextEdit{edit(0, 99, 0, 99, "!")}, "héllo!\nwörld\n", false},
+		{"CRLF", "a\r\nb\r\n", []TextEdit{edit(1, 0, 1, 1, "B")}, "a\r\nB\r\n", false},
+		{"unsorted", text, []TextEdit{edit(1, 0, 1, 1, "W"), edit(0, 0, 0, 1, "H")}, "Héllo\nWörld\n", false},
+		{"overlap", text, []TextEdit{edit(0, 0, 0, 3, ""), edit(0, 2, 0, 4, "")}, "", true},
+		{"line out of range", text, []TextEdit{edit(3, 0, 3, 0, "x")}, "", true},
+		{"end before start", text, []TextEdit{edit(1, 0, 0, 0, "x")}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyEdits(tt.text, tt.edits)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applyEdits = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEdits: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("applyEdits = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchFileHandler(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(target, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := util.FileVersion(fi)
+	patch := PatchFileHandler(root)
+	send := func(version string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(PatchRequest{
+			Path:        "a.txt",
+			BaseVersion: version,
+			Edits:       []TextEdit{{Start: Position{1, 0}, End: Position{1, 3}, Text: "TWO"}},
+		})
+		w := httptest.NewRecorder()
+		patch.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/patch", bytes.NewReader(body)))
+		return w
+	}
+
+	// another program changes the file after the client read it
+	if err := os.WriteFile(target, []byte("one\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := send(base)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale base: status %d, want 409", w.Code)
+	}
+	var conflict SaveConflict
+	if err := json.NewDecoder(w.Body).Decode(&conflict); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "one\n2\n" {
+		t.Errorf("conflicting patch changed the file to %q", got)
+	}
+
+	w = send(conflict.Version)
+	if w.Code != http.StatusOK {
+		t.Fatalf("current base: status %d %s", w.Code, w.Body)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "one\nTWO\n" {
+		t.Errorf("patched file holds %q", got)
+	}
+}
@@ -30,7 +30,10 @@ func FileSectionHandler(root string) http.HandlerFunc {
 		reqPath := r.URL.Query().Get("path")
 		target, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowRead(w, r, reqPath, target, false) {
 			return
 		}
 		f, err := os.Open(target)
@@ -6,14 +6,17 @@ package handlers
 
 import (
 	"archive/zip"
+	"bytes"
 	"encoding/json"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"lightdev/internal/access"
 	"lightdev/internal/util"
 )
 
@@ -29,6 +32,8 @@ type dirEntry struct {
 	Mode         string    `json:"mode"`         // Human readable mode string
 	Size         int64     `json:"size"`
 	ModTime      time.Time `json:"modTime"`
+	Access       string    `json:"access,omitempty"`     // access rule mode: read-only or deny
+	AccessRule   string    `json:"accessRule,omitempty"` // pattern of the access rule
 }
 
 // TreeHandler lists directory entries under the given path.
@@ -51,7 +56,10 @@ func TreeHandler(root string) http.HandlerFunc {
 		reqPath := r.URL.Query().Get("path")
 		target, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowRead(w, r, reqPath, target, false) {
 			return
 		}
 		fi, err := os.Stat(target)
@@ -114,12 +122,16 @@ func TreeHandler(root string) http.HandlerFunc {
 			isSymlink := e.Mode()&os.ModeSymlink != 0
 			isBroken := false
 			isExternal := false
+			// rules apply to the link and to what it points to, as reads
+			// of the entry check both
+			rule := access.Default().Lookup(abs)
 			if isSymlink {
 				if _, err := os.Stat(p); err != nil {
 					isBroken = true
 				} else {
 					// Check if external
 					if realPath, err := filepath.EvalSymlinks(p); err == nil {
+						rule = stricter(rule, access.Default().Lookup(realPath))
 						if relToRoot, err := filepath.Rel(rootAbs, realPath); err == nil {
 							// If relative path starts with "..", it's outside the root
 							if len(relToRoot) >= 2 && relToRoot[:2] == ".." {
@@ -129,12 +141,20 @@ func TreeHandler(root string) http.HandlerFunc {
 					}
 				}
 			}
+			accessMode, accessPattern := accessInfo(rule)
+			if accessMode == access.Hidden.String() {
+				continue
+			}
 
 			canRead, canWrite, canExec := resolveAccess(e)
 			isRestricted := !canRead
 			if e.IsDir() && !canExec {
 				isRestricted = true
 			}
+			if accessMode != "" {
+				canWrite = false
+				isRestricted = isRestricted || accessMode == access.Deny.String()
+			}
 
 			// Use absolute path for API to avoid ambiguity with SanitizePath strategy
 			// when root is not system root.
@@ -155,6 +175,8 @@ func TreeHandler(root string) http.HandlerFunc {
 				Mode:         e.Mode().String(),
 				Size:         e.Size(),
 				ModTime:      e.ModTime(),
+				Access:       accessMode,
+				AccessRule:   accessPattern,
 			})
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -181,7 +203,10 @@ func GetFileHandler(root string) http.HandlerFunc {
 		reqPath := r.URL.Query().Get("path")
 		target, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowRead(w, r, reqPath, target, false) {
 			return
 		}
 		fi, err := os.Stat(target)
@@ -236,6 +261,18 @@ func serveDirectoryAsZip(w http.ResponseWriter, target string) {
 		if err != nil {
 			return err
 		}
+		// symlinks are not followed: the access rules and the confinement
+		// are checked on the link, not its target
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		// leave out what the access rules keep from being read
+		if access.Default().Mode(path) >= access.Deny {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		// Create relative path for zip entry
 		relPath := path[rootLen:]
 		if len(relPath) > 0 && (relPath[0] == '/' || relPath[0] == '\\') {
@@ -268,8 +305,8 @@ func serveDirectoryAsZip(w http.ResponseWriter, target string) {
 			if err != nil {
 				return err
 			}
-			defer file.Close()
 			_, err = io.Copy(writer, file)
+			file.Close()
 			if err != nil {
 				return err
 			}
@@ -283,6 +320,9 @@ func serveDirectoryAsZip(w http.ResponseWriter, target string) {
 	}
 }
 
+// serveFile serves target with http.ServeContent, which handles Range
+// (including multipart ranges), If-Range, If-None-Match and
+// If-Modified-Since.
 func serveFile(w http.ResponseWriter, r *http.Request, target string) {
 	f, err := os.Open(target)
 	if err != nil {
@@ -294,10 +334,15 @@ func serveFile(w http.ResponseWriter, r *http.Request, target string) {
 		return
 	}
 	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "stat failed", http.StatusInternalServerError)
+		return
+	}
 
 	// detect content type from the first bytes
 	buf := make([]byte, 512)
-	n, _ := f.Read(buf)
+	n, _ := f.ReadAt(buf, 0)
 	mime := http.DetectContentType(buf[:n])
 
 	// Override mime for SVG if detected as text/plain or text/xml
@@ -305,26 +350,91 @@ func serveFile(w http.ResponseWriter, r *http.Request, target string) {
 		mime = "image/svg+xml"
 	}
 
-	w.Header().Set("Content-Type", mime)
-
 	// Check if download is requested
-	if r.URL.Query().Get("download") == "true" {
+	download := r.URL.Query().Get("download") == "true"
+	if download {
 		filename := filepath.Base(target)
 		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
 	}
 
-	// Check for UTF-8 BOM
-	startOffset := int64(0)
-	// Only strip BOM if NOT downloading (i.e. viewing in editor)
-	if r.URL.Query().Get("download") != "true" {
-		if n >= 3 && buf[0] == 0xEF && buf[1] == 0xBB && buf[2] == 0xBF {
-			startOffset = 3
+	// the version identifies the file for saves, see PostFileHandler
+	version := util.FileVersion(fi)
+	w.Header().Set("X-File-Version", version)
+	etag := version
+	var content io.ReadSeeker = f
+	// Unless downloading, text is served to the editor as UTF-8 without
+	// BOM; PostFileHandler restores the encoding and BOM on save.
+	if !download && (strings.HasPrefix(mime, "text/") || mime == "application/octet-stream") {
+		content, mime, etag = textContent(w, r, f, fi.Size(), buf[:n], mime, etag)
+	}
+
+	w.Header().Set("Content-Type", mime)
+	w.Header().Set("ETag", `"`+etag+`"`)
+	// the token may be in the URL, so only the client itself may cache,
+	// and it revalidates with the ETag
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "", fi.ModTime(), content)
+}
+
+// maxTextView is the largest file serveFile decodes for the editor.
+const maxTextView = 16 << 20
+
+// textContent returns the content, MIME type and ETag serveFile uses for
+// f, whose first bytes are head. Binary files are served as they are.
+// UTF-8 is checked while streaming and at most a BOM is stripped, so only
+// text in another encoding is read into memory to be decoded. Range
+// requests and files above maxTextView are never decoded.
+func textContent(w http.ResponseWriter, r *http.Request, f *os.File, size int64, head []byte, mime, etag string) (io.ReadSeeker, string, string) {
+	info, ok := util.DetectText(head)
+	if !ok {
+		return f, mime, etag
+	}
+	if r.Header.Get("Range") != "" || size > maxTextView {
+		setTextHeaders(w, info)
+		if info.Encoding == util.EncodingUTF8 && info.BOM {
+			return io.NewSectionReader(f, 3, size-3), mime, etag + "-nobom"
+		}
+		return f, mime, etag
+	}
+	if info.Encoding == util.EncodingUTF8 {
+		if full, ok, err := util.ScanUTF8(f); err == nil && ok {
+			setTextHeaders(w, full)
+			if full.BOM {
+				return io.NewSectionReader(f, 3, size-3), mime, etag + "-nobom"
+			}
+			return f, mime, etag
+		}
+		// not UTF-8 after all, e.g. Latin-1 further on
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, size))
+	if err != nil {
+		return f, mime, etag
+	}
+	if info, ok = util.DetectText(data); !ok {
+		return f, mime, etag
+	}
+	setTextHeaders(w, info)
+	switch {
+	case info.Encoding != util.EncodingUTF8:
+		text, err := util.DecodeText(data, info)
+		if err != nil {
+			break
 		}
+		// the decoded content is a different representation
+		return strings.NewReader(text), "text/plain; charset=utf-8", etag + "-utf8"
+	case info.BOM:
+		return bytes.NewReader(data[3:]), mime, etag + "-nobom"
 	}
+	return bytes.NewReader(data), mime, etag
+}
 
-	// rewind to start (or skip BOM)
-	if _, err := f.Seek(startOffset, 0); err == nil {
-		_, _ = io.Copy(w, f)
+// setTextHeaders reports the encoding of a text file.
+func setTextHeaders(w http.ResponseWriter, info util.TextInfo) {
+	w.Header().Set("X-File-Encoding", info.Encoding)
+	w.Header().Set("X-File-BOM", strconv.FormatBool(info.BOM))
+	if info.EOL != "" {
+		w.Header().Set("X-File-EOL", info.EOL)
 	}
 }
 
@@ -342,6 +452,10 @@ type FileStat struct {
 	IsNamedPipe   bool      `json:"isNamedPipe"`
 	IsReadOnly    bool      `json:"isReadOnly"`
 	IsRestricted  bool      `json:"isRestricted"`
+	Access        string    `json:"access,omitempty"`
+	AccessRule    string    `json:"accessRule,omitempty"`
+	// Version changes whenever the file is written, see PostFileHandler.
+	Version string `json:"version,omitempty"`
 }
 
 // StatHandler returns basic file metadata: mime, permissions, modTime
@@ -363,7 +477,10 @@ func StatHandler(root string) http.HandlerFunc {
 		reqPath := r.URL.Query().Get("path")
 		target, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowStat(w, r, reqPath, target) {
 			return
 		}
 		fi, err := os.Stat(target)
@@ -401,6 +518,11 @@ func StatHandler(root string) http.HandlerFunc {
 		if fi.IsDir() && !canExec {
 			isRestricted = true
 		}
+		accessMode, accessPattern := accessInfo(lookup(access.Default(), reqPath, target))
+		if accessMode != "" {
+			canWrite = false
+			isRestricted = isRestricted || accessMode == access.Deny.String()
+		}
 
 		resp := FileStat{
 			IsDir:         fi.IsDir(),
@@ -415,6 +537,11 @@ func StatHandler(root string) http.HandlerFunc {
 			IsNamedPipe:   mode&os.ModeNamedPipe != 0,
 			IsReadOnly:    !canWrite,
 			IsRestricted:  isRestricted,
+			Access:        accessMode,
+			AccessRule:    accessPattern,
+		}
+		if !fi.IsDir() {
+			resp.Version = util.FileVersion(fi)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,166 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"lightdev/internal/util"
+)
+
+// TestFileRoundTrip loads files in the editor's encoding and saves them
+// back unchanged, which must not change a byte.
+func TestFileRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	get := GetFileHandler(root)
+	post := PostFileHandler(root)
+	files := map[string][]byte{
+		"utf8.txt":     []byte("héllo\nwörld\n"),
+		"bom-crlf.txt": []byte("\xef\xbb\xbfa\r\nb\r\n"),
+		"utf16le.txt":  []byte("\xff\xfea\x00\r\x00\n\x00\xe9\x00"),
+		"utf16be.txt":  []byte("\xfe\xff\x00a\x00\n\x00b\xd8\x3d\xde\x00"),
+		"latin1.txt":   []byte("caf\xe9\n\xfc\n"),
+		"cp1252.txt":   []byte("\x93quote\x94 \x80\r\n"),
+		"mixed.txt":    []byte("a\nb\r\nc\rd"),
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			target := filepath.Join(root, name)
+			if err := os.WriteFile(target, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			get.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path="+name, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET: status %d", w.Code)
+			}
+			body, _ := json.Marshal(SaveRequest{Path: name, Content: w.Body.String()})
+			w = httptest.NewRecorder()
+			post.ServeHTTP(w, httptest.NewRequest("POST", "/api/file", bytes.NewReader(body)))
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("POST: status %d %s", w.Code, w.Body)
+			}
+			got, err := os.ReadFile(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("saved %q, want %q", got, data)
+			}
+		})
+	}
+}
+
+// TestConcurrentSaves sends several saves based on the same version at
+// once; only the first may be written, the others must conflict.
+func TestConcurrentSaves(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(target, []byte("base\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := util.FileVersion(fi)
+	post := PostFileHandler(root)
+
+	const n = 8
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, _ := json.Marshal(SaveRequest{Path: "a.txt", Content: fmt.Sprintf("save %d\n", i), ExpectedVersion: version})
+			w := httptest.NewRecorder()
+			post.ServeHTTP(w, httptest.NewRequest("POST", "/api/file", bytes.NewReader(body)))
+			codes <- w.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	saved := 0
+	for code := range codes {
+		switch code {
+		case http.StatusNoContent:
+			saved++
+		case http.StatusConflict:
+		default:
+			t.Errorf("status %d, want 204 or 409", code)
+		}
+	}
+	if saved != 1 {
+		t.Errorf("%d saves succeeded, want 1", saved)
+	}
+}
+
+func TestPutFile(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(target, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	put := PutFileHandler(root)
+	send := func(query, body string) int {
+		w := httptest.NewRecorder()
+		put.ServeHTTP(w, httptest.NewRequest("PUT", "/api/file?"+query, strings.NewReader(body)))
+		return w.Code
+	}
+
+	// the checksum is that of "new\n", not of the body
+	sum := sha256.Sum256([]byte("new\n"))
+	if code := send("path=a.txt&sha256="+hex.EncodeToString(sum[:]), "other\n"); code != http.StatusBadRequest {
+		t.Errorf("checksum mismatch: status %d, want 400", code)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "old\n" {
+		t.Errorf("checksum mismatch changed the file to %q", got)
+	}
+	if code := send("path=a.txt&sha256="+hex.EncodeToString(sum[:]), "new\n"); code != http.StatusNoContent {
+		t.Errorf("matching checksum: status %d, want 204", code)
+	}
+	if code := send("path=a.txt&mode=create", "x"); code != http.StatusPreconditionFailed {
+		t.Errorf("create of an existing file: status %d, want 412", code)
+	}
+
+	// of several concurrent creates only one may succeed
+	const n = 8
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes <- send("path=b.txt&mode=create", fmt.Sprintf("create %d\n", i))
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusPreconditionFailed:
+		default:
+			t.Errorf("concurrent create: status %d, want 201 or 412", code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d creates succeeded, want 1", created)
+	}
+}
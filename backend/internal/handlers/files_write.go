@@ -5,13 +5,27 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"lightdev/internal/audit"
+	"lightdev/internal/metrics"
 	"lightdev/internal/util"
 )
 
@@ -19,20 +33,122 @@ import (
 type SaveRequest struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
+	// ExpectedVersion is the version the client read (from /api/stat or
+	// the X-File-Version header of GET /api/file). If the file has changed
+	// since, the save is refused with 409. The If-Match header may be used
+	// instead.
+	ExpectedVersion string `json:"expectedVersion,omitempty"`
+	// Force overwrites the file even if the version does not match.
+	Force bool `json:"force,omitempty"`
+	// Encoding is the charset to write: utf-8, utf-16le, utf-16be,
+	// iso-8859-1 or windows-1252. Without it an existing file keeps the
+	// encoding GET /api/file decoded it from.
+	Encoding string `json:"encoding,omitempty"`
+	// BOM writes a byte order mark. Without it an existing file keeps its
+	// BOM if the encoding does not change.
+	BOM *bool `json:"bom,omitempty"`
+	// EOL converts all line breaks to lf, crlf or cr. Without it the
+	// content is written as sent.
+	EOL string `json:"eol,omitempty"`
+}
+
+// SaveConflict is returned with 409 when the file changed since it was
+// read.
+type SaveConflict struct {
+	Error string `json:"error"`
+	// Exists is false if the file was deleted.
+	Exists  bool       `json:"exists"`
+	Version string     `json:"version,omitempty"`
+	Size    int64      `json:"size"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+}
+
+// checkVersion reports whether target still has the expected version. If
+// not, it answers 409 with the current version and metadata. "*" only
+// requires the file to exist.
+func checkVersion(w http.ResponseWriter, target, expected string) bool {
+	fi, err := os.Stat(target)
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, "stat failed", http.StatusInternalServerError)
+		return false
+	}
+	conflict := SaveConflict{Error: "file was deleted since it was read"}
+	if err == nil {
+		current := util.FileVersion(fi)
+		if expected == "*" || expected == current {
+			return true
+		}
+		mt := fi.ModTime()
+		conflict = SaveConflict{Error: "file was changed since it was read", Exists: true, Version: current, Size: fi.Size(), ModTime: &mt}
+		w.Header().Set("ETag", `"`+current+`"`)
+		w.Header().Set("X-File-Version", current)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(conflict)
+	return false
+}
+
+// encodeContent returns the bytes to save for req and how they are
+// encoded. The editor works on UTF-8 without BOM (see serveFile), so
+// unless the request chooses otherwise, an existing file keeps its
+// encoding and BOM and a new one is written as UTF-8.
+func encodeContent(target string, req SaveRequest) ([]byte, util.TextInfo, error) {
+	info := util.TextInfo{Encoding: util.EncodingUTF8}
+	if cur, ok, err := util.DetectFileText(target, maxTextView); err == nil && ok {
+		info.Encoding, info.BOM = cur.Encoding, cur.BOM
+	}
+	detected := req.Encoding == ""
+	if !detected {
+		enc, err := util.NormalizeEncoding(req.Encoding)
+		if err != nil {
+			return nil, info, err
+		}
+		if enc != info.Encoding {
+			info.Encoding, info.BOM = enc, false
+		}
+	}
+	if req.BOM != nil {
+		info.BOM = *req.BOM
+	}
+	content := req.Content
+	if req.EOL != "" {
+		eol, err := util.NormalizeEOL(req.EOL)
+		if err != nil {
+			return nil, info, err
+		}
+		content = util.ConvertEOL(content, eol)
+	}
+	info.EOL = util.DetectEOL(content)
+	data, err := util.EncodeText(content, info)
+	if err != nil && detected && info.Encoding == util.EncodingLatin1 {
+		// a file without the bytes 0x80-0x9F is detected as ISO-8859-1,
+		// but may as well be Windows-1252, which has e.g. the euro sign
+		info.Encoding = util.EncodingWindows1252
+		if d, err2 := util.EncodeText(content, info); err2 == nil {
+			return d, info, nil
+		}
+	}
+	return data, info, err
 }
 
 // PostFileHandler saves content to a file, creating it if needed.
 // @Summary Save file
-// @Description Creates or overwrites a text file.
+// @Description Creates or overwrites a text file. An existing file keeps its encoding and BOM unless encoding or bom is given; eol converts line breaks. If expectedVersion (or an If-Match header) is given and the file changed since, the save is refused with 409 and the current version, unless force is set. The new version is returned in the ETag and X-File-Version headers.
 // @ID saveFile
 // @Tags file
 // @Security TokenAuth
 // @Accept json
 // @Param body body SaveRequest true "File content"
+// @Param If-Match header string false "Expected version (ETag)"
 // @Success 204
+// @Failure 400 "Unsupported encoding or character that cannot be encoded"
+// @Failure 409 {object} SaveConflict
 // @Router /api/file [post]
 func PostFileHandler(root string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileWrite)
+		defer ev.Done()
 		if util.IsBlocked() {
 			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
 			return
@@ -42,8 +158,30 @@ func PostFileHandler(root string) http.HandlerFunc {
 			http.Error(w, "invalid json", http.StatusBadRequest)
 			return
 		}
+		ev.Path = req.Path
 		target, err := util.SanitizePath(root, req.Path)
 		if err != nil {
+			pathError(w, r, req.Path, err)
+			return
+		}
+		if !allowWrite(w, r, req.Path, target, false) {
+			return
+		}
+		// hold other saves off between the version check and the write
+		defer lockPath(target)()
+		expected := util.ParseVersion(req.ExpectedVersion)
+		if expected == "" {
+			expected = util.ParseVersion(r.Header.Get("If-Match"))
+		}
+		if req.Force {
+			ev.Detail = "forced"
+		} else if expected != "" && !checkVersion(w, target, expected) {
+			ev.Detail = "version conflict"
+			return
+		}
+		data, info, err := encodeContent(target, req)
+		if err != nil {
+			ev.Detail = "encoding"
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -51,28 +189,257 @@ func PostFileHandler(root string) http.HandlerFunc {
 			http.Error(w, "mkdir failed", http.StatusInternalServerError)
 			return
 		}
-		if err := os.WriteFile(target, []byte(req.Content), 0644); err != nil {
+		if err := util.WriteFileAtomic(target, data, 0644); err != nil {
 			http.Error(w, "write failed", http.StatusInternalServerError)
 			return
 		}
+		ev.Bytes = int64(len(data))
+		setTextHeaders(w, info)
+		if fi, err := os.Stat(target); err == nil {
+			version := util.FileVersion(fi)
+			w.Header().Set("ETag", `"`+version+`"`)
+			w.Header().Set("X-File-Version", version)
+		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// putOptions are the query parameters and headers of PutFileHandler.
+type putOptions struct {
+	// mode is "create", "overwrite" or empty
+	mode   string
+	sha256 string
+	md5    []byte
+	// perm is set if hasPerm
+	perm    os.FileMode
+	hasPerm bool
+	mtime   time.Time
+}
+
+func parsePutOptions(r *http.Request) (putOptions, error) {
+	var o putOptions
+	q := r.URL.Query()
+	switch o.mode = q.Get("mode"); o.mode {
+	case "", "create", "overwrite":
+	default:
+		return o, fmt.Errorf("invalid mode %q (use create or overwrite)", o.mode)
+	}
+	if r.Header.Get("If-None-Match") == "*" {
+		o.mode = "create"
+	}
+	o.sha256 = q.Get("sha256")
+	if o.sha256 == "" {
+		o.sha256 = r.Header.Get("X-Checksum-SHA256")
+	}
+	o.sha256 = strings.ToLower(strings.TrimSpace(o.sha256))
+	if o.sha256 != "" && !validSHA256(o.sha256) {
+		return o, fmt.Errorf("invalid sha256 checksum")
+	}
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil || len(b) != md5.Size {
+			return o, fmt.Errorf("invalid Content-MD5")
+		}
+		o.md5 = b
+	}
+	if v := r.Header.Get("X-File-Mode"); v != "" {
+		m, err := strconv.ParseUint(strings.TrimSpace(v), 8, 32)
+		if err != nil || m > 0777 {
+			return o, fmt.Errorf("invalid X-File-Mode %q (use octal permissions, e.g. 0644)", v)
+		}
+		o.perm, o.hasPerm = os.FileMode(m), true
+	}
+	if v := strings.TrimSpace(r.Header.Get("X-File-Mtime")); v != "" {
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+			o.mtime = time.Unix(sec, 0)
+		} else if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			o.mtime = t
+		} else {
+			return o, fmt.Errorf("invalid X-File-Mtime %q (use RFC 3339 or Unix seconds)", v)
+		}
+	}
+	return o, nil
+}
+
+// putPreconditions checks mode and the expected version of PutFileHandler
+// against the current state of target and reports whether it exists. If a
+// check fails it answers the request and returns the status sent, else 0.
+func putPreconditions(w http.ResponseWriter, target, mode, expected string) (exists bool, status int) {
+	fi, err := os.Stat(target)
+	exists = err == nil
+	switch {
+	case exists && fi.IsDir():
+		http.Error(w, "is a directory", http.StatusBadRequest)
+		return exists, http.StatusBadRequest
+	case mode == "create" && exists:
+		http.Error(w, "file already exists", http.StatusPreconditionFailed)
+		return exists, http.StatusPreconditionFailed
+	case mode == "overwrite" && !exists:
+		http.Error(w, "not found", http.StatusNotFound)
+		return exists, http.StatusNotFound
+	}
+	if expected != "" && !checkVersion(w, target, expected) {
+		return exists, http.StatusConflict
+	}
+	return exists, 0
+}
+
+// PutFileHandler streams the raw request body to a file, creating it if
+// needed. Unlike PostFileHandler it can write any bytes and does not hold
+// the content in memory.
+// @Summary Write raw file content
+// @Description Streams the request body into the file atomically. mode=create (or If-None-Match: *) refuses to replace an existing file, mode=overwrite refuses to create one. If a sha256 or Content-MD5 checksum is given and does not match, nothing is written. X-File-Mode and X-File-Mtime set the permissions and modification time. Returns 201 if the file was created and 204 if it was replaced, with the new version in ETag and X-File-Version and the SHA-256 of the content in X-Checksum-SHA256.
+// @ID putFile
+// @Tags file
+// @Security TokenAuth
+// @Accept application/octet-stream
+// @Param path query string true "File path"
+// @Param mode query string false "create or overwrite"
+// @Param sha256 query string false "SHA-256 of the body (hex), also as X-Checksum-SHA256 header"
+// @Param Content-MD5 header string false "MD5 of the body (base64)"
+// @Param X-File-Mode header string false "Permissions (octal, e.g. 0755)"
+// @Param X-File-Mtime header string false "Modification time (RFC 3339 or Unix seconds)"
+// @Param If-Match header string false "Expected version (ETag)"
+// @Success 201
+// @Success 204
+// @Failure 400 "Invalid parameter or checksum mismatch"
+// @Failure 404 "mode=overwrite and the file does not exist"
+// @Failure 409 {object} SaveConflict
+// @Failure 412 "mode=create and the file exists"
+// @Router /api/file [put]
+func PutFileHandler(root string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileWrite)
+		defer ev.Done()
+		if util.IsBlocked() {
+			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		reqPath := r.URL.Query().Get("path")
+		ev.Path = reqPath
+		target, err := util.SanitizePath(root, reqPath)
+		if err != nil {
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowWrite(w, r, reqPath, target, false) {
+			return
+		}
+		opts, err := parsePutOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the preconditions are checked before the body is received and
+		// again, under the path lock, before the file is replaced
+		expected := util.ParseVersion(r.Header.Get("If-Match"))
+		exists, status := putPreconditions(w, target, opts.mode, expected)
+		if status == http.StatusConflict {
+			ev.Detail = "version conflict"
+		}
+		if status != 0 {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			http.Error(w, "mkdir failed", http.StatusInternalServerError)
+			return
+		}
+		perm := os.FileMode(0644)
+		if opts.hasPerm {
+			perm = opts.perm
+		}
+		f, err := util.CreateAtomic(target, perm)
+		if err != nil {
+			http.Error(w, "write failed", http.StatusInternalServerError)
+			return
+		}
+		defer f.Abort()
+		sum, md := sha256.New(), md5.New()
+		n, err := io.Copy(io.MultiWriter(f, sum, md), r.Body)
+		ev.Bytes = n
+		if err != nil {
+			http.Error(w, "write failed", http.StatusInternalServerError)
+			return
+		}
+		got := hex.EncodeToString(sum.Sum(nil))
+		if opts.sha256 != "" && got != opts.sha256 {
+			ev.Detail = "checksum mismatch"
+			http.Error(w, "sha256 checksum mismatch", http.StatusBadRequest)
+			return
+		}
+		if opts.md5 != nil && !bytes.Equal(md.Sum(nil), opts.md5) {
+			ev.Detail = "checksum mismatch"
+			http.Error(w, "Content-MD5 mismatch", http.StatusBadRequest)
+			return
+		}
+		// the data is complete and verified; nothing touched the file so far
+		defer lockPath(target)()
+		if opts.mode == "create" {
+			if err := f.CommitNew(); err != nil {
+				if errors.Is(err, fs.ErrExist) {
+					http.Error(w, "file already exists", http.StatusPreconditionFailed)
+					return
+				}
+				http.Error(w, "write failed", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			if exists, status = putPreconditions(w, target, opts.mode, expected); status != 0 {
+				if status == http.StatusConflict {
+					ev.Detail = "version conflict"
+				}
+				return
+			}
+			if err := f.Commit(); err != nil {
+				http.Error(w, "write failed", http.StatusInternalServerError)
+				return
+			}
+		}
+		// Commit keeps the permissions of a replaced file
+		if opts.hasPerm {
+			if err := os.Chmod(target, opts.perm); err != nil {
+				log.Printf("[WARNING] cannot set mode of %s: %v", target, err)
+			}
+		}
+		if !opts.mtime.IsZero() {
+			if err := os.Chtimes(target, opts.mtime, opts.mtime); err != nil {
+				log.Printf("[WARNING] cannot set modification time of %s: %v", target, err)
+			}
+		}
+		if fi, err := os.Stat(target); err == nil {
+			version := util.FileVersion(fi)
+			w.Header().Set("ETag", `"`+version+`"`)
+			w.Header().Set("X-File-Version", version)
+		}
+		w.Header().Set("X-Checksum-SHA256", got)
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}
+}
+
 // UploadHandler accepts multipart form file uploads and writes them into the
 // target directory specified by the `path` query parameter (relative to root).
+// Large files should use the resumable uploads of UploadsHandler instead.
 // @Summary Upload files
-// @Description Upload one or more files via multipart/form-data.
+// @Description Upload one or more files via multipart/form-data. Returns a result per file; the status is 207 if some files failed and 500 if all did.
 // @ID uploadFiles
 // @Tags file
 // @Security TokenAuth
 // @Accept multipart/form-data
+// @Produce json
 // @Param path query string false "Target directory"
 // @Param file formData file true "Files to upload"
-// @Success 204
+// @Success 200 {array} UploadResult
+// @Success 207 {array} UploadResult
+// @Failure 500 {array} UploadResult
 // @Router /api/upload [post]
 func UploadHandler(root string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileUpload)
+		defer ev.Done()
 		if util.IsBlocked() {
 			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
 			return
@@ -83,43 +450,90 @@ func UploadHandler(root string) http.HandlerFunc {
 			return
 		}
 		reqPath := r.URL.Query().Get("path")
+		ev.Path = reqPath
 		targetDir, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
 			return
 		}
+		if !allowWrite(w, r, reqPath, targetDir, false) {
+			return
+		}
+		// refuse the whole upload if any file would break an access rule
+		files := r.MultipartForm.File
+		for _, fhs := range files {
+			for _, fh := range fhs {
+				dst := filepath.Join(targetDir, filepath.Base(fh.Filename))
+				if !allowWrite(w, r, filepath.Join(reqPath, filepath.Base(fh.Filename)), dst, false) {
+					return
+				}
+			}
+		}
 		// ensure directory exists
 		if err := os.MkdirAll(targetDir, 0755); err != nil {
 			http.Error(w, "failed to create dir", http.StatusInternalServerError)
 			return
 		}
 		// iterate uploaded files (form field may be 'file' or multiple)
-		files := r.MultipartForm.File
+		results := []UploadResult{}
+		var names []string
+		failed := 0
 		for _, fhs := range files {
 			for _, fh := range fhs {
-				in, err := fh.Open()
-				if err != nil {
-					continue
-				}
-				defer in.Close()
-				dstPath := filepath.Join(targetDir, filepath.Base(fh.Filename))
-				out, err := os.Create(dstPath)
+				name := filepath.Base(fh.Filename)
+				res := UploadResult{Name: name, Path: filepath.ToSlash(filepath.Join(reqPath, name))}
+				n, err := saveUploadedFile(root, filepath.Join(targetDir, name), fh)
+				res.Bytes = n
+				ev.Bytes += n
+				metrics.UploadBytes.Add(uint64(n))
 				if err != nil {
-					in.Close()
-					continue
-				}
-				if _, err := io.Copy(out, in); err != nil {
-					out.Close()
-					in.Close()
-					continue
+					res.Error = err.Error()
+					failed++
+				} else {
+					names = append(names, name)
 				}
-				out.Close()
+				results = append(results, res)
 			}
 		}
-		w.WriteHeader(http.StatusNoContent)
+		ev.Detail = strings.Join(names, ", ")
+		status := http.StatusOK
+		if failed > 0 {
+			ev.Detail += fmt.Sprintf(" (%d failed)", failed)
+			status = http.StatusMultiStatus
+			if failed == len(results) {
+				status = http.StatusInternalServerError
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(results)
 	}
 }
 
+// saveUploadedFile writes the multipart file fh to dst.
+func saveUploadedFile(root, dst string, fh *multipart.FileHeader) (int64, error) {
+	in, err := fh.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	// the name could be an existing symlink leading out of root
+	dstPath, err := util.SanitizePath(root, dst)
+	if err != nil {
+		return 0, err
+	}
+	out, err := util.CreateAtomic(dstPath, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Abort()
+	n, err := io.Copy(out, in)
+	if err != nil {
+		return n, err
+	}
+	return n, out.Commit()
+}
+
 // DeleteFileHandler deletes a file at path (moves to .trash for safety).
 // @Summary Delete file
 // @Description Moves a file to trash.
@@ -132,6 +546,8 @@ func UploadHandler(root string) http.HandlerFunc {
 // @Router /api/file [delete]
 func DeleteFileHandler(root string, trashDir string, allowDelete bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileDelete)
+		defer ev.Done()
 		if !allowDelete {
 			http.Error(w, "deletion is disabled", http.StatusForbidden)
 			return
@@ -141,9 +557,13 @@ func DeleteFileHandler(root string, trashDir string, allowDelete bool) http.Hand
 			return
 		}
 		reqPath := r.URL.Query().Get("path")
+		ev.Path = reqPath
 		target, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowWrite(w, r, reqPath, target, true) {
 			return
 		}
 		// Move to trash
@@ -157,25 +577,13 @@ func DeleteFileHandler(root string, trashDir string, allowDelete bool) http.Hand
 		}
 		// attempt rename first
 		if err := os.Rename(target, dest); err != nil {
-			// fallback to copy
-			in, err := os.Open(target)
-			if err != nil {
-				http.Error(w, "move failed", http.StatusInternalServerError)
-				return
-			}
-			defer in.Close()
-			out, err := os.Create(dest)
-			if err != nil {
-				http.Error(w, "move failed", http.StatusInternalServerError)
-				return
-			}
-			defer out.Close()
-			if _, err := io.Copy(out, in); err != nil {
+			// fallback to copy, e.g. if the trash is on another filesystem
+			if err := util.CopyRecursive(target, dest); err != nil {
 				http.Error(w, "move failed", http.StatusInternalServerError)
 				return
 			}
 			// remove original
-			if err := os.Remove(target); err != nil {
+			if err := os.RemoveAll(target); err != nil {
 				// If we fail to remove the original, the delete is incomplete.
 				// For the user, the file is still there.
 				if os.IsPermission(err) {
@@ -188,6 +596,7 @@ func DeleteFileHandler(root string, trashDir string, allowDelete bool) http.Hand
 		}
 		// Record deletion
 		RecordTrash(reqPath, dest)
+		ev.Target = dest
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -210,6 +619,8 @@ type RenameRequest struct {
 // @Router /api/rename [post]
 func RenameFileHandler(root string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileRename)
+		defer ev.Done()
 		if util.IsBlocked() {
 			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
 			return
@@ -219,16 +630,23 @@ func RenameFileHandler(root string) http.HandlerFunc {
 			http.Error(w, "invalid json", http.StatusBadRequest)
 			return
 		}
+		ev.Path, ev.Target = req.OldPath, req.NewPath
 
 		oldTarget, err := util.SanitizePath(root, req.OldPath)
 		if err != nil {
-			http.Error(w, "invalid old path: "+err.Error(), http.StatusBadRequest)
+			pathError(w, r, req.OldPath, err)
+			return
+		}
+		if !allowWrite(w, r, req.OldPath, oldTarget, true) {
 			return
 		}
 
 		newTarget, err := util.SanitizePath(root, req.NewPath)
 		if err != nil {
-			http.Error(w, "invalid new path: "+err.Error(), http.StatusBadRequest)
+			pathError(w, r, req.NewPath, err)
+			return
+		}
+		if !allowWrite(w, r, req.NewPath, newTarget, false) {
 			return
 		}
 
@@ -283,6 +701,8 @@ type CopyRequest struct {
 // @Router /api/copy [post]
 func CopyFileHandler(root string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionFileCopy)
+		defer ev.Done()
 		if util.IsBlocked() {
 			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
 			return
@@ -292,16 +712,23 @@ func CopyFileHandler(root string) http.HandlerFunc {
 			http.Error(w, "invalid json", http.StatusBadRequest)
 			return
 		}
+		ev.Path, ev.Target = req.OldPath, req.NewPath
 
 		oldTarget, err := util.SanitizePath(root, req.OldPath)
 		if err != nil {
-			http.Error(w, "invalid old path: "+err.Error(), http.StatusBadRequest)
+			pathError(w, r, req.OldPath, err)
+			return
+		}
+		if !allowRead(w, r, req.OldPath, oldTarget, true) {
 			return
 		}
 
 		newTarget, err := util.SanitizePath(root, req.NewPath)
 		if err != nil {
-			http.Error(w, "invalid new path: "+err.Error(), http.StatusBadRequest)
+			pathError(w, r, req.NewPath, err)
+			return
+		}
+		if !allowWrite(w, r, req.NewPath, newTarget, false) {
 			return
 		}
 
@@ -332,8 +759,12 @@ func CopyFileHandler(root string) http.HandlerFunc {
 			return
 		}
 		defer src.Close()
+		perm := os.FileMode(0666)
+		if fi, err := src.Stat(); err == nil {
+			perm = fi.Mode().Perm()
+		}
 
-		dst, err := os.Create(newTarget)
+		dst, err := util.CreateAtomic(newTarget, perm)
 		if err != nil {
 			if os.IsPermission(err) {
 				http.Error(w, "permission denied", http.StatusForbidden)
@@ -342,9 +773,14 @@ func CopyFileHandler(root string) http.HandlerFunc {
 			http.Error(w, "failed to create destination: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		defer dst.Close()
+		defer dst.Abort()
 
-		if _, err := io.Copy(dst, src); err != nil {
+		n, err := io.Copy(dst, src)
+		ev.Bytes = n
+		if err == nil {
+			err = dst.Commit()
+		}
+		if err != nil {
 			http.Error(w, "failed to copy content: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
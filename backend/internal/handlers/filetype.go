@@ -20,12 +20,17 @@ type fileTypeResp struct {
 	Mime   string `json:"mime"`
 	IsText bool   `json:"isText"`
 	Ext    string `json:"ext"`
+	// Encoding, BOM and EOL are set for text files, see util.TextInfo.
+	Encoding string `json:"encoding,omitempty"`
+	BOM      bool   `json:"bom,omitempty"`
+	EOL      string `json:"eol,omitempty"`
 }
 
 // FileTypeHandler inspects the file bytes to determine a mime type and
-// whether the file is likely text. It returns JSON with {mime,isText,ext}.
+// whether the file is likely text. It returns JSON with {mime,isText,ext}
+// and, for text, the encoding, BOM and line ending style.
 // @Summary Detect file type
-// @Description Returns MIME type and text/binary classification.
+// @Description Returns MIME type and text/binary classification. For text files also the charset (utf-8, utf-16le, utf-16be, iso-8859-1 or windows-1252), whether there is a BOM, and the line ending style (lf, crlf, cr or mixed).
 // @ID detectFileType
 // @Tags file
 // @Security TokenAuth
@@ -42,7 +47,10 @@ func FileTypeHandler(root string) http.HandlerFunc {
 		reqPath := r.URL.Query().Get("path")
 		target, err := util.SanitizePath(root, reqPath)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowRead(w, r, reqPath, target, false) {
 			return
 		}
 		fi, err := os.Stat(target)
@@ -82,7 +90,16 @@ func FileTypeHandler(root string) http.HandlerFunc {
 			}
 		}
 
+		resp := fileTypeResp{Mime: mimeType, IsText: isText, Ext: ext}
+		if isText || mimeType == "application/octet-stream" {
+			// UTF-16 without BOM is only recognised here
+			if info, ok, err := util.DetectFileText(target, maxTextView); err == nil && ok {
+				resp.IsText = true
+				resp.Encoding, resp.BOM, resp.EOL = info.Encoding, info.BOM, info.EOL
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(fileTypeResp{Mime: mimeType, IsText: isText, Ext: ext})
+		_ = json.NewEncoder(w).Encode(resp)
 	}
 }
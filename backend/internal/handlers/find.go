@@ -0,0 +1,87 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"lightdev/internal/index"
+	"lightdev/internal/util"
+)
+
+type findResp struct {
+	Results []index.Result `json:"results"`
+	index.Stats
+}
+
+// FindHandler finds files by name using the path index.
+// @Summary Find files by name
+// @Description Fuzzy-matches q against the paths below the root, e.g. for quick-open. Consecutive characters, the file name, path segment and camelCase starts and recently modified files score higher. The index is built on first use and kept current by filesystem events; dot-directories and paths excluded by .gitignore files are not indexed. Without q the most recently modified files are returned.
+// @ID findFiles
+// @Tags file
+// @Security TokenAuth
+// @Param q query string false "Text to match"
+// @Param path query string false "Directory to restrict the results to"
+// @Param dirs query bool false "Include directories"
+// @Param limit query int false "Maximum results (default 50, at most 500)"
+// @Produce json
+// @Success 200 {object} findResp
+// @Router /api/find [get]
+func FindHandler(root string, idx *index.Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if util.IsBlocked() {
+			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		query := index.Query{Text: q.Get("q"), Dirs: queryBool(q.Get("dirs"))}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			query.Limit = n
+		}
+		if reqPath := q.Get("path"); reqPath != "" {
+			target, err := util.SanitizePath(root, reqPath)
+			if err != nil {
+				pathError(w, r, reqPath, err)
+				return
+			}
+			if !allowRead(w, r, reqPath, target, false) {
+				return
+			}
+			rel, err := filepath.Rel(resolvedRoot(root), target)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				http.Error(w, "path is not below the root", http.StatusBadRequest)
+				return
+			}
+			query.Dir = rel
+		}
+		results, stats := idx.Find(query)
+		for i := range results {
+			results[i].Path = apiPath(results[i].Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(findResp{Results: results, Stats: stats})
+	}
+}
+
+// resolvedRoot returns root as SanitizePath resolves paths, absolute and
+// with symlinks followed.
+func resolvedRoot(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return root
+}
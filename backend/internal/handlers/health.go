@@ -35,10 +35,15 @@ type healthInfo struct {
 	StartTime    string  `json:"start_time,omitempty"`
 	PasswordAuth bool    `json:"password_auth"`
 	AuthRequired bool    `json:"auth_required"`
+	ReadOnly     bool    `json:"read_only"`
+	LockedHosts  int     `json:"login_locked_hosts"`
 	HomeDir      string  `json:"home_dir,omitempty"`
 }
 
-// Health returns a handler that serves health info.
+// Health returns a handler that serves health info. passwordAuth reports
+// whether password login is configured, lockedHosts how many remote hosts
+// are currently locked out of it, readOnly whether the server currently
+// refuses changes. They are called for every request.
 // @Summary Get system health
 // @Description Returns the status of the server and basic system metrics.
 // @ID getHealth
@@ -46,13 +51,21 @@ type healthInfo struct {
 // @Produce json
 // @Success 200 {object} healthInfo
 // @Router /health [get]
-func Health(passwordAuth bool, authRequired bool) http.HandlerFunc {
+func Health(passwordAuth func() bool, authRequired bool, lockedHosts func() int, readOnly func() bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var info healthInfo
 		info.Status = "ok"
 		info.Version = BackendVersion
-		info.PasswordAuth = passwordAuth
+		if passwordAuth != nil {
+			info.PasswordAuth = passwordAuth()
+		}
 		info.AuthRequired = authRequired
+		if lockedHosts != nil {
+			info.LockedHosts = lockedHosts()
+		}
+		if readOnly != nil {
+			info.ReadOnly = readOnly()
+		}
 		info.PID = os.Getpid()
 		info.StartTime = startTime.Format("2006-01-02 15:04:05")
 		if hn, err := os.Hostname(); err == nil {
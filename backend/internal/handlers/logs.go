@@ -1,62 +1,59 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/logging"
 )
 
 // @Summary Get system logs
-// @Description Returns the last 50KB of the application log.
+// @Description Returns the last 50KB of the application log. level (debug, info, warn, error) and q (substring) filter the lines. With follow=1 the response is an event stream: the last tail lines (default 100) are sent first, then new lines as they are logged, each as a JSON object with time, level and message.
 // @ID getLogs
 // @Tags system
 // @Security TokenAuth
 // @Produce text/plain
+// @Produce text/event-stream
+// @Param follow query bool false "Stream new lines (SSE)"
+// @Param level query string false "Minimum level"
+// @Param q query string false "Only lines containing this text (case-insensitive)"
+// @Param tail query int false "Lines replayed before following"
 // @Success 200 {string} string "Log content"
 // @Failure 404 "Log file not found"
 // @Router /api/logs [get]
-func LogsHandler() http.HandlerFunc {
+func LogsHandler(logFile string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Determine log file path
-		// We assume standard deployment location: ~/.mlcremote/current.log
-		home, err := os.UserHomeDir()
-		if err != nil {
-			http.Error(w, "Could not determine home directory", http.StatusInternalServerError)
+		q := r.URL.Query()
+		filter := logging.Filter{Contains: q.Get("q")}
+		if v := q.Get("level"); v != "" {
+			level, err := logging.ParseLevel(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.Level = level
+		}
+		if q.Get("follow") == "1" || q.Get("follow") == "true" {
+			followLogs(w, r, filter)
 			return
 		}
 
-		// Search for latest session-*.log file as optional secondary source
-		logDir := filepath.Join(home, ".mlcremote")
-		logPath := filepath.Join(logDir, "current.log")
-
-		// If current.log doesn't exist, look for session-*.log
-		if _, err := os.Stat(logPath); os.IsNotExist(err) {
-			entries, err := os.ReadDir(logDir)
-			if err == nil {
-				var newestFile string
-				var newestTime int64
-
-				for _, entry := range entries {
-					if entry.IsDir() {
-						continue
-					}
-					name := entry.Name()
-					// Match session-*.log
-					if len(name) > 12 && name[0:8] == "session-" && name[len(name)-4:] == ".log" {
-						info, err := entry.Info()
-						if err == nil {
-							if info.ModTime().Unix() > newestTime {
-								newestTime = info.ModTime().Unix()
-								newestFile = name
-							}
-						}
-					}
-				}
-				if newestFile != "" {
-					logPath = filepath.Join(logDir, newestFile)
-				}
+		logPath := logFile
+		if logPath == "" {
+			var err error
+			if logPath, err = legacyLogPath(); err != nil {
+				http.Error(w, "Could not determine home directory", http.StatusInternalServerError)
+				return
 			}
 		}
 
@@ -88,6 +85,168 @@ func LogsHandler() http.HandlerFunc {
 		}
 
 		w.Header().Set("Content-Type", "text/plain")
-		io.Copy(w, f)
+		if filter == (logging.Filter{}) {
+			io.Copy(w, f)
+			return
+		}
+		var buf bytes.Buffer
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := sc.Text()
+			if filter.Match(logging.ParseLine(line)) {
+				buf.WriteString(line)
+				buf.WriteByte('\n')
+			}
+		}
+		w.Write(buf.Bytes())
+	}
+}
+
+// legacyLogPath returns the log written by the deployment script:
+// ~/.mlcremote/current.log, or the newest session-*.log.
+func legacyLogPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Search for latest session-*.log file as optional secondary source
+	logDir := filepath.Join(home, ".mlcremote")
+	logPath := filepath.Join(logDir, "current.log")
+
+	// If current.log doesn't exist, look for session-*.log
+	if _, err := os.Stat(logPath); os.IsNotExist(err) {
+		entries, err := os.ReadDir(logDir)
+		if err == nil {
+			var newestFile string
+			var newestTime int64
+
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				name := entry.Name()
+				// Match session-*.log
+				if len(name) > 12 && name[0:8] == "session-" && name[len(name)-4:] == ".log" {
+					info, err := entry.Info()
+					if err == nil {
+						if info.ModTime().Unix() > newestTime {
+							newestTime = info.ModTime().Unix()
+							newestFile = name
+						}
+					}
+				}
+			}
+			if newestFile != "" {
+				logPath = filepath.Join(logDir, newestFile)
+			}
+		}
+	}
+	return logPath, nil
+}
+
+// followLogs streams log lines matching filter as server-sent events.
+func followLogs(w http.ResponseWriter, r *http.Request, filter logging.Filter) {
+	lg := logging.Default()
+	if lg == nil {
+		http.Error(w, "log streaming not available", http.StatusNotImplemented)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	tail := 100
+	if v := r.URL.Query().Get("tail"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			tail = n
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// subscribe before replaying so no line falls in between
+	ch := lg.Follow(filter)
+	defer lg.Unfollow(ch)
+
+	send := func(l logging.Line) {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	for _, l := range lg.Recent(tail, filter) {
+		send(l)
+	}
+	flusher.Flush()
+
+	// keep proxies from closing an idle stream
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case l := <-ch:
+			send(l)
+			flusher.Flush()
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// LogLevelHandler reads or changes the log level at runtime.
+// @Summary Get or set the log level
+// @Description GET returns the current log level. PUT changes it until the agent restarts. Requires the admin scope.
+// @ID logLevel
+// @Tags system
+// @Security TokenAuth
+// @Accept json
+// @Produce json
+// @Param body body logLevelRequest false "New level (PUT)"
+// @Success 200 {object} logLevelRequest
+// @Failure 400
+// @Router /api/logs/level [get]
+// @Router /api/logs/level [put]
+func LogLevelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lg := logging.Default()
+		if lg == nil {
+			http.Error(w, "log levels not available", http.StatusNotImplemented)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req logLevelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid json", http.StatusBadRequest)
+				return
+			}
+			level, err := logging.ParseLevel(req.Level)
+			if err != nil || strings.TrimSpace(req.Level) == "" {
+				http.Error(w, "level must be debug, info, warn or error", http.StatusBadRequest)
+				return
+			}
+			lg.SetLevel(level)
+			ev := audit.FromRequest(r, audit.ActionSettingsChange)
+			ev.Detail = "log_level=" + level.String()
+			audit.Record(ev)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(logLevelRequest{Level: lg.Level().String()})
 	}
 }
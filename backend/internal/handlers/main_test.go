@@ -0,0 +1,28 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lightdev/internal/audit"
+)
+
+// TestMain keeps the audit events of handler tests out of the user's
+// home directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "handlers-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	audit.SetDefault(audit.New(filepath.Join(dir, "audit.jsonl"), 0, 0))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import "sync"
+
+// pathLocks serializes the writes of this agent to a file, so that a
+// version check and the write it guards cannot interleave with another
+// save. Writers outside the agent are not held off; for them the version
+// check remains best effort.
+var pathLocks = struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}{locks: make(map[string]*pathLock)}
+
+type pathLock struct {
+	mu sync.Mutex
+	// refs counts the holders and waiters, the entry is dropped at zero
+	refs int
+}
+
+// lockPath locks the resolved path name and returns the function that
+// unlocks it.
+func lockPath(name string) (unlock func()) {
+	pathLocks.mu.Lock()
+	l := pathLocks.locks[name]
+	if l == nil {
+		l = &pathLock{}
+		pathLocks.locks[name] = l
+	}
+	l.refs++
+	pathLocks.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		pathLocks.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(pathLocks.locks, name)
+		}
+		pathLocks.mu.Unlock()
+	}
+}
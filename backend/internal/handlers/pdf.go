@@ -27,7 +27,10 @@ func PdfRenderHandler(root string) http.HandlerFunc {
         }
         target, err := util.SanitizePath(root, reqPath)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
+            pathError(w, r, reqPath, err)
+            return
+        }
+        if !allowRead(w, r, reqPath, target, false) {
             return
         }
         fi, err := os.Stat(target)
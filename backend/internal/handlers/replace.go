@@ -0,0 +1,317 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"lightdev/internal/access"
+	"lightdev/internal/audit"
+	"lightdev/internal/search"
+	"lightdev/internal/util"
+)
+
+// ReplaceRequest represents a POST /api/replace/preview or
+// /api/replace/apply body.
+type ReplaceRequest struct {
+	// Path is the directory to search (default: root).
+	Path string `json:"path"`
+	// Query is the text or regular expression to replace.
+	Query string `json:"query"`
+	// Replace is the replacement; with regex, $1 or ${name} refer to
+	// capture groups.
+	Replace       string   `json:"replace"`
+	Regex         bool     `json:"regex"`
+	CaseSensitive bool     `json:"case"`
+	WholeWord     bool     `json:"word"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	NoIgnore      bool     `json:"noIgnore"`
+	Hidden        bool     `json:"hidden"`
+	MaxResults    int      `json:"maxResults,omitempty"`
+	MaxPerFile    int      `json:"maxPerFile,omitempty"`
+	// Files selects what apply changes.
+	Files []ReplaceFile `json:"files,omitempty"`
+}
+
+// ReplaceFile selects the matches to replace in one file.
+type ReplaceFile struct {
+	Path string `json:"path"`
+	// Version is the version the preview was made from; the file is not
+	// changed if it differs.
+	Version string `json:"version"`
+	// Matches are the indexes of the matches to replace; all if omitted.
+	Matches []int `json:"matches,omitempty"`
+}
+
+// ReplacePreview is the response of POST /api/replace/preview.
+type ReplacePreview struct {
+	Files   []search.FileReplacements `json:"files"`
+	Summary search.Summary            `json:"summary"`
+}
+
+// ReplaceResult is the outcome of a replacement in one file.
+type ReplaceResult struct {
+	Path     string `json:"path"`
+	Replaced int    `json:"replaced"`
+	// Version is the version after the replacement.
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// CurrentVersion is set if the file changed since the preview.
+	CurrentVersion string `json:"currentVersion,omitempty"`
+}
+
+// options returns the search options of req.
+func (req *ReplaceRequest) options() search.Options {
+	return search.Options{
+		Query:         req.Query,
+		Regex:         req.Regex,
+		CaseSensitive: req.CaseSensitive,
+		WholeWord:     req.WholeWord,
+		Include:       req.Include,
+		Exclude:       req.Exclude,
+		NoIgnore:      req.NoIgnore,
+		Hidden:        req.Hidden,
+		MaxResults:    req.MaxResults,
+		MaxPerFile:    req.MaxPerFile,
+	}
+}
+
+// decodeReplaceRequest reads the body of a replace request.
+func decodeReplaceRequest(w http.ResponseWriter, r *http.Request) (*ReplaceRequest, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if util.IsBlocked() {
+		http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	var req ReplaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.MaxResults < 0 || req.MaxPerFile < 0 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// ReplacePreviewHandler shows what a search-and-replace would change.
+// @Summary Preview search and replace
+// @Description Searches the text files below path like GET /api/search and lists, per file, the matches with the line before and after replacing each one. Each file carries its version and each match an index, which POST /api/replace/apply takes to replace a selection. Nothing is written.
+// @ID previewReplace
+// @Tags file
+// @Security TokenAuth
+// @Accept json
+// @Produce json
+// @Param body body ReplaceRequest true "Query and replacement"
+// @Success 200 {object} ReplacePreview
+// @Failure 400 "Missing query or invalid regular expression"
+// @Router /api/replace/preview [post]
+func ReplacePreviewHandler(root string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeReplaceRequest(w, r)
+		if !ok {
+			return
+		}
+		target, err := util.SanitizePath(root, req.Path)
+		if err != nil {
+			pathError(w, r, req.Path, err)
+			return
+		}
+		if !allowRead(w, r, req.Path, target, false) {
+			return
+		}
+		if fi, err := os.Stat(target); err != nil || !fi.IsDir() {
+			http.Error(w, "not a directory", http.StatusBadRequest)
+			return
+		}
+		resp := ReplacePreview{Files: []search.FileReplacements{}}
+		resp.Summary, err = search.Preview(r.Context(), target, req.options(), req.Replace, func(f search.FileReplacements) error {
+			f.Path = apiPath(f.Path)
+			resp.Files = append(resp.Files, f)
+			return nil
+		})
+		if r.Context().Err() != nil {
+			log.Printf("[DEBUG] replace preview for %q in %s cancelled", req.Query, target)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// ReplaceApplyHandler replaces the selected matches in the selected files.
+// @Summary Apply search and replace
+// @Description Replaces matches of the query in the listed files, all of them or those whose indexes (from POST /api/replace/preview) are given. Each file is replaced atomically and keeps its encoding, BOM and line endings. A file whose version differs from the given one is left unchanged and reported with its current version. Every changed file is recorded in the audit log. The response lists the outcome per file; the status is 207 if some files failed and 409 or 500 if all did.
+// @ID applyReplace
+// @Tags file
+// @Security TokenAuth
+// @Accept json
+// @Produce json
+// @Param body body ReplaceRequest true "Query, replacement and files"
+// @Success 200 {array} ReplaceResult
+// @Success 207 {array} ReplaceResult
+// @Failure 400 "Missing query or files, or invalid regular expression"
+// @Router /api/replace/apply [post]
+func ReplaceApplyHandler(root string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeReplaceRequest(w, r)
+		if !ok {
+			return
+		}
+		opts := req.options()
+		x, err := opts.Replacer(req.Replace)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Files) == 0 {
+			http.Error(w, "files required", http.StatusBadRequest)
+			return
+		}
+		results := make([]ReplaceResult, 0, len(req.Files))
+		failed, conflicts := 0, 0
+		for _, f := range req.Files {
+			res := replaceFile(r, root, x, f)
+			if res.Error != "" {
+				failed++
+				if res.CurrentVersion != "" {
+					conflicts++
+				}
+			}
+			results = append(results, res)
+		}
+		status := http.StatusOK
+		switch {
+		case failed == 0:
+		case failed < len(results):
+			status = http.StatusMultiStatus
+		case conflicts == failed:
+			status = http.StatusConflict
+		default:
+			status = http.StatusInternalServerError
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(results)
+	}
+}
+
+// replaceFile applies x to one file and records the outcome in the audit
+// log.
+func replaceFile(r *http.Request, root string, x *search.Replacer, f ReplaceFile) ReplaceResult {
+	res := ReplaceResult{Path: f.Path}
+	ev := audit.FromRequest(r, audit.ActionFileReplace)
+	ev.Path = f.Path
+	fail := func(status int, msg string) ReplaceResult {
+		res.Error = msg
+		ev.Status = status
+		ev.Result = audit.ResultError
+		ev.Detail = msg
+		audit.Record(ev)
+		return res
+	}
+
+	target, err := util.SanitizePath(root, f.Path)
+	if err != nil {
+		status, msg := pathErrorStatus(r, f.Path, err)
+		if status == http.StatusForbidden {
+			// already audited as denied
+			res.Error = msg
+			return res
+		}
+		return fail(status, msg)
+	}
+	if rule := lookup(access.Default(), f.Path, target); rule != nil {
+		auditDenied(r, f.Path, "rule "+rule.String())
+		res.Error = "forbidden: access denied (rule " + rule.String() + ")"
+		if rule.Mode == access.Hidden {
+			res.Error = "not found"
+		}
+		return res
+	}
+	base := util.ParseVersion(f.Version)
+	if base == "" || base == "*" {
+		return fail(http.StatusBadRequest, "version required")
+	}
+	fi, err := os.Stat(target)
+	if err != nil || !fi.Mode().IsRegular() {
+		return fail(http.StatusNotFound, "not a file")
+	}
+	if current := util.FileVersion(fi); current != base {
+		res.CurrentVersion = current
+		return fail(http.StatusConflict, "file was changed since the preview")
+	}
+	if fi.Size() > search.MaxFileSize {
+		return fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("file too large (max %d MB)", search.MaxFileSize>>20))
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return fail(http.StatusInternalServerError, "read failed")
+	}
+	info, ok := util.DetectText(data)
+	if !ok {
+		return fail(http.StatusBadRequest, "not a text file")
+	}
+	text, err := util.DecodeText(data, info)
+	if err != nil {
+		return fail(http.StatusBadRequest, err.Error())
+	}
+	text, res.Replaced = x.Replace(text, f.Matches)
+	if res.Replaced == 0 {
+		res.Version = base
+		return res
+	}
+	out, err := util.EncodeText(text, info)
+	if err != nil {
+		res.Replaced = 0
+		return fail(http.StatusBadRequest, err.Error())
+	}
+
+	// the file may have changed while the matches were replaced; other
+	// saves are held off from this check until the file is replaced
+	defer lockPath(target)()
+	if fi, err := os.Stat(target); err != nil || util.FileVersion(fi) != base {
+		res.Replaced = 0
+		if err == nil {
+			res.CurrentVersion = util.FileVersion(fi)
+		}
+		return fail(http.StatusConflict, "file was changed since the preview")
+	}
+	af, err := util.CreateAtomic(target, fi.Mode().Perm())
+	if err != nil {
+		res.Replaced = 0
+		return fail(http.StatusInternalServerError, "write failed")
+	}
+	defer af.Abort()
+	if _, err := af.Write(out); err != nil {
+		res.Replaced = 0
+		return fail(http.StatusInternalServerError, "write failed")
+	}
+	if err := af.Commit(); err != nil {
+		res.Replaced = 0
+		return fail(http.StatusInternalServerError, "write failed")
+	}
+	if fi, err := os.Stat(target); err == nil {
+		res.Version = util.FileVersion(fi)
+	}
+	ev.Status = http.StatusOK
+	ev.Bytes = int64(len(out))
+	ev.Detail = fmt.Sprintf("%d replaced", res.Replaced)
+	audit.Record(ev)
+	return res
+}
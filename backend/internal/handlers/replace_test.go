@@ -0,0 +1,86 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lightdev/internal/util"
+)
+
+func TestReplaceApply(t *testing.T) {
+	root, out := t.TempDir(), t.TempDir()
+	prev := util.CurrentConfinement()
+	util.SetConfinement(util.ConfineJail)
+	defer util.SetConfinement(prev)
+
+	files := map[string]string{
+		filepath.Join(root, "a.txt"): "foo bar\n",
+		filepath.Join(root, "b.txt"): "foo baz\n",
+		filepath.Join(out, "c.txt"):  "foo qux\n",
+	}
+	versions := map[string]string{}
+	for name, data := range files {
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions[name] = util.FileVersion(fi)
+	}
+	// b.txt changes after the preview
+	b := filepath.Join(root, "b.txt")
+	files[b] = "foo changed\n"
+	if err := os.WriteFile(b, []byte(files[b]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := ReplaceRequest{Query: "foo", Replace: "FOO"}
+	for name, version := range versions {
+		req.Files = append(req.Files, ReplaceFile{Path: name, Version: version})
+	}
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	ReplaceApplyHandler(root).ServeHTTP(w, httptest.NewRequest("POST", "/api/replace/apply", bytes.NewReader(body)))
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status %d, want 207", w.Code)
+	}
+	var results []ReplaceResult
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(versions) {
+		t.Fatalf("%d results, want %d", len(results), len(versions))
+	}
+	for _, res := range results {
+		got, _ := os.ReadFile(res.Path)
+		switch filepath.Base(res.Path) {
+		case "a.txt":
+			if res.Error != "" || res.Replaced != 1 || string(got) != "FOO bar\n" {
+				t.Errorf("a.txt: %+v, file %q", res, got)
+			}
+		case "b.txt":
+			if res.CurrentVersion == "" || res.Replaced != 0 {
+				t.Errorf("b.txt: %+v, want a conflict", res)
+			}
+		case "c.txt":
+			if !strings.HasPrefix(res.Error, "forbidden") {
+				t.Errorf("c.txt outside the jail: %+v, want forbidden", res)
+			}
+		}
+		if filepath.Base(res.Path) != "a.txt" && string(got) != files[res.Path] {
+			t.Errorf("%s changed to %q", res.Path, got)
+		}
+	}
+}
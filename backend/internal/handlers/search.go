@@ -0,0 +1,173 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"lightdev/internal/search"
+	"lightdev/internal/util"
+)
+
+// searchRecord is one line of the result stream: a match, the summary
+// at the end, or an error that ended the search.
+type searchRecord struct {
+	Type string `json:"type"`
+	*search.Match
+	Summary *search.Summary `json:"summary,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// SearchHandler searches the content of the files below a directory and
+// streams the matches.
+// @Summary Search file contents
+// @Description Searches the text files below path and streams the matching lines as NDJSON, or as server-sent events if the Accept header asks for text/event-stream or format=sse. Each record has a type: "match" (path, 1-based line, text, submatches in UTF-16 code units, context lines), then one "summary" or "error". Binary files, files over 10MB, symlinks, dotfiles (unless hidden=true) and files excluded by .gitignore (unless noIgnore=true) are skipped; hidden and denied paths of the access rules are never searched. The search stops when the client disconnects.
+// @ID searchFiles
+// @Tags file
+// @Security TokenAuth
+// @Param path query string false "Directory to search (default: root)"
+// @Param q query string true "Text or regular expression"
+// @Param regex query bool false "Treat q as a regular expression (RE2)"
+// @Param case query bool false "Case-sensitive"
+// @Param word query bool false "Match whole words"
+// @Param include query string false "Globs of files to search, comma-separated or repeated"
+// @Param exclude query string false "Globs of files and directories to skip"
+// @Param noIgnore query bool false "Search files excluded by .gitignore"
+// @Param hidden query bool false "Search dotfiles and dot-directories"
+// @Param maxResults query int false "Maximum matching lines (default 1000, at most 10000)"
+// @Param maxPerFile query int false "Maximum matching lines per file (default 100)"
+// @Param context query int false "Context lines before and after a match (at most 10)"
+// @Param format query string false "ndjson (default) or sse"
+// @Produce application/x-ndjson
+// @Produce text/event-stream
+// @Success 200 {object} searchRecord
+// @Failure 400 "Missing query or invalid regular expression"
+// @Router /api/search [get]
+func SearchHandler(root string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if util.IsBlocked() {
+			http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		reqPath := q.Get("path")
+		target, err := util.SanitizePath(root, reqPath)
+		if err != nil {
+			pathError(w, r, reqPath, err)
+			return
+		}
+		if !allowRead(w, r, reqPath, target, false) {
+			return
+		}
+		if fi, err := os.Stat(target); err != nil || !fi.IsDir() {
+			http.Error(w, "not a directory", http.StatusBadRequest)
+			return
+		}
+		opts := search.Options{
+			Query:         q.Get("q"),
+			Regex:         queryBool(q.Get("regex")),
+			CaseSensitive: queryBool(q.Get("case")),
+			WholeWord:     queryBool(q.Get("word")),
+			Include:       queryList(q["include"]),
+			Exclude:       queryList(q["exclude"]),
+			NoIgnore:      queryBool(q.Get("noIgnore")),
+			Hidden:        queryBool(q.Get("hidden")),
+		}
+		for name, dst := range map[string]*int{"maxResults": &opts.MaxResults, "maxPerFile": &opts.MaxPerFile, "context": &opts.Context} {
+			if v := q.Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					http.Error(w, "invalid "+name, http.StatusBadRequest)
+					return
+				}
+				*dst = n
+			}
+		}
+		// report a bad query before the stream starts
+		if _, err := opts.Compile(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		sse := q.Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		emit := func(rec searchRecord) error {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if sse {
+				_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", rec.Type, data)
+			} else {
+				_, err = fmt.Fprintf(w, "%s\n", data)
+			}
+			flusher.Flush()
+			return err
+		}
+
+		sum, err := search.Search(r.Context(), target, opts, func(m search.Match) error {
+			m.Path = apiPath(m.Path)
+			return emit(searchRecord{Type: "match", Match: &m})
+		})
+		if r.Context().Err() != nil {
+			log.Printf("[DEBUG] search for %q in %s cancelled", opts.Query, target)
+			return
+		}
+		if err != nil {
+			_ = emit(searchRecord{Type: "error", Error: err.Error()})
+			return
+		}
+		_ = emit(searchRecord{Type: "summary", Summary: &sum})
+	}
+}
+
+// apiPath returns an absolute path in the form TreeHandler uses.
+func apiPath(p string) string {
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+// queryBool parses a boolean query parameter.
+func queryBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// queryList splits repeated and comma-separated query values.
+func queryList(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
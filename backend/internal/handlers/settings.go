@@ -6,6 +6,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"lightdev/internal/audit"
 	"lightdev/internal/config"
 	"net/http"
 )
@@ -13,8 +14,9 @@ import (
 // SettingsHandler handles reading and writing user settings.
 // GET: Returns merged system config + user settings.
 // POST: Updates user settings.
+// readOnly reports whether the server currently refuses changes.
 // @Summary Get or Update frontend settings
-// @Description Returns runtime-configurable settings or updates them.
+// @Description Returns runtime-configurable settings or updates them. readOnly tells clients to hide write actions.
 // @ID settingsHandler
 // @Tags system
 // @Accept json
@@ -22,9 +24,12 @@ import (
 // @Success 200 {object} map[string]interface{}
 // @Router /api/settings [get]
 // @Router /api/settings [post]
-func SettingsHandler(allowDelete bool, settingsPath string) http.HandlerFunc {
+func SettingsHandler(allowDelete bool, readOnly func() bool, settingsPath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
+			w, ev := audit.Begin(w, r, audit.ActionSettingsChange)
+			defer ev.Done()
+			ev.Path = settingsPath
 			// Update settings
 			// Load existing settings to support partial updates
 			existing, err := config.LoadSettings(settingsPath)
@@ -60,6 +65,7 @@ func SettingsHandler(allowDelete bool, settingsPath string) http.HandlerFunc {
 		// Merge system settings (read-only) with user settings
 		resp := map[string]interface{}{
 			"allowDelete":         allowDelete,
+			"readOnly":            readOnly != nil && readOnly(),
 			"defaultShell":        detectDefaultShell(),
 			"theme":               userSettings.Theme,
 			"autoOpen":            userSettings.AutoOpen,
@@ -25,9 +25,11 @@ import (
 
 // terminalSession represents a server-side PTY/Process and its connected websockets.
 type terminalSession struct {
-	id    string
-	tty   io.ReadWriteCloser
-	cmd   *exec.Cmd
+	id  string
+	tty io.ReadWriteCloser
+	cmd *exec.Cmd
+	// token is the session's MLCREMOTE_TOKEN, revoked on close
+	token string
 	mu    sync.Mutex
 	conns map[*websocket.Conn]struct{}
 }
@@ -38,13 +40,15 @@ var (
 )
 
 // newTerminalSession starts a PTY running the given shell and registers it.
-// If id is empty, a new one is generated.
-func newTerminalSession(id string, shell string, cwd string, extraEnv []string) (*terminalSession, error) {
+// If id is empty, a new one is generated. token is the session's API
+// token from issueTerminalToken; it is revoked when the session ends.
+func newTerminalSession(id string, shell string, cwd string, extraEnv []string, token string) (*terminalSession, error) {
 	if shell == "" {
 		// ...
 	}
 	tty, cmd, err := termutil.StartShellPTY(shell, cwd, extraEnv)
 	if err != nil {
+		revokeTerminalToken(token)
 		return nil, err
 	}
 	if id == "" {
@@ -54,6 +58,7 @@ func newTerminalSession(id string, shell string, cwd string, extraEnv []string)
 		id:    id,
 		tty:   tty,
 		cmd:   cmd,
+		token: token,
 		conns: make(map[*websocket.Conn]struct{}),
 	}
 	// start reader from PTY to broadcast to connections
@@ -139,6 +144,7 @@ func (s *terminalSession) close() {
 		delete(s.conns, c)
 	}
 	sessionsMu.Unlock()
+	revokeTerminalToken(s.token)
 	// attempt to close tty
 	_ = s.tty.Close()
 	// attempt to terminate the child process and its group
@@ -160,6 +166,25 @@ func getSession(id string) *terminalSession {
 
 // Session id generation is handled by util/terminal.GenerateSessionID.
 
+// ActiveSessionCount returns the number of running terminal sessions.
+func ActiveSessionCount() int {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	return len(sessions)
+}
+
+// AttachedConnCount returns the number of websockets attached to terminal
+// sessions.
+func AttachedConnCount() int {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	n := 0
+	for _, s := range sessions {
+		n += len(s.conns)
+	}
+	return n
+}
+
 // ShutdownAllSessions attempts to close all active terminal sessions and their
 // associated PTYs/connections. It is safe to call multiple times.
 func ShutdownAllSessions() {
@@ -236,15 +261,12 @@ func NewTerminalAPI(root string, serverPort *int) http.HandlerFunc {
 			}
 		}
 
-		token := r.Header.Get("X-Auth-Token")
-		if token == "" {
-			token = r.URL.Query().Get("token")
-		}
+		token := issueTerminalToken(r)
 
 		// Use shared helper ensuring we get the correct server port (not the tunnel port from Host header)
 		env := buildSessionEnv(r, token, serverPort)
 
-		s, err := newTerminalSession(id, shell, cwd, env)
+		s, err := newTerminalSession(id, shell, cwd, env, token)
 		if err != nil {
 			log.Printf("[ERROR] failed to start session (shell=%s cwd=%s): %v", shell, cwd, err)
 			http.Error(w, "failed to start session: "+err.Error(), http.StatusInternalServerError)
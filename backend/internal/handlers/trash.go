@@ -6,13 +6,13 @@ package handlers
 
 import (
 	"encoding/json"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"lightdev/internal/audit"
 	"lightdev/internal/util"
 )
 
@@ -80,6 +80,8 @@ type RestoreRequest struct {
 // @Router /api/trash/restore [post]
 func RestoreTrashHandler(root string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionTrashRestore)
+		defer ev.Done()
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -104,15 +106,20 @@ func RestoreTrashHandler(root string) http.HandlerFunc {
 			}
 		}
 
+		ev.Path = req.TrashPath
 		if entry == nil {
 			http.Error(w, "trash entry not found in history", http.StatusNotFound)
 			return
 		}
+		ev.Target = entry.OriginalPath
 
 		// Calculate destination
 		dest, err := util.SanitizePath(root, entry.OriginalPath)
 		if err != nil {
-			http.Error(w, "invalid destination path", http.StatusBadRequest)
+			pathError(w, r, entry.OriginalPath, err)
+			return
+		}
+		if !allowWrite(w, r, entry.OriginalPath, dest, false) {
 			return
 		}
 
@@ -131,23 +138,11 @@ func RestoreTrashHandler(root string) http.HandlerFunc {
 		// Move back
 		if err := os.Rename(entry.TrashPath, dest); err != nil {
 			// fallback copy
-			in, err := os.Open(entry.TrashPath)
-			if err != nil {
-				http.Error(w, "restore failed (open)", http.StatusInternalServerError)
-				return
-			}
-			defer in.Close()
-			out, err := os.Create(dest)
-			if err != nil {
-				http.Error(w, "restore failed (create)", http.StatusInternalServerError)
-				return
-			}
-			defer out.Close()
-			if _, err := io.Copy(out, in); err != nil {
+			if err := util.CopyRecursive(entry.TrashPath, dest); err != nil {
 				http.Error(w, "restore failed (copy)", http.StatusInternalServerError)
 				return
 			}
-			_ = os.Remove(entry.TrashPath)
+			_ = os.RemoveAll(entry.TrashPath)
 		}
 
 		// Remove from history
@@ -168,6 +163,8 @@ func RestoreTrashHandler(root string) http.HandlerFunc {
 // @Router /api/trash [delete]
 func EmptyTrashHandler(trashDir string, allowDelete bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		w, ev := audit.Begin(w, r, audit.ActionTrashEmpty)
+		defer ev.Done()
 		if !allowDelete {
 			http.Error(w, "deletion is disabled", http.StatusForbidden)
 			return
@@ -177,6 +174,7 @@ func EmptyTrashHandler(trashDir string, allowDelete bool) http.HandlerFunc {
 			return
 		}
 
+		ev.Path = trashDir
 		// Clear in-memory history since backing files are gone
 		trashMu.Lock()
 		recentTrashed = []TrashEntry{}
@@ -0,0 +1,556 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/metrics"
+	"lightdev/internal/util"
+)
+
+// Resumable uploads follow the tus protocol (tus.io) in spirit:
+//
+//	POST   /api/uploads               create an upload for a path and size
+//	HEAD   /api/uploads/{id}          Upload-Offset reports the bytes received
+//	PATCH  /api/uploads/{id}          append a chunk at Upload-Offset
+//	POST   /api/uploads/{id}/complete verify the SHA-256, move the file into place
+//	DELETE /api/uploads/{id}          abort
+//
+// Partial data is kept in the upload directory as <id>.part next to
+// <id>.json, so an upload survives a dropped connection as well as an
+// agent restart. Uploads without a chunk for uploadExpiry are removed.
+const (
+	uploadExpiry        = 24 * time.Hour
+	uploadSweepInterval = 10 * time.Minute
+)
+
+// DefaultMaxUploadSize is the largest resumable upload accepted unless
+// configured otherwise.
+const DefaultMaxUploadSize int64 = 10 << 30
+
+// UploadInfo describes a resumable upload.
+type UploadInfo struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Offset is the number of bytes received so far.
+	Offset int64 `json:"offset"`
+	// SHA256 is the checksum announced at creation, if any.
+	SHA256    string    `json:"sha256,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CreateUploadRequest is the POST /api/uploads body.
+type CreateUploadRequest struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// CompleteUploadRequest is the POST /api/uploads/{id}/complete body. The
+// checksum may be omitted if it was given at creation.
+type CompleteUploadRequest struct {
+	SHA256 string `json:"sha256"`
+}
+
+// UploadResult reports the outcome for one uploaded file.
+type UploadResult struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+var errUploadNotFound = errors.New("upload not found")
+
+// uploadStore keeps the state of resumable uploads on disk.
+type uploadStore struct {
+	dir string
+
+	mu        sync.Mutex
+	busy      map[string]bool
+	lastSweep time.Time
+}
+
+func (s *uploadStore) part(id string) string { return filepath.Join(s.dir, id+".part") }
+func (s *uploadStore) meta(id string) string { return filepath.Join(s.dir, id+".json") }
+
+// load returns the upload id. Offset and Updated come from the part file.
+func (s *uploadStore) load(id string) (*UploadInfo, error) {
+	if b, err := hex.DecodeString(id); err != nil || len(b) != 16 {
+		return nil, errUploadNotFound
+	}
+	data, err := os.ReadFile(s.meta(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	var info UploadInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(s.part(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	info.Offset = fi.Size()
+	info.Updated = fi.ModTime().UTC()
+	info.ExpiresAt = info.Updated.Add(uploadExpiry)
+	return &info, nil
+}
+
+func (s *uploadStore) create(req CreateUploadRequest) (*UploadInfo, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	info := &UploadInfo{
+		ID:        hex.EncodeToString(b),
+		Path:      req.Path,
+		Size:      req.Size,
+		SHA256:    req.SHA256,
+		Created:   now,
+		Updated:   now,
+		ExpiresAt: now.Add(uploadExpiry),
+	}
+	// partial data is private until the upload is complete
+	f, err := os.OpenFile(s.part(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	data, _ := json.MarshalIndent(info, "", "  ")
+	if err := os.WriteFile(s.meta(info.ID), data, 0600); err != nil {
+		os.Remove(s.part(info.ID))
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *uploadStore) remove(id string) {
+	os.Remove(s.part(id))
+	os.Remove(s.meta(id))
+}
+
+// acquire marks id as in use, so chunks of one upload are not written
+// concurrently. It reports false if the upload is already in use.
+func (s *uploadStore) acquire(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[id] {
+		return false
+	}
+	s.busy[id] = true
+	return true
+}
+
+func (s *uploadStore) release(id string) {
+	s.mu.Lock()
+	delete(s.busy, id)
+	s.mu.Unlock()
+}
+
+// list returns the pending uploads.
+func (s *uploadStore) list() []*UploadInfo {
+	out := []*UploadInfo{}
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	for _, m := range matches {
+		if info, err := s.load(strings.TrimSuffix(filepath.Base(m), ".json")); err == nil {
+			out = append(out, info)
+		}
+	}
+	return out
+}
+
+// sweep removes expired uploads, at most once per uploadSweepInterval.
+func (s *uploadStore) sweep() {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < uploadSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "*"))
+	for _, m := range matches {
+		id := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(m), ".json"), ".part")
+		fi, err := os.Stat(m)
+		if err != nil || time.Since(fi.ModTime()) < uploadExpiry || !s.acquire(id) {
+			continue
+		}
+		if info, err := s.load(id); err == nil && time.Now().Before(info.ExpiresAt) {
+			s.release(id)
+			continue
+		}
+		s.remove(id)
+		s.release(id)
+		log.Printf("[INFO] uploads: removed abandoned upload %s", id)
+	}
+}
+
+// UploadsHandler serves resumable uploads. Partial data is kept in dir.
+// Uploads larger than maxSize are refused; 0 means DefaultMaxUploadSize.
+// @Summary Resumable uploads
+// @Description Chunked, resumable uploads for large files. POST /api/uploads creates an upload ({"path","size","sha256"}) and returns its id. PATCH /api/uploads/{id} appends the body at the Upload-Offset header, which must equal the bytes received so far (409 with the current Upload-Offset otherwise). Uploads larger than the configured maximum (max_upload_size_mb, default 10 GB) are refused with 413. HEAD or GET /api/uploads/{id} reports Upload-Offset and Upload-Length. POST /api/uploads/{id}/complete checks the SHA-256 and moves the file to its path. DELETE /api/uploads/{id} aborts. GET /api/uploads lists pending uploads. Uploads without a chunk for 24 hours are removed.
+// @ID resumableUploads
+// @Tags file
+// @Security TokenAuth
+// @Accept json
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Param body body CreateUploadRequest false "Upload to create (POST /api/uploads)"
+// @Success 200 {object} UploadResult
+// @Success 201 {object} UploadInfo
+// @Success 204
+// @Failure 400
+// @Failure 404 "Upload not found or expired"
+// @Failure 409 "Offset mismatch, upload busy or incomplete"
+// @Failure 413 "Upload larger than the configured maximum"
+// @Router /api/uploads [get]
+// @Router /api/uploads [post]
+// @Router /api/uploads/{id} [head]
+// @Router /api/uploads/{id} [patch]
+// @Router /api/uploads/{id} [delete]
+// @Router /api/uploads/{id}/complete [post]
+func UploadsHandler(root, dir string, maxSize int64) http.HandlerFunc {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUploadSize
+	}
+	store := &uploadStore{dir: dir, busy: map[string]bool{}}
+	store.sweep()
+	return func(w http.ResponseWriter, r *http.Request) {
+		store.sweep()
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/uploads"), "/")
+		id, action, _ := strings.Cut(rest, "/")
+
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(store.list())
+		case id == "" && r.Method == http.MethodPost:
+			createUpload(w, r, root, store, maxSize)
+		case id == "":
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		case action == "complete" && r.Method == http.MethodPost:
+			completeUpload(w, r, root, store, id)
+		case action != "":
+			http.Error(w, "not found", http.StatusNotFound)
+		case r.Method == http.MethodHead || r.Method == http.MethodGet:
+			info, err := store.load(id)
+			if err != nil {
+				uploadError(w, err)
+				return
+			}
+			setUploadHeaders(w, info)
+			w.Header().Set("Cache-Control", "no-store")
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(info)
+		case r.Method == http.MethodPatch:
+			patchUpload(w, r, store, id)
+		case r.Method == http.MethodDelete:
+			if !store.acquire(id) {
+				http.Error(w, "upload busy", http.StatusConflict)
+				return
+			}
+			defer store.release(id)
+			if _, err := store.load(id); err != nil {
+				uploadError(w, err)
+				return
+			}
+			store.remove(id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func setUploadHeaders(w http.ResponseWriter, info *UploadInfo) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Upload-Expires", info.ExpiresAt.Format(http.TimeFormat))
+}
+
+func uploadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUploadNotFound) {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "upload state unreadable: "+err.Error(), http.StatusInternalServerError)
+}
+
+// validSHA256 reports whether s is a hex SHA-256 digest.
+func validSHA256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+func createUpload(w http.ResponseWriter, r *http.Request, root string, store *uploadStore, maxSize int64) {
+	if util.IsBlocked() {
+		http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if req.Path == "" || req.Size < 0 {
+		http.Error(w, "path and size required", http.StatusBadRequest)
+		return
+	}
+	if req.Size > maxSize {
+		http.Error(w, fmt.Sprintf("upload too large (max %d MB)", maxSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if req.SHA256 != "" && !validSHA256(req.SHA256) {
+		http.Error(w, "invalid sha256", http.StatusBadRequest)
+		return
+	}
+	target, err := util.SanitizePath(root, req.Path)
+	if err != nil {
+		pathError(w, r, req.Path, err)
+		return
+	}
+	if !allowWrite(w, r, req.Path, target, false) {
+		return
+	}
+	if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+		http.Error(w, "path is a directory", http.StatusConflict)
+		return
+	}
+	info, err := store.create(req)
+	if err != nil {
+		http.Error(w, "failed to create upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setUploadHeaders(w, info)
+	w.Header().Set("Location", "/api/uploads/"+info.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+func patchUpload(w http.ResponseWriter, r *http.Request, store *uploadStore, id string) {
+	if util.IsBlocked() {
+		http.Error(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	// load under the lock, a chunk finishing in between moves the offset
+	if !store.acquire(id) {
+		http.Error(w, "upload busy", http.StatusConflict)
+		return
+	}
+	defer store.release(id)
+	info, err := store.load(id)
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Upload-Offset header required", http.StatusBadRequest)
+		return
+	}
+	if offset != info.Offset {
+		setUploadHeaders(w, info)
+		http.Error(w, fmt.Sprintf("offset mismatch: %d bytes received", info.Offset), http.StatusConflict)
+		return
+	}
+
+	f, err := os.OpenFile(store.part(id), os.O_WRONLY, 0)
+	if err != nil {
+		http.Error(w, "failed to open upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		http.Error(w, "failed to seek: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// what arrived before a dropped connection is kept, the client
+	// resumes from the offset reported by HEAD
+	n, copyErr := io.Copy(f, io.LimitReader(r.Body, info.Size-offset))
+	metrics.UploadBytes.Add(uint64(n))
+	closeErr := f.Close()
+	info.Offset += n
+	setUploadHeaders(w, info)
+	switch {
+	case copyErr != nil:
+		log.Printf("[DEBUG] uploads: chunk for %s interrupted after %d bytes: %v", id, n, copyErr)
+		http.Error(w, "chunk interrupted: "+copyErr.Error(), http.StatusBadRequest)
+	case closeErr != nil:
+		http.Error(w, "failed to write chunk: "+closeErr.Error(), http.StatusInternalServerError)
+	case info.Offset == info.Size && extraData(r.Body):
+		http.Error(w, "chunk exceeds upload size", http.StatusRequestEntityTooLarge)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// extraData reports whether body has data left.
+func extraData(body io.Reader) bool {
+	n, _ := body.Read(make([]byte, 1))
+	return n > 0
+}
+
+func completeUpload(w http.ResponseWriter, r *http.Request, root string, store *uploadStore, id string) {
+	w, ev := audit.Begin(w, r, audit.ActionFileUpload)
+	defer ev.Done()
+	if !store.acquire(id) {
+		http.Error(w, "upload busy", http.StatusConflict)
+		return
+	}
+	defer store.release(id)
+	info, err := store.load(id)
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+	ev.Path = info.Path
+
+	var req CompleteUploadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	want := strings.ToLower(strings.TrimSpace(req.SHA256))
+	if want == "" {
+		want = info.SHA256
+	}
+	if !validSHA256(want) {
+		http.Error(w, "sha256 checksum required", http.StatusBadRequest)
+		return
+	}
+	if info.SHA256 != "" && want != info.SHA256 {
+		http.Error(w, "sha256 differs from the one given at creation", http.StatusBadRequest)
+		return
+	}
+	if info.Offset != info.Size {
+		setUploadHeaders(w, info)
+		http.Error(w, fmt.Sprintf("upload incomplete: %d of %d bytes received", info.Offset, info.Size), http.StatusConflict)
+		return
+	}
+
+	sum, err := fileSHA256(store.part(id))
+	if err != nil {
+		http.Error(w, "failed to hash upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sum != want {
+		ev.Detail = "checksum mismatch"
+		http.Error(w, "checksum mismatch: got "+sum, http.StatusBadRequest)
+		return
+	}
+
+	// rules and confinement may have changed since the upload was created
+	target, err := util.SanitizePath(root, info.Path)
+	if err != nil {
+		pathError(w, r, info.Path, err)
+		return
+	}
+	if !allowWrite(w, r, info.Path, target, false) {
+		return
+	}
+	if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+		http.Error(w, "path is a directory", http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		http.Error(w, "mkdir failed", http.StatusInternalServerError)
+		return
+	}
+	// a new file gets the usual mode instead of the private one of the
+	// part file; ReplaceFile keeps the mode of a file it replaces
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		_ = os.Chmod(store.part(id), uploadFileMode)
+	}
+	if err := util.ReplaceFile(store.part(id), target); err != nil {
+		// the upload directory may be on another filesystem
+		if err := copyIntoPlace(store.part(id), target); err != nil {
+			http.Error(w, "failed to move upload into place: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	store.remove(id)
+
+	ev.Bytes = info.Size
+	ev.Detail = "sha256=" + sum
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(UploadResult{Name: filepath.Base(target), Path: info.Path, Bytes: info.Size, SHA256: sum})
+}
+
+// uploadFileMode is the mode of a file created by an upload.
+const uploadFileMode os.FileMode = 0644
+
+// copyIntoPlace copies src to name through an atomic file, which keeps
+// the mode of an existing file.
+func copyIntoPlace(src, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := util.CreateAtomic(name, uploadFileMode)
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Commit()
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func uploadRequest(h http.Handler, method, target string, body io.Reader, offset int64) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	if offset >= 0 {
+		r.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// failingReader returns its data and then an error, like the body of a
+// request whose connection dropped.
+type failingReader struct{ data []byte }
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+func TestUploadResume(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "uploads")
+	h := UploadsHandler(root, dir, 0)
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(data)
+	create, _ := json.Marshal(CreateUploadRequest{Path: "sub/big.bin", Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+	w := uploadRequest(h, "POST", "/api/uploads", bytes.NewReader(create), -1)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d %s", w.Code, w.Body)
+	}
+	var info UploadInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	url := "/api/uploads/" + info.ID
+	offset := func(h http.Handler) string {
+		t.Helper()
+		w := uploadRequest(h, "HEAD", url, nil, -1)
+		if w.Code != http.StatusOK {
+			t.Fatalf("HEAD: status %d", w.Code)
+		}
+		return w.Header().Get("Upload-Offset")
+	}
+
+	if w := uploadRequest(h, "PATCH", url, bytes.NewReader(data[:3000]), 0); w.Code != http.StatusNoContent {
+		t.Fatalf("first chunk: status %d %s", w.Code, w.Body)
+	}
+	// the connection drops halfway through the second chunk
+	w = uploadRequest(h, "PATCH", url, &failingReader{data[3000:4500]}, 3000)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("interrupted chunk: status %d", w.Code)
+	}
+	if got := offset(h); got != "4500" {
+		t.Fatalf("offset after interrupted chunk %s, want 4500", got)
+	}
+	// a client resending from where it thinks it stopped is told the
+	// actual offset
+	w = uploadRequest(h, "PATCH", url, bytes.NewReader(data[3000:]), 3000)
+	if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "4500" {
+		t.Fatalf("stale offset: status %d, Upload-Offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if w := uploadRequest(h, "POST", url+"/complete", nil, -1); w.Code != http.StatusConflict {
+		t.Fatalf("complete before all data: status %d", w.Code)
+	}
+
+	// the agent restarts; the upload continues from the part file
+	h = UploadsHandler(root, dir, 0)
+	if got := offset(h); got != "4500" {
+		t.Fatalf("offset after restart %s, want 4500", got)
+	}
+	if w := uploadRequest(h, "PATCH", url, bytes.NewReader(data[4500:]), 4500); w.Code != http.StatusNoContent {
+		t.Fatalf("last chunk: status %d %s", w.Code, w.Body)
+	}
+	if w := uploadRequest(h, "PATCH", url, strings.NewReader("x"), int64(len(data))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("data beyond the size: status %d, want 413", w.Code)
+	}
+	if w := uploadRequest(h, "POST", url+"/complete", nil, -1); w.Code != http.StatusOK {
+		t.Fatalf("complete: status %d %s", w.Code, w.Body)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "sub", "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("uploaded file differs: %d bytes, want %d", len(got), len(data))
+	}
+	if w := uploadRequest(h, "HEAD", url, nil, -1); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD after completion: status %d, want 404", w.Code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left in the upload directory", len(entries))
+	}
+}
+
+func TestUploadChecksum(t *testing.T) {
+	root := t.TempDir()
+	h := UploadsHandler(root, filepath.Join(t.TempDir(), "uploads"), 0)
+	if err := os.WriteFile(filepath.Join(root, "f.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	create, _ := json.Marshal(CreateUploadRequest{Path: "f.txt", Size: 3})
+	w := uploadRequest(h, "POST", "/api/uploads", bytes.NewReader(create), -1)
+	var info UploadInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	url := "/api/uploads/" + info.ID
+	uploadRequest(h, "PATCH", url, strings.NewReader("new"), 0)
+
+	wrong := sha256.Sum256([]byte("other"))
+	body, _ := json.Marshal(CompleteUploadRequest{SHA256: hex.EncodeToString(wrong[:])})
+	if w := uploadRequest(h, "POST", url+"/complete", bytes.NewReader(body), -1); w.Code != http.StatusBadRequest {
+		t.Fatalf("wrong checksum: status %d, want 400", w.Code)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "f.txt")); string(got) != "old" {
+		t.Fatalf("file changed to %q by a failed upload", got)
+	}
+	if w := uploadRequest(h, "DELETE", url, nil, -1); w.Code != http.StatusNoContent {
+		t.Fatalf("abort: status %d", w.Code)
+	}
+	if w := uploadRequest(h, "HEAD", url, nil, -1); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD after abort: status %d, want 404", w.Code)
+	}
+	if w := uploadRequest(h, "HEAD", "/api/uploads/../../etc", nil, -1); w.Code != http.StatusNotFound {
+		t.Errorf("invalid id: status %d, want 404", w.Code)
+	}
+}
+
+func TestUploadSizeLimit(t *testing.T) {
+	h := UploadsHandler(t.TempDir(), filepath.Join(t.TempDir(), "uploads"), 1<<20)
+	for _, tt := range []struct {
+		size int64
+		want int
+	}{
+		{1 << 20, http.StatusCreated},
+		{1<<20 + 1, http.StatusRequestEntityTooLarge},
+		{-1, http.StatusBadRequest},
+	} {
+		create, _ := json.Marshal(CreateUploadRequest{Path: "f.bin", Size: tt.size})
+		if w := uploadRequest(h, "POST", "/api/uploads", bytes.NewReader(create), -1); w.Code != tt.want {
+			t.Errorf("size %d: status %d, want %d", tt.size, w.Code, tt.want)
+		}
+	}
+}
@@ -14,6 +14,8 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
 	"lightdev/internal/util"
 	termutil "lightdev/internal/util/terminal"
 )
@@ -31,7 +33,9 @@ import (
 // @Router /ws/terminal [get]
 // @Router /ws/terminal [get]
 // @Router /ws/terminal [get]
-func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFunc {
+//
+// checkOrigin decides which browser origins may open a terminal.
+func WsTerminalHandler(root string, debug bool, serverPort *int, checkOrigin func(*http.Request) bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if debug {
 			log.Printf("HANDLER: WsTerminalHandler called. Session=%s", r.URL.Query().Get("session"))
@@ -39,7 +43,7 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 		up := websocket.Upgrader{
 			ReadBufferSize:  8192,
 			WriteBufferSize: 8192,
-			CheckOrigin:     func(r *http.Request) bool { return true },
+			CheckOrigin:     checkOrigin,
 		}
 		conn, err := up.Upgrade(w, r, nil)
 		if err != nil {
@@ -80,16 +84,13 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 					cwd = ""
 				}
 			}
-			token := r.Header.Get("X-Auth-Token")
-			if token == "" {
-				token = r.URL.Query().Get("token")
-			}
+			token := issueTerminalToken(r)
 			env := buildSessionEnv(r, token, serverPort)
 
-			log.Printf("WsTerminalHandler: creating session with env: %v", env)
+			log.Printf("WsTerminalHandler: creating session shell=%s cwd=%s", shell, cwd)
 
 			// create a tracked terminal session so ShutdownAllSessions can close it
-			s, err := newTerminalSession("", shell, cwd, env)
+			s, err := newTerminalSession("", shell, cwd, env, token)
 			if err != nil {
 				log.Printf("failed to start shell for ephemeral ws: %v", err)
 				_ = conn.WriteMessage(websocket.TextMessage, []byte("failed to start shell: "+err.Error()))
@@ -98,7 +99,7 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 			// attach this websocket to the session
 			s.addConn(conn)
 			log.Printf("ws: attached conn %p to session %s", conn, s.id)
-			WriteAuditLog("Terminal Session Connected (Ephemeral, shell=%s) from %s", shell, r.RemoteAddr)
+			auditTerminal(r, audit.ActionTerminalOpen, s.id, "ephemeral, shell="+shell)
 
 			defer func() {
 				s.removeConn(conn)
@@ -109,7 +110,7 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 				sessionsMu.Lock()
 				delete(sessions, s.id)
 				sessionsMu.Unlock()
-				WriteAuditLog("Terminal Session Disconnected (Ephemeral) from %s", r.RemoteAddr)
+				auditTerminal(r, audit.ActionTerminalClose, s.id, "ephemeral")
 			}()
 
 			// WS -> PTY (write into session's PTY). Support resize JSON messages.
@@ -149,7 +150,7 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 		}
 
 		s.addConn(conn)
-		WriteAuditLog("Terminal Session Attached (Persistent, id=%s) from %s", sessionID, r.RemoteAddr)
+		auditTerminal(r, audit.ActionTerminalOpen, sessionID, "attached")
 
 		defer func() {
 			s.removeConn(conn)
@@ -164,9 +165,9 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 				sessionsMu.Lock()
 				delete(sessions, s.id)
 				sessionsMu.Unlock()
-				WriteAuditLog("Terminal Session Closed (Persistent, id=%s) from %s", sessionID, r.RemoteAddr)
+				auditTerminal(r, audit.ActionTerminalClose, sessionID, "closed")
 			} else {
-				WriteAuditLog("Terminal Session Detached (Persistent, id=%s) from %s", sessionID, r.RemoteAddr)
+				auditTerminal(r, audit.ActionTerminalClose, sessionID, "detached")
 			}
 		}()
 
@@ -198,8 +199,60 @@ func WsTerminalHandler(root string, debug bool, serverPort *int) http.HandlerFun
 	}
 }
 
-// buildSessionEnv constructs the environment variables for a terminal session,
-// including the auth token and the API URL.
+var (
+	apiSocket      string
+	apiFingerprint string
+	terminalTokens *auth.TerminalTokens
+)
+
+// auditTerminal records the opening or closing of a terminal session.
+func auditTerminal(r *http.Request, action, sessionID, detail string) {
+	ev := audit.FromRequest(r, action)
+	ev.Detail = "session=" + sessionID + " (" + detail + ")"
+	audit.Record(ev)
+}
+
+// SetAPISocket records the Unix socket the server listens on. Terminal
+// sessions then get MLCREMOTE_API_SOCKET so the cmd/cwd helpers can reach
+// the agent without a TCP port.
+func SetAPISocket(path string) {
+	apiSocket = path
+}
+
+// SetAPIFingerprint records the TLS certificate fingerprint. Terminal
+// sessions get it as MLCREMOTE_API_FINGERPRINT so the helpers can pin the
+// self-signed certificate.
+func SetAPIFingerprint(fp string) {
+	apiFingerprint = fp
+}
+
+// SetTerminalTokens sets the store that mints the MLCREMOTE_TOKEN of
+// terminal sessions. Without one, terminals get no token.
+func SetTerminalTokens(t *auth.TerminalTokens) {
+	terminalTokens = t
+}
+
+// issueTerminalToken mints the API token of a terminal opened by the
+// caller of r. The caller's own credential is never passed to the shell.
+func issueTerminalToken(r *http.Request) string {
+	if terminalTokens == nil {
+		return ""
+	}
+	token, err := terminalTokens.Issue(auth.FromContext(r.Context()))
+	if err != nil {
+		log.Printf("[ERROR] failed to issue terminal token: %v", err)
+		return ""
+	}
+	return token
+}
+
+// revokeTerminalToken invalidates a token from issueTerminalToken.
+func revokeTerminalToken(token string) {
+	if terminalTokens != nil && token != "" {
+		terminalTokens.Revoke(token)
+	}
+}
+
 // buildSessionEnv constructs the environment variables for a terminal session,
 // including the auth token and the API URL.
 func buildSessionEnv(r *http.Request, token string, serverPort *int) []string {
@@ -207,6 +260,9 @@ func buildSessionEnv(r *http.Request, token string, serverPort *int) []string {
 	if token != "" {
 		env = append(env, "MLCREMOTE_TOKEN="+token)
 	}
+	if apiFingerprint != "" {
+		env = append(env, "MLCREMOTE_API_FINGERPRINT="+apiFingerprint)
+	}
 
 	scheme := "http"
 	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
@@ -214,7 +270,11 @@ func buildSessionEnv(r *http.Request, token string, serverPort *int) []string {
 	}
 
 	var apiURL string
-	if serverPort != nil && *serverPort > 0 {
+	if apiSocket != "" {
+		// the host part is ignored when dialing the socket
+		apiURL = fmt.Sprintf("%s://localhost", scheme)
+		env = append(env, "MLCREMOTE_API_SOCKET="+apiSocket)
+	} else if serverPort != nil && *serverPort > 0 {
 		apiURL = fmt.Sprintf("%s://%s:%d", scheme, "127.0.0.1", *serverPort)
 	} else {
 		// Fallback if port is not available
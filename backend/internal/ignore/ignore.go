@@ -0,0 +1,200 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package ignore implements .gitignore rules and the glob matching used
+// for include and exclude patterns.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one line of a .gitignore file.
+type ignoreRule struct {
+	// base is the directory of the .gitignore file
+	base     string
+	elems    []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnore reads the rules of the .gitignore file in dir, if any.
+func parseIgnore(dir string) []ignoreRule {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		// a slash other than at the end anchors the pattern to base
+		r.anchored = strings.Contains(line, "/")
+		r.elems = globElems(line)
+		if len(r.elems) > 0 {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// matches reports whether the rule applies to name, a path below r.base.
+func (r ignoreRule) matches(name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	elems := globElems(filepath.ToSlash(rel))
+	if !r.anchored {
+		return globMatch(r.elems, elems[len(elems)-1:])
+	}
+	return globMatch(r.elems, elems)
+}
+
+// Matcher tracks the .gitignore files of the directories being walked.
+type Matcher struct {
+	rules map[string][]ignoreRule
+}
+
+// New returns a Matcher for a walk of dir. If dir is inside a
+// git repository, the .gitignore files of its parents up to the
+// repository root apply as well.
+func New(dir string) *Matcher {
+	ig := &Matcher{rules: map[string][]ignoreRule{}}
+	var parents []string
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			for _, p := range parents {
+				ig.rules[p] = parseIgnore(p)
+			}
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		parents = append(parents, parent)
+		d = parent
+	}
+	return ig
+}
+
+// Enter loads the .gitignore file of dir. A walk calls it for each
+// directory before its contents.
+func (ig *Matcher) Enter(dir string) {
+	if _, ok := ig.rules[dir]; !ok {
+		ig.rules[dir] = parseIgnore(dir)
+	}
+}
+
+// Ignored reports whether name is ignored. Rules of deeper directories
+// and later lines take precedence, as in git.
+func (ig *Matcher) Ignored(name string, isDir bool) bool {
+	var dirs []string
+	for d := filepath.Dir(name); ; d = filepath.Dir(d) {
+		if _, ok := ig.rules[d]; ok {
+			dirs = append(dirs, d)
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, r := range ig.rules[dirs[i]] {
+			if r.matches(name, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Forget drops the rules loaded for dir, so that Enter reads the
+// .gitignore file again.
+func (ig *Matcher) Forget(dir string) {
+	delete(ig.rules, dir)
+}
+
+// MatchAny reports whether name, a path below dir, matches one of the
+// globs. A glob without "/" matches the last element of the path, others
+// the path relative to dir. "**" matches any number of elements.
+func MatchAny(globs []string, name, dir string) bool {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil {
+		return false
+	}
+	elems := globElems(filepath.ToSlash(rel))
+	if len(elems) == 0 {
+		return false
+	}
+	for _, g := range globs {
+		pat := globElems(g)
+		if len(pat) == 0 {
+			continue
+		}
+		if !strings.Contains(g, "/") {
+			if globMatch(pat, elems[len(elems)-1:]) {
+				return true
+			}
+		} else if globMatch(pat, elems) {
+			return true
+		}
+	}
+	return false
+}
+
+// globElems splits a slash-separated pattern or path into elements.
+func globElems(p string) []string {
+	var out []string
+	for _, e := range strings.Split(p, "/") {
+		if e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// globMatch reports whether the path elements match the pattern elements,
+// where "**" matches any number of elements.
+func globMatch(pat, elems []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if globMatch(pat[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], elems[0]); !ok {
+			return false
+		}
+		pat, elems = pat[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
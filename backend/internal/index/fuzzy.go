@@ -0,0 +1,165 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Score weights. A match of the whole query in the file name beats a
+// scattered match across directories.
+const (
+	scoreChar        = 1
+	scoreConsecutive = 5
+	scoreSegment     = 8
+	scoreCamel       = 7
+	scoreNameStart   = 10
+	scoreInName      = 2
+	scoreExactName   = 50
+	scoreNamePrefix  = 20
+	penaltyGap       = 1
+	maxGapPenalty    = 10
+	maxRecency       = 10
+)
+
+// fuzzyMatch matches the lower-case query against path, whose file name
+// starts at byte nameStart. It returns the score and the byte offsets of
+// the matched characters, or false if the query is not a subsequence of
+// path.
+func fuzzyMatch(query []rune, path string, nameStart int) (int, []int, bool) {
+	// the query may be matched throughout the path, or as far as possible
+	// in the file name; the better of both counts
+	best, bestPos, ok := -1, []int(nil), false
+	if pos := matchFrom(query, path, 0); pos != nil {
+		best, bestPos, ok = scoreMatch(path, nameStart, pos), pos, true
+	}
+	if pos := matchNameFirst(query, path, nameStart); pos != nil {
+		if s := scoreMatch(path, nameStart, pos); s > best {
+			best, bestPos, ok = s, pos, true
+		}
+	}
+	if !ok {
+		return 0, nil, false
+	}
+	name := strings.ToLower(path[nameStart:])
+	switch q := string(query); {
+	case name == q:
+		best += scoreExactName
+	case strings.HasPrefix(name, q):
+		best += scoreNamePrefix
+	}
+	return best, bestPos, true
+}
+
+// matchFrom matches query greedily in path starting at byte from.
+func matchFrom(query []rune, path string, from int) []int {
+	pos := make([]int, 0, len(query))
+	qi := 0
+	for i, r := range path[from:] {
+		if qi == len(query) {
+			break
+		}
+		if unicode.ToLower(r) == query[qi] {
+			pos = append(pos, from+i)
+			qi++
+		}
+	}
+	if qi < len(query) {
+		return nil
+	}
+	return pos
+}
+
+// matchNameFirst matches the longest possible end of query in the file
+// name and the rest before it.
+func matchNameFirst(query []rune, path string, nameStart int) []int {
+	for k := 0; k < len(query); k++ {
+		tail := matchFrom(query[k:], path, nameStart)
+		if tail == nil {
+			continue
+		}
+		if k == 0 {
+			return tail
+		}
+		head := matchFrom(query[:k], path[:nameStart], 0)
+		if head == nil {
+			return nil
+		}
+		return append(head, tail...)
+	}
+	return nil
+}
+
+// scoreMatch rates the matched positions in path.
+func scoreMatch(path string, nameStart int, pos []int) int {
+	score := 0
+	prev := -1
+	for _, p := range pos {
+		score += scoreChar
+		if p >= nameStart {
+			score += scoreInName
+		}
+		if p == nameStart {
+			score += scoreNameStart
+		}
+		if prev >= 0 {
+			_, size := utf8.DecodeRuneInString(path[prev:])
+			if p == prev+size {
+				score += scoreConsecutive
+			} else {
+				score -= min(utf8.RuneCountInString(path[prev+size:p])*penaltyGap, maxGapPenalty)
+			}
+		}
+		if p > 0 {
+			before, _ := utf8.DecodeLastRuneInString(path[:p])
+			cur, _ := utf8.DecodeRuneInString(path[p:])
+			switch {
+			case strings.ContainsRune("/_-. ", before):
+				score += scoreSegment
+			case unicode.IsLower(before) && unicode.IsUpper(cur):
+				score += scoreCamel
+			}
+		}
+		prev = p
+	}
+	return score
+}
+
+// recencyBonus rates how recently a file was modified, from maxRecency
+// for the last hour down to 0 after about a month.
+func recencyBonus(age int64) int {
+	switch {
+	case age < 3600:
+		return maxRecency
+	case age < 86400:
+		return maxRecency * 2 / 3
+	case age < 7*86400:
+		return maxRecency / 3
+	case age < 30*86400:
+		return 1
+	}
+	return 0
+}
+
+// utf16Offsets converts byte offsets in s to UTF-16 offsets.
+func utf16Offsets(s string, pos []int) []int {
+	out := make([]int, len(pos))
+	units, b := 0, 0
+	for i, p := range pos {
+		for b < p {
+			r, size := utf8.DecodeRuneInString(s[b:])
+			if r >= 0x10000 {
+				units += 2
+			} else {
+				units++
+			}
+			b += size
+		}
+		out[i] = units
+	}
+	return out
+}
@@ -0,0 +1,448 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package index keeps an in-memory index of the paths below the root for
+// finding files by name. The index is built on first use, kept current
+// by watcher events, and dropped again when it has not been used for a
+// while. Directories starting with "." and paths excluded by .gitignore
+// files or hidden by the access rules are not indexed.
+package index
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"lightdev/internal/access"
+	"lightdev/internal/ignore"
+	"lightdev/internal/watcher"
+)
+
+const (
+	// MaxEntries bounds the number of indexed paths. An entry takes
+	// about 100 bytes with its lookup keys, so a full index stays around
+	// 20MB.
+	MaxEntries = 200000
+	// DefaultLimit and MaxLimit bound the results of Find.
+	DefaultLimit = 50
+	MaxLimit     = 500
+	// idleTimeout is how long an unused index is kept.
+	idleTimeout = 30 * time.Minute
+	// staleAfter is how old an index may get before it is rebuilt, if
+	// there is no watcher or it dropped or debounced events.
+	staleAfter = time.Minute
+)
+
+// entry is an indexed path, stored as directory and name so that the
+// directory names are shared.
+type entry struct {
+	// name is empty for a removed entry
+	name  string
+	mtime int64
+	dir   int32
+	isDir bool
+}
+
+// entryKey identifies an entry for removal.
+type entryKey struct {
+	dir  int32
+	name string
+}
+
+// Index is a path index of a directory tree.
+type Index struct {
+	root    string
+	watcher *watcher.Service
+
+	mu sync.Mutex
+	// dirs holds the relative, slash-separated directory paths entries
+	// refer to; "" is the root
+	dirs []string
+	// dirIdx maps the indexed directories to their position in dirs
+	dirIdx  map[string]int32
+	entries []entry
+	// pos maps the live entries to their position in entries, children
+	// lists the positions of the entries in each of dirs
+	pos       map[entryKey]int
+	children  [][]int
+	removed   int
+	ign       *ignore.Matcher
+	built     time.Time
+	dirty     bool
+	truncated bool
+	sub       chan watcher.Event
+	dropped   uint64
+	debounced uint64
+	idle      *time.Timer
+}
+
+// New returns an index of root. w may be nil, in which case the index is
+// rebuilt when it is older than a minute.
+func New(root string, w *watcher.Service) *Index {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Index{root: root, watcher: w}
+}
+
+// Query selects what Find returns.
+type Query struct {
+	// Text is matched fuzzily against the path relative to the root.
+	Text string
+	// Dir restricts the results to a directory, relative to the root.
+	Dir string
+	// Dirs includes directories in the results.
+	Dirs  bool
+	Limit int
+}
+
+// Result is a found path.
+type Result struct {
+	// Path is absolute.
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	IsDir bool   `json:"isDir,omitempty"`
+	Score int    `json:"score"`
+	// Matches are the positions of the matched characters in Path, in
+	// UTF-16 code units.
+	Matches []int `json:"matches"`
+}
+
+// Stats describe the index and a query.
+type Stats struct {
+	// Indexed is the number of indexed paths.
+	Indexed int `json:"indexed"`
+	// Matched is the number of paths that matched, of which Find returns
+	// the best.
+	Matched int `json:"matched"`
+	// Truncated is set if the tree has more than MaxEntries paths.
+	Truncated bool `json:"truncated"`
+}
+
+// Find returns the paths that best match q.
+func (x *Index) Find(q Query) ([]Result, Stats) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	var query []rune
+	for _, r := range q.Text {
+		if !unicode.IsSpace(r) {
+			query = append(query, unicode.ToLower(r))
+		}
+	}
+	dir := strings.Trim(path.Clean("/"+filepath.ToSlash(q.Dir)), "/")
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.ensure()
+	stats := Stats{Indexed: len(x.entries) - x.removed, Truncated: x.truncated}
+
+	type candidate struct {
+		i     int
+		rel   string
+		score int
+		pos   []int
+	}
+	var found []candidate
+	now := time.Now().Unix()
+	policy := access.Default()
+	for i, e := range x.entries {
+		if e.name == "" || (e.isDir && !q.Dirs) {
+			continue
+		}
+		d := x.dirs[e.dir]
+		if dir != "" && d != dir && !strings.HasPrefix(d, dir+"/") {
+			continue
+		}
+		rel := e.name
+		if d != "" {
+			rel = d + "/" + e.name
+		}
+		score, pos, ok := fuzzyMatch(query, rel, len(rel)-len(e.name))
+		if !ok {
+			continue
+		}
+		if policy != nil && policy.Mode(x.abs(rel)) == access.Hidden {
+			continue
+		}
+		if !e.isDir {
+			score += recencyBonus(now - e.mtime)
+		}
+		found = append(found, candidate{i, rel, score, pos})
+	}
+	stats.Matched = len(found)
+	sort.SliceStable(found, func(a, b int) bool {
+		if found[a].score != found[b].score {
+			return found[a].score > found[b].score
+		}
+		return len(found[a].rel) < len(found[b].rel)
+	})
+
+	prefix := filepath.ToSlash(x.root)
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	results := make([]Result, 0, min(q.Limit, len(found)))
+	for _, c := range found {
+		if len(results) == q.Limit {
+			break
+		}
+		// an event may have been missed
+		if _, err := os.Lstat(x.abs(c.rel)); err != nil {
+			x.drop(c.i)
+			stats.Matched--
+			continue
+		}
+		abs := prefix + c.rel
+		pos := make([]int, len(c.pos))
+		for i, p := range c.pos {
+			pos[i] = p + len(prefix)
+		}
+		e := x.entries[c.i]
+		results = append(results, Result{Path: abs, Name: e.name, IsDir: e.isDir, Score: c.score, Matches: utf16Offsets(abs, pos)})
+	}
+	return results, stats
+}
+
+// abs returns the absolute path of a relative one.
+func (x *Index) abs(rel string) string {
+	return filepath.Join(x.root, filepath.FromSlash(rel))
+}
+
+// ensure builds the index if needed and keeps it from being dropped.
+// x.mu must be held.
+func (x *Index) ensure() {
+	if x.watcher != nil && (x.watcher.Dropped() != x.dropped || x.watcher.Debounced() != x.debounced) {
+		// events were lost, or swallowed by the watcher's debounce
+		x.dropped, x.debounced = x.watcher.Dropped(), x.watcher.Debounced()
+		x.dirty = true
+	}
+	stale := x.watcher == nil || x.dirty
+	if x.entries == nil || (stale && time.Since(x.built) > staleAfter) {
+		x.build()
+	}
+	if x.idle == nil {
+		x.idle = time.AfterFunc(idleTimeout, x.release)
+	} else {
+		x.idle.Reset(idleTimeout)
+	}
+}
+
+// build indexes the tree. x.mu must be held.
+func (x *Index) build() {
+	start := time.Now()
+	x.dirs = []string{""}
+	x.dirIdx = map[string]int32{"": 0}
+	x.entries = make([]entry, 0, 1024)
+	x.pos = make(map[entryKey]int, 1024)
+	x.children = [][]int{nil}
+	x.removed = 0
+	x.truncated = false
+	x.ign = ignore.New(x.root)
+	if x.watcher != nil && x.sub == nil {
+		x.sub = x.watcher.Subscribe()
+		x.dropped, x.debounced = x.watcher.Dropped(), x.watcher.Debounced()
+		go x.consume(x.sub)
+	}
+	x.ign.Enter(x.root)
+	x.walk(x.root)
+	x.built = time.Now()
+	x.dirty = false
+	log.Printf("[DEBUG] index: %d paths indexed in %s", len(x.entries), time.Since(start).Round(time.Millisecond))
+	if x.truncated {
+		log.Printf("[WARNING] index: more than %d paths below %s, index is incomplete", MaxEntries, x.root)
+	}
+}
+
+// walk indexes the contents of the directory start. x.mu must be held.
+func (x *Index) walk(start string) {
+	filepath.WalkDir(start, func(name string, d fs.DirEntry, err error) error {
+		if name == start {
+			return nil
+		}
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		isDir := d.IsDir()
+		if x.skip(name, d.Name(), isDir) {
+			if isDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		var mtime int64
+		if fi, err := d.Info(); err == nil {
+			mtime = fi.ModTime().Unix()
+		}
+		if !x.add(name, isDir, mtime) {
+			return fs.SkipAll
+		}
+		if isDir {
+			x.ign.Enter(name)
+		}
+		return nil
+	})
+}
+
+// skip reports whether name is left out of the index. Directories
+// starting with "." are not watched, so they are not indexed either.
+func (x *Index) skip(name, base string, isDir bool) bool {
+	if isDir && strings.HasPrefix(base, ".") {
+		return true
+	}
+	if p := access.Default(); p != nil && p.Mode(name) == access.Hidden {
+		return true
+	}
+	return x.ign.Ignored(name, isDir)
+}
+
+// add indexes the absolute path name. It reports false if the index is
+// full.
+func (x *Index) add(name string, isDir bool, mtime int64) bool {
+	if len(x.entries)-x.removed >= MaxEntries {
+		x.truncated = true
+		return false
+	}
+	rel, err := filepath.Rel(x.root, name)
+	if err != nil {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	dir, base := path.Split(rel)
+	id := x.dirID(strings.TrimSuffix(dir, "/"))
+	if isDir {
+		x.dirID(rel)
+	}
+	i := len(x.entries)
+	x.entries = append(x.entries, entry{name: base, mtime: mtime, dir: id, isDir: isDir})
+	x.pos[entryKey{id, base}] = i
+	x.children[id] = append(x.children[id], i)
+	return true
+}
+
+// dirID returns the position of the directory dir in dirs, adding it if
+// needed. x.mu must be held.
+func (x *Index) dirID(dir string) int32 {
+	if id, ok := x.dirIdx[dir]; ok {
+		return id
+	}
+	id := int32(len(x.dirs))
+	x.dirs = append(x.dirs, dir)
+	x.children = append(x.children, nil)
+	x.dirIdx[dir] = id
+	return id
+}
+
+// drop marks the entry at position i as removed. x.mu must be held.
+func (x *Index) drop(i int) {
+	e := &x.entries[i]
+	if e.name == "" {
+		return
+	}
+	delete(x.pos, entryKey{e.dir, e.name})
+	e.name = ""
+	x.removed++
+}
+
+// consume applies watcher events until the subscription ends.
+func (x *Index) consume(ch chan watcher.Event) {
+	for ev := range ch {
+		if ev.Type == watcher.EventFileChange {
+			x.update(ev.Path)
+		}
+	}
+}
+
+// update re-indexes a path, given relative to the root, after it
+// changed.
+func (x *Index) update(p string) {
+	rel := strings.Trim(path.Clean("/"+p), "/")
+	if rel == "" {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.entries == nil {
+		return
+	}
+	x.remove(rel)
+	dir, base := path.Split(rel)
+	if base == ".gitignore" {
+		// which paths are indexed changes
+		x.ign.Forget(x.abs(strings.TrimSuffix(dir, "/")))
+		x.dirty = true
+		return
+	}
+	if _, ok := x.dirIdx[strings.TrimSuffix(dir, "/")]; !ok {
+		// the parent is not indexed
+		return
+	}
+	name := x.abs(rel)
+	fi, err := os.Lstat(name)
+	if err != nil || x.skip(name, base, fi.IsDir()) {
+		return
+	}
+	if !x.add(name, fi.IsDir(), fi.ModTime().Unix()) {
+		return
+	}
+	if fi.IsDir() {
+		x.ign.Enter(name)
+		x.walk(name)
+	}
+	if x.removed > len(x.entries)/2 {
+		// reclaim the removed entries with the next query
+		x.dirty = true
+	}
+}
+
+// remove drops rel and, for a directory, its contents. x.mu must be
+// held.
+func (x *Index) remove(rel string) {
+	dir, base := path.Split(rel)
+	if id, ok := x.dirIdx[strings.TrimSuffix(dir, "/")]; ok {
+		if i, ok := x.pos[entryKey{id, base}]; ok {
+			x.drop(i)
+		}
+	}
+	if _, ok := x.dirIdx[rel]; !ok {
+		return
+	}
+	for d, id := range x.dirIdx {
+		if d == rel || strings.HasPrefix(d, rel+"/") {
+			for _, i := range x.children[id] {
+				x.drop(i)
+			}
+			x.children[id] = nil
+			delete(x.dirIdx, d)
+		}
+	}
+}
+
+// release drops the index after it was not used for idleTimeout.
+func (x *Index) release() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.sub != nil {
+		x.watcher.Unsubscribe(x.sub)
+		x.sub = nil
+	}
+	x.dirs, x.dirIdx, x.entries, x.ign = nil, nil, nil, nil
+	x.pos, x.children = nil, nil
+	x.removed = 0
+	x.idle = nil
+	log.Printf("[DEBUG] index: dropped after %s without use", idleTimeout)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"lightdev/internal/watcher"
+)
+
+// paths returns the indexed files and directories, relative to the root
+// and sorted, as found by an empty query.
+func paths(t *testing.T, x *Index) []string {
+	t.Helper()
+	res, _ := x.Find(Query{Dirs: true, Limit: MaxLimit})
+	var out []string
+	for _, r := range res {
+		rel, err := filepath.Rel(x.root, r.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = filepath.ToSlash(rel)
+		if r.IsDir {
+			rel += "/"
+		}
+		out = append(out, rel)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func mkfile(t *testing.T, root, rel string) {
+	t.Helper()
+	name := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// event delivers a file change event for rel as the watcher would.
+func event(x *Index, rel string) {
+	ch := make(chan watcher.Event, 1)
+	ch <- watcher.Event{Type: watcher.EventFileChange, Path: "/" + rel}
+	close(ch)
+	x.consume(ch)
+}
+
+func TestIndexUpdates(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"a.go", "src/b.go", "src/lib/c.go", ".hidden/d.go", "tmp/e.log"} {
+		mkfile(t, root, f)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	x := New(root, nil)
+	t.Cleanup(func() {
+		if x.idle != nil {
+			x.idle.Stop()
+		}
+	})
+
+	steps := []struct {
+		name string
+		// change modifies the tree and returns the paths the watcher
+		// reports
+		change func() []string
+		want   []string
+	}{
+		{"build", func() []string { return nil },
+			[]string{".gitignore", "a.go", "src/", "src/b.go", "src/lib/", "src/lib/c.go", "tmp/"}},
+		{"add file", func() []string {
+			mkfile(t, root, "src/new.go")
+			return []string{"src/new.go"}
+		}, []string{".gitignore", "a.go", "src/", "src/b.go", "src/lib/", "src/lib/c.go", "src/new.go", "tmp/"}},
+		{"add ignored file", func() []string {
+			mkfile(t, root, "src/x.log")
+			return []string{"src/x.log"}
+		}, []string{".gitignore", "a.go", "src/", "src/b.go", "src/lib/", "src/lib/c.go", "src/new.go", "tmp/"}},
+		{"add directory with contents", func() []string {
+			mkfile(t, root, "pkg/sub/f.go")
+			return []string{"pkg"}
+		}, []string{".gitignore", "a.go", "pkg/", "pkg/sub/", "pkg/sub/f.go", "src/", "src/b.go", "src/lib/", "src/lib/c.go", "src/new.go", "tmp/"}},
+		{"remove file", func() []string {
+			os.Remove(filepath.Join(root, "src", "new.go"))
+			return []string{"src/new.go"}
+		}, []string{".gitignore", "a.go", "pkg/", "pkg/sub/", "pkg/sub/f.go", "src/", "src/b.go", "src/lib/", "src/lib/c.go", "tmp/"}},
+		{"rename file", func() []string {
+			os.Rename(filepath.Join(root, "a.go"), filepath.Join(root, "src", "a2.go"))
+			return []string{"a.go", "src/a2.go"}
+		}, []string{".gitignore", "pkg/", "pkg/sub/", "pkg/sub/f.go", "src/", "src/a2.go", "src/b.go", "src/lib/", "src/lib/c.go", "tmp/"}},
+		{"rename directory", func() []string {
+			os.Rename(filepath.Join(root, "src"), filepath.Join(root, "lib"))
+			return []string{"src", "lib"}
+		}, []string{".gitignore", "lib/", "lib/a2.go", "lib/b.go", "lib/lib/", "lib/lib/c.go", "pkg/", "pkg/sub/", "pkg/sub/f.go", "tmp/"}},
+		{"remove directory", func() []string {
+			os.RemoveAll(filepath.Join(root, "pkg"))
+			return []string{"pkg"}
+		}, []string{".gitignore", "lib/", "lib/a2.go", "lib/b.go", "lib/lib/", "lib/lib/c.go", "tmp/"}},
+		{"file replaced by directory of the same name", func() []string {
+			os.Remove(filepath.Join(root, "lib", "b.go"))
+			mkfile(t, root, "lib/b.go/g.go")
+			return []string{"lib/b.go"}
+		}, []string{".gitignore", "lib/", "lib/a2.go", "lib/b.go/", "lib/b.go/g.go", "lib/lib/", "lib/lib/c.go", "tmp/"}},
+	}
+	for _, st := range steps {
+		for _, p := range st.change() {
+			event(x, p)
+		}
+		if got := paths(t, x); !reflect.DeepEqual(got, st.want) {
+			t.Fatalf("%s: indexed %q\nwant %q", st.name, got, st.want)
+		}
+	}
+
+	// a missed event: the file is gone, Find notices and drops it
+	os.Remove(filepath.Join(root, "lib", "a2.go"))
+	res, stats := x.Find(Query{Text: "a2"})
+	if len(res) != 0 || stats.Matched != 0 {
+		t.Errorf("Find returned a removed file: %+v %+v", res, stats)
+	}
+	if got := paths(t, x); strings.Contains(strings.Join(got, " "), "a2.go") {
+		t.Errorf("removed file still indexed: %q", got)
+	}
+}
+
+func TestIndexFind(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"src/server/server.go", "src/server/server_test.go", "doc/server.md", "cmd/main.go"} {
+		mkfile(t, root, f)
+	}
+	x := New(root, nil)
+	t.Cleanup(func() {
+		if x.idle != nil {
+			x.idle.Stop()
+		}
+	})
+
+	res, stats := x.Find(Query{Text: "srvgo"})
+	if stats.Indexed != 8 || stats.Matched != 2 || len(res) != 2 {
+		t.Fatalf("Find(srvgo) = %+v %+v", res, stats)
+	}
+	if want := filepath.ToSlash(filepath.Join(root, "src/server/server.go")); res[0].Path != want {
+		t.Errorf("best match %s, want %s", res[0].Path, want)
+	}
+	if res, _ := x.Find(Query{Text: "server", Dir: "doc"}); len(res) != 1 || res[0].Name != "server.md" {
+		t.Errorf("Find(server in doc) = %+v", res)
+	}
+	if res, _ := x.Find(Query{Text: "server", Limit: 1}); len(res) != 1 {
+		t.Errorf("Find with limit 1 returned %d results", len(res))
+	}
+}
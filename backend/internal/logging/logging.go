@@ -0,0 +1,232 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package logging adds levels, rotation and live following to the agent's
+// standard logger. Code keeps using log.Printf; the level of a line is
+// taken from its tag ("[DEBUG]", "[WARNING]", ...), untagged lines are info.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses "debug", "info", "warn"/"warning" or "error".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", s)
+}
+
+// tagLevels maps the tags used in log lines to levels. High-volume tags
+// (file watcher, event stream) are debug output.
+var tagLevels = map[string]Level{
+	"[DEBUG]":   LevelDebug,
+	"[WATCHER]": LevelDebug,
+	"[SSE]":     LevelDebug,
+	"[INFO]":    LevelInfo,
+	"[ACCESS]":  LevelInfo,
+	"[WARN]":    LevelWarn,
+	"[WARNING]": LevelWarn,
+	"[ERROR]":   LevelError,
+	"[FATAL]":   LevelError,
+}
+
+// LevelOf returns the level of a formatted log line by looking for a tag
+// near its start (after the timestamp added by the log package).
+func LevelOf(line string) Level {
+	head := line
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	i := strings.IndexByte(head, '[')
+	if i < 0 {
+		return LevelInfo
+	}
+	j := strings.IndexByte(head[i:], ']')
+	if j < 0 {
+		return LevelInfo
+	}
+	if l, ok := tagLevels[strings.ToUpper(head[i:i+j+1])]; ok {
+		return l
+	}
+	return LevelInfo
+}
+
+// Line is a log line as kept for followers.
+type Line struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+
+	level Level
+}
+
+// ParseLine returns msg as a Line with the level taken from its tag.
+func ParseLine(msg string) Line {
+	level := LevelOf(msg)
+	return Line{Time: time.Now(), Level: level.String(), Message: msg, level: level}
+}
+
+// Filter selects lines for followers. The zero value matches everything.
+type Filter struct {
+	Level    Level
+	Contains string
+}
+
+// Match reports whether l passes the filter.
+func (f Filter) Match(l Line) bool {
+	if l.level < f.Level {
+		return false
+	}
+	return f.Contains == "" || strings.Contains(strings.ToLower(l.Message), strings.ToLower(f.Contains))
+}
+
+// backlogSize is the number of recent lines replayed to new followers.
+const backlogSize = 1000
+
+// Logger is an io.Writer for the log package that drops lines below the
+// current level, writes the rest to its outputs and hands them to
+// followers.
+type Logger struct {
+	mu        sync.Mutex
+	level     Level
+	outputs   []io.Writer
+	backlog   []Line
+	next      int
+	followers map[chan Line]Filter
+}
+
+// New returns a Logger writing to outputs.
+func New(level Level, outputs ...io.Writer) *Logger {
+	return &Logger{level: level, outputs: outputs, followers: map[chan Line]Filter{}}
+}
+
+// SetLevel changes the minimum level at runtime.
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	l.level = level
+	l.mu.Unlock()
+}
+
+// Level returns the current minimum level.
+func (l *Logger) Level() Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
+}
+
+// Write implements io.Writer. The log package calls it once per line.
+func (l *Logger) Write(p []byte) (int, error) {
+	line := ParseLine(strings.TrimRight(string(p), "\n"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if line.level < l.level {
+		return len(p), nil
+	}
+	for _, w := range l.outputs {
+		_, _ = w.Write(p)
+	}
+
+	if len(l.backlog) < backlogSize {
+		l.backlog = append(l.backlog, line)
+	} else {
+		l.backlog[l.next] = line
+		l.next = (l.next + 1) % backlogSize
+	}
+	for ch, f := range l.followers {
+		if !f.Match(line) {
+			continue
+		}
+		select {
+		case ch <- line:
+		default:
+			// follower too slow, drop the line rather than block logging
+		}
+	}
+	return len(p), nil
+}
+
+// Recent returns up to n of the most recent lines matching f, oldest first.
+func (l *Logger) Recent(n int, f Filter) []Line {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ordered := append(append([]Line(nil), l.backlog[l.next:]...), l.backlog[:l.next]...)
+	var out []Line
+	for i := len(ordered) - 1; i >= 0 && len(out) < n; i-- {
+		if f.Match(ordered[i]) {
+			out = append(out, ordered[i])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Follow subscribes to new lines matching f. Call Unfollow when done.
+func (l *Logger) Follow(f Filter) chan Line {
+	ch := make(chan Line, 256)
+	l.mu.Lock()
+	l.followers[ch] = f
+	l.mu.Unlock()
+	return ch
+}
+
+// Unfollow ends a subscription made with Follow.
+func (l *Logger) Unfollow(ch chan Line) {
+	l.mu.Lock()
+	delete(l.followers, ch)
+	l.mu.Unlock()
+}
+
+var (
+	stdMu sync.RWMutex
+	std   *Logger
+)
+
+// SetDefault makes l the logger used by the /api/logs handlers.
+func SetDefault(l *Logger) {
+	stdMu.Lock()
+	std = l
+	stdMu.Unlock()
+}
+
+// Default returns the logger set with SetDefault, or nil.
+func Default() *Logger {
+	stdMu.RLock()
+	defer stdMu.RUnlock()
+	return std
+}
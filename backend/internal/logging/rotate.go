@@ -0,0 +1,162 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for RotatingFile.
+const (
+	DefaultMaxSize    = 10 << 20
+	DefaultMaxBackups = 5
+	DefaultMaxAge     = 7 * 24 * time.Hour
+)
+
+// DefaultDir returns ~/.mlcremote/logs.
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.TempDir()
+	}
+	return filepath.Join(home, ".mlcremote", "logs")
+}
+
+// RotatingFile writes to Dir/agent.log. A file larger than MaxSize is
+// renamed to agent-<timestamp>.log; rotated files beyond MaxBackups or
+// older than MaxAge are deleted.
+type RotatingFile struct {
+	Dir        string
+	MaxSize    int64
+	MaxBackups int
+	MaxAge     time.Duration
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens (or creates) Dir/agent.log for appending.
+// Non-positive limits use the defaults.
+func OpenRotatingFile(dir string, maxSize int64, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	rf := &RotatingFile{Dir: dir, MaxSize: maxSize, MaxBackups: maxBackups, MaxAge: maxAge}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	rf.prune()
+	return rf, nil
+}
+
+// Path returns the path of the active log file.
+func (rf *RotatingFile) Path() string {
+	return filepath.Join(rf.Dir, "agent.log")
+}
+
+func (rf *RotatingFile) open() error {
+	if err := os.MkdirAll(rf.Dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rf.Path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, fi.Size()
+	return nil
+}
+
+// Write implements io.Writer.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	if rf.size+int64(len(p)) > rf.MaxSize && rf.size > 0 {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) rotate() error {
+	rf.f.Close()
+	rf.f = nil
+	name := filepath.Join(rf.Dir, "agent-"+time.Now().Format("20060102-150405.000")+".log")
+	if err := os.Rename(rf.Path(), name); err != nil {
+		return err
+	}
+	rf.prune()
+	return rf.open()
+}
+
+// prune deletes rotated files beyond MaxBackups or older than MaxAge.
+func (rf *RotatingFile) prune() {
+	backups := rf.Backups()
+	cutoff := time.Now().Add(-rf.MaxAge)
+	for i, b := range backups {
+		fi, err := os.Stat(b)
+		if err != nil {
+			continue
+		}
+		// backups are sorted newest first
+		if i >= rf.MaxBackups || fi.ModTime().Before(cutoff) {
+			os.Remove(b)
+		}
+	}
+}
+
+// Backups returns the rotated files, newest first.
+func (rf *RotatingFile) Backups() []string {
+	entries, err := os.ReadDir(rf.Dir)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "agent-") && strings.HasSuffix(name, ".log") {
+			out = append(out, filepath.Join(rf.Dir, name))
+		}
+	}
+	// the timestamp in the name sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(out)))
+	return out
+}
+
+// Close closes the active file.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
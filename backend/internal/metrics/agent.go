@@ -0,0 +1,103 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// Agent metrics updated by the server and handlers.
+var (
+	HTTPRequests = Default.NewCounterVec("mlcremote_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "code")
+	HTTPDuration = Default.NewHistogramVec("mlcremote_http_request_duration_seconds",
+		"HTTP request latency by route and method.", DefBuckets, "route", "method")
+	UploadBytes = Default.NewCounter("mlcremote_upload_bytes_total",
+		"Bytes received through file uploads.")
+)
+
+var startTime = time.Now()
+
+func init() {
+	Default.GaugeFunc("mlcremote_start_time_seconds", "Unix time the agent was started.", func() float64 {
+		return float64(startTime.Unix())
+	})
+	Default.Register(runtimeCollector{})
+}
+
+// runtimeCollector exposes Go runtime statistics.
+type runtimeCollector struct{}
+
+func (runtimeCollector) Collect(w io.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	gauge := func(name, help string, v float64) {
+		WriteHeader(w, name, help, "gauge")
+		WriteSample(w, name, nil, v)
+	}
+	gauge("go_goroutines", "Number of goroutines.", float64(runtime.NumGoroutine()))
+	gauge("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.", float64(ms.HeapAlloc))
+	gauge("go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", float64(ms.Sys))
+	WriteHeader(w, "go_gc_cycles_total", "Completed GC cycles.", "counter")
+	WriteSample(w, "go_gc_cycles_total", nil, float64(ms.NumGC))
+	WriteHeader(w, "go_gc_pause_seconds_total", "Total GC stop-the-world pause time.", "counter")
+	WriteSample(w, "go_gc_pause_seconds_total", nil, float64(ms.PauseTotalNs)/1e9)
+}
+
+// HostCollector exposes CPU, memory, load, uptime and the disk usage of
+// the file system holding Root, read from gopsutil at scrape time.
+type HostCollector struct {
+	Root string
+}
+
+func (h HostCollector) Collect(w io.Writer) {
+	gauge := func(name, help string, v float64) {
+		WriteHeader(w, name, help, "gauge")
+		WriteSample(w, name, nil, v)
+	}
+	// interval 0 compares against the previous call, so scrapes do not block
+	if pct, err := cpu.Percent(0, false); err == nil && len(pct) > 0 {
+		gauge("mlcremote_host_cpu_percent", "Host CPU usage since the previous scrape.", pct[0])
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		gauge("mlcremote_host_memory_total_bytes", "Total host memory.", float64(vm.Total))
+		gauge("mlcremote_host_memory_used_bytes", "Used host memory.", float64(vm.Used))
+	}
+	if avg, err := load.Avg(); err == nil {
+		WriteHeader(w, "mlcremote_host_load", "Host load average.", "gauge")
+		WriteSample(w, "mlcremote_host_load", []string{"period", "1m"}, avg.Load1)
+		WriteSample(w, "mlcremote_host_load", []string{"period", "5m"}, avg.Load5)
+		WriteSample(w, "mlcremote_host_load", []string{"period", "15m"}, avg.Load15)
+	}
+	if up, err := host.Uptime(); err == nil {
+		gauge("mlcremote_host_uptime_seconds", "Host uptime.", float64(up))
+	}
+	if h.Root != "" {
+		if du, err := disk.Usage(h.Root); err == nil {
+			gauge("mlcremote_disk_total_bytes", "Size of the file system holding the workspace root.", float64(du.Total))
+			gauge("mlcremote_disk_used_bytes", "Used space on the file system holding the workspace root.", float64(du.Used))
+		}
+	}
+}
+
+// Handler serves the metrics of reg in the Prometheus text format.
+func Handler(reg *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		reg.Write(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
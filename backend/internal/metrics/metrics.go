@@ -0,0 +1,278 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package metrics implements the small subset of the Prometheus client
+// the agent needs: counters, histograms and callback gauges, exposed in
+// the Prometheus text format (which OpenMetrics scrapers accept as well).
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Collector writes one or more metric families in text format.
+type Collector interface {
+	Collect(w io.Writer)
+}
+
+// Registry holds the collectors exposed by Handler.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the registry the agent's metrics are registered in.
+var Default = NewRegistry()
+
+// Register adds c to the registry.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Write writes every registered metric to w.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	cs := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range cs {
+		c.Collect(w)
+	}
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	name, help string
+	v          atomic.Uint64
+}
+
+// NewCounter registers a counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.Register(c)
+	return c
+}
+
+// Add increases the counter by n.
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+
+// Inc increases the counter by one.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+func (c *Counter) Collect(w io.Writer) {
+	WriteHeader(w, c.name, c.help, "counter")
+	WriteSample(w, c.name, nil, float64(c.v.Load()))
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*labeled
+}
+
+type labeled struct {
+	values []string
+	v      float64
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]*labeled{}}
+	r.Register(c)
+	return c
+}
+
+// Inc increases the counter for the label values by one. The values must
+// be given in the order of the label names.
+func (c *CounterVec) Inc(values ...string) {
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	e, ok := c.values[key]
+	if !ok {
+		e = &labeled{values: append([]string(nil), values...)}
+		c.values[key] = e
+	}
+	e.v++
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) Collect(w io.Writer) {
+	WriteHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		e := c.values[key]
+		WriteSample(w, c.name, labelPairs(c.labels, e.values), e.v)
+	}
+}
+
+// DefBuckets are the default latency buckets in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histSeries
+}
+
+type histSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given buckets (upper
+// bounds, ascending) and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histSeries{}}
+	r.Register(h)
+	return h
+}
+
+// Observe adds v to the histogram for the label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) Collect(w io.Writer) {
+	WriteHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		pairs := labelPairs(h.labels, s.values)
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += s.counts[i]
+			WriteSample(w, h.name+"_bucket", append(pairs, "le", formatFloat(b)), float64(cum))
+		}
+		WriteSample(w, h.name+"_bucket", append(pairs, "le", "+Inf"), float64(s.count))
+		WriteSample(w, h.name+"_sum", pairs, s.sum)
+		WriteSample(w, h.name+"_count", pairs, float64(s.count))
+	}
+}
+
+// funcMetric reads its value from a callback at scrape time.
+type funcMetric struct {
+	name, help, typ string
+	fn              func() float64
+}
+
+// GaugeFunc registers a gauge whose value is returned by fn.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.Register(&funcMetric{name: name, help: help, typ: "gauge", fn: fn})
+}
+
+// CounterFunc registers a counter whose value is returned by fn.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.Register(&funcMetric{name: name, help: help, typ: "counter", fn: fn})
+}
+
+func (m *funcMetric) Collect(w io.Writer) {
+	WriteHeader(w, m.name, m.help, m.typ)
+	WriteSample(w, m.name, nil, m.fn())
+}
+
+// WriteHeader writes the HELP and TYPE lines of a metric family.
+func WriteHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// WriteSample writes one sample. labels alternates names and values.
+func WriteSample(w io.Writer, name string, labels []string, v float64) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(escapeLabel(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+	io.WriteString(w, b.String())
+}
+
+func labelPairs(names, values []string) []string {
+	pairs := make([]string, 0, 2*len(names)+2)
+	for i, n := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs = append(pairs, n, v)
+	}
+	return pairs
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package search
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Replacement is a match and the text that would replace it.
+type Replacement struct {
+	// Index is the 0-based position of the match among all matches in the
+	// file. Replace selects matches by it.
+	Index int `json:"index"`
+	// Line is 1-based; Start and End are in UTF-16 code units.
+	Line        int    `json:"line"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Text        string `json:"text"`
+	Replacement string `json:"replacement"`
+	// Before is the line as it is, After the line with only this match
+	// replaced.
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// Truncated is set if Before and After were shortened.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// FileReplacements are the replacements in one file.
+type FileReplacements struct {
+	Path string `json:"path"`
+	// Version is the version of the file the matches were found in.
+	Version string        `json:"version"`
+	Matches []Replacement `json:"matches"`
+	// Count is the number of matches in the file, which may be more than
+	// are listed.
+	Count int `json:"count"`
+}
+
+// Replacer replaces the matches of a query.
+type Replacer struct {
+	re      *regexp.Regexp
+	repl    string
+	literal bool
+}
+
+// Replacer returns a Replacer for the query of o. In regex mode repl may
+// refer to capture groups as $1 or ${name}; otherwise it is literal.
+func (o *Options) Replacer(repl string) (*Replacer, error) {
+	re, err := o.Compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Replacer{re: re, repl: repl, literal: !o.Regex}, nil
+}
+
+// expand returns the replacement for the match loc in line.
+func (x *Replacer) expand(line string, loc []int) string {
+	if x.literal {
+		return x.repl
+	}
+	return string(x.re.ExpandString(nil, x.repl, line, loc))
+}
+
+// Replace replaces the matches of text whose Index is in selected, or all
+// of them if selected is nil. Matches do not span lines and line endings
+// are kept. It returns the new text and the number of replacements.
+func (x *Replacer) Replace(text string, selected []int) (string, int) {
+	var sel map[int]bool
+	if selected != nil {
+		sel = make(map[int]bool, len(selected))
+		for _, i := range selected {
+			sel[i] = true
+		}
+	}
+	var b strings.Builder
+	last, index, n := 0, 0, 0
+	for _, lb := range lineBounds(text) {
+		line := text[lb[0]:lb[1]]
+		for _, loc := range x.re.FindAllStringSubmatchIndex(line, -1) {
+			if sel == nil || sel[index] {
+				b.WriteString(text[last : lb[0]+loc[0]])
+				b.WriteString(x.expand(line, loc))
+				last = lb[0] + loc[1]
+				n++
+			}
+			index++
+		}
+	}
+	if n == 0 {
+		return text, 0
+	}
+	b.WriteString(text[last:])
+	return b.String(), n
+}
+
+// Preview finds the matches of opts below dir like Search and calls fn
+// for each file with the replacements repl would make. MaxResults and
+// MaxPerFile bound the listed matches; Context is not used.
+func Preview(ctx context.Context, dir string, opts Options, repl string, fn func(FileReplacements) error) (Summary, error) {
+	start := time.Now()
+	var sum Summary
+	x, err := opts.Replacer(repl)
+	if err != nil {
+		return sum, err
+	}
+	opts.normalize()
+	err = walk(ctx, dir, &opts, func(name string) error {
+		return previewFile(name, x, &opts, &sum, fn)
+	})
+	sum.ElapsedMs = time.Since(start).Milliseconds()
+	if errors.Is(err, errLimit) {
+		sum.Truncated = true
+		err = nil
+	}
+	return sum, err
+}
+
+// previewFile reports the replacements in one file.
+func previewFile(name string, x *Replacer, opts *Options, sum *Summary, fn func(FileReplacements) error) error {
+	text, version, ok := readText(name, sum)
+	if !ok {
+		return nil
+	}
+	f := FileReplacements{Path: name, Version: version}
+	var limited error
+	for i, lb := range lineBounds(text) {
+		line := text[lb[0]:lb[1]]
+		for _, loc := range x.re.FindAllStringSubmatchIndex(line, -1) {
+			f.Count++
+			if len(f.Matches) >= opts.MaxPerFile || limited != nil {
+				continue
+			}
+			if sum.Matches >= opts.MaxResults {
+				limited = errLimit
+				continue
+			}
+			rep := x.expand(line, loc)
+			m := Replacement{
+				Index:       f.Count - 1,
+				Line:        i + 1,
+				Start:       utf16Len(line[:loc[0]]),
+				End:         utf16Len(line[:loc[1]]),
+				Text:        line[loc[0]:loc[1]],
+				Replacement: rep,
+				Before:      line,
+				After:       line[:loc[0]] + rep + line[loc[1]:],
+			}
+			if len(m.Before) > maxLineLength || len(m.After) > maxLineLength {
+				m.Before, m.After, m.Truncated = truncate(m.Before, maxLineLength), truncate(m.After, maxLineLength), true
+			}
+			f.Matches = append(f.Matches, m)
+			sum.Matches++
+		}
+	}
+	if len(f.Matches) == 0 {
+		return limited
+	}
+	sum.FilesMatched++
+	if err := fn(f); err != nil {
+		return err
+	}
+	return limited
+}
+
+// lineBounds returns the start and end of each line of text, without its
+// line ending, splitting lines like splitLines.
+func lineBounds(text string) [][2]int {
+	var out [][2]int
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '\n' && c != '\r' {
+			continue
+		}
+		out = append(out, [2]int{start, i})
+		if c == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+			i++
+		}
+		start = i + 1
+	}
+	if start < len(text) {
+		out = append(out, [2]int{start, len(text)})
+	}
+	return out
+}
@@ -0,0 +1,346 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+// Package search finds text in the files below a directory, like grep -r.
+// Files are decoded with their detected encoding, binary files are
+// skipped, and .gitignore files and the access rules are honoured.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"lightdev/internal/access"
+	"lightdev/internal/ignore"
+	"lightdev/internal/util"
+)
+
+// Limits applied to Options.
+const (
+	DefaultMaxResults = 1000
+	MaxResults        = 10000
+	DefaultMaxPerFile = 100
+	MaxContext        = 10
+	// MaxFileSize is the largest file searched.
+	MaxFileSize = 10 << 20
+	// maxLineLength is the longest line returned in a match.
+	maxLineLength = 1000
+)
+
+// Options select what and where to search.
+type Options struct {
+	Query string
+	// Regex treats Query as a regular expression (RE2 syntax).
+	Regex         bool
+	CaseSensitive bool
+	WholeWord     bool
+	// Include and Exclude are globs such as "*.go" or "src/**/*.ts".
+	// A glob without "/" matches the file name, others the path
+	// relative to the searched directory. "**" matches any number of
+	// directories.
+	Include []string
+	Exclude []string
+	// NoIgnore searches files excluded by .gitignore files.
+	NoIgnore bool
+	// Hidden searches files and directories starting with ".".
+	Hidden bool
+	// MaxResults bounds the matching lines reported in total, MaxPerFile
+	// those of one file.
+	MaxResults int
+	MaxPerFile int
+	// Context is the number of lines reported before and after a match.
+	Context int
+}
+
+// Submatch is where the query matched in a line, in UTF-16 code units
+// as in the Language Server Protocol.
+type Submatch struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Match is a line that matched.
+type Match struct {
+	Path string `json:"path"`
+	// Line is 1-based.
+	Line       int        `json:"line"`
+	Text       string     `json:"text"`
+	Submatches []Submatch `json:"submatches"`
+	// Truncated is set if Text was shortened.
+	Truncated bool     `json:"truncated,omitempty"`
+	Before    []string `json:"before,omitempty"`
+	After     []string `json:"after,omitempty"`
+}
+
+// Summary describes a finished search.
+type Summary struct {
+	FilesSearched int `json:"filesSearched"`
+	FilesMatched  int `json:"filesMatched"`
+	Matches       int `json:"matches"`
+	// Skipped counts binary files and files over MaxFileSize.
+	Skipped int `json:"skipped"`
+	// Truncated is set if MaxResults stopped the search.
+	Truncated bool  `json:"truncated"`
+	ElapsedMs int64 `json:"elapsedMs"`
+}
+
+// errLimit stops the walk once MaxResults is reached.
+var errLimit = errors.New("result limit reached")
+
+// Compile returns the regular expression for the query of o.
+func (o *Options) Compile() (*regexp.Regexp, error) {
+	if o.Query == "" {
+		return nil, errors.New("query required")
+	}
+	expr := o.Query
+	if o.Regex {
+		// report errors in terms of the query
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+	} else {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if o.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if !o.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// normalize applies the defaults and limits.
+func (o *Options) normalize() {
+	if o.MaxResults <= 0 {
+		o.MaxResults = DefaultMaxResults
+	}
+	if o.MaxResults > MaxResults {
+		o.MaxResults = MaxResults
+	}
+	if o.MaxPerFile <= 0 {
+		o.MaxPerFile = DefaultMaxPerFile
+	}
+	if o.Context < 0 {
+		o.Context = 0
+	}
+	if o.Context > MaxContext {
+		o.Context = MaxContext
+	}
+}
+
+// Search searches the files below dir and calls fn for each match. It
+// stops when ctx is done, when fn returns an error, or when
+// opts.MaxResults is reached. Symlinks are not followed.
+func Search(ctx context.Context, dir string, opts Options, fn func(Match) error) (Summary, error) {
+	start := time.Now()
+	var sum Summary
+	re, err := opts.Compile()
+	if err != nil {
+		return sum, err
+	}
+	opts.normalize()
+	err = walk(ctx, dir, &opts, func(name string) error {
+		return searchFile(name, re, &opts, &sum, fn)
+	})
+	sum.ElapsedMs = time.Since(start).Milliseconds()
+	if errors.Is(err, errLimit) {
+		sum.Truncated = true
+		err = nil
+	}
+	return sum, err
+}
+
+// walk calls fn for the files below dir that opts select.
+func walk(ctx context.Context, dir string, opts *Options, fn func(name string) error) error {
+	ig := ignore.New(dir)
+	policy := access.Default()
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// unreadable directories are left out
+			if d != nil && d.IsDir() && name != dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		isDir := d.IsDir()
+		if name != dir {
+			if skip(name, d, dir, opts, ig, policy) {
+				if isDir {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		if isDir {
+			if !opts.NoIgnore {
+				ig.Enter(name)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || policy.Mode(name) >= access.Deny {
+			return nil
+		}
+		if len(opts.Include) > 0 && !ignore.MatchAny(opts.Include, name, dir) {
+			return nil
+		}
+		return fn(name)
+	})
+}
+
+// skip reports whether the walk leaves out name.
+func skip(name string, d fs.DirEntry, dir string, opts *Options, ig *ignore.Matcher, policy *access.Policy) bool {
+	base := d.Name()
+	if base == ".git" || (!opts.Hidden && strings.HasPrefix(base, ".")) {
+		return true
+	}
+	if d.Type()&fs.ModeSymlink != 0 {
+		return true
+	}
+	if policy.Mode(name) == access.Hidden {
+		return true
+	}
+	if ignore.MatchAny(opts.Exclude, name, dir) {
+		return true
+	}
+	return !opts.NoIgnore && ig.Ignored(name, d.IsDir())
+}
+
+// searchFile reports the matches in one file.
+func searchFile(name string, re *regexp.Regexp, opts *Options, sum *Summary, fn func(Match) error) error {
+	text, _, ok := readText(name, sum)
+	if !ok {
+		return nil
+	}
+	lines := splitLines(text)
+	found := 0
+	for i, line := range lines {
+		locs := re.FindAllStringIndex(line, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		if sum.Matches >= opts.MaxResults {
+			return errLimit
+		}
+		m := Match{Path: name, Line: i + 1}
+		m.Text, m.Truncated = line, len(line) > maxLineLength
+		if m.Truncated {
+			m.Text = truncate(line, maxLineLength)
+		}
+		for _, loc := range locs {
+			if m.Truncated && loc[0] >= len(m.Text) {
+				break
+			}
+			m.Submatches = append(m.Submatches, Submatch{Start: utf16Len(line[:loc[0]]), End: utf16Len(line[:min(loc[1], len(m.Text))])})
+		}
+		if opts.Context > 0 {
+			m.Before = contextLines(lines, i-opts.Context, i)
+			m.After = contextLines(lines, i+1, i+1+opts.Context)
+		}
+		if found == 0 {
+			sum.FilesMatched++
+		}
+		found++
+		sum.Matches++
+		if err := fn(m); err != nil {
+			return err
+		}
+		if found >= opts.MaxPerFile {
+			break
+		}
+	}
+	return nil
+}
+
+// readText returns the decoded content of a text file and its version.
+// It counts the file in sum as searched or skipped.
+func readText(name string, sum *Summary) (string, string, bool) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return "", "", false
+	}
+	if fi.Size() > MaxFileSize {
+		sum.Skipped++
+		return "", "", false
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", "", false
+	}
+	info, ok := util.DetectText(data)
+	if !ok {
+		sum.Skipped++
+		return "", "", false
+	}
+	text, err := util.DecodeText(data, info)
+	if err != nil {
+		sum.Skipped++
+		return "", "", false
+	}
+	sum.FilesSearched++
+	return text, util.FileVersion(fi), true
+}
+
+// splitLines splits text into lines without their line endings.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	return lines
+}
+
+// contextLines returns the lines from..to, clipped to the file.
+func contextLines(lines []string, from, to int) []string {
+	from, to = max(from, 0), min(to, len(lines))
+	if from >= to {
+		return nil
+	}
+	out := make([]string, 0, to-from)
+	for _, l := range lines[from:to] {
+		out = append(out, truncate(l, maxLineLength))
+	}
+	return out
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// utf16Len returns the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
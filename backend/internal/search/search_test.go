@@ -0,0 +1,113 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeTree creates the files below dir; names ending in "/" are
+// directories.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// searched returns the files below dir that a search for "needle" reads,
+// relative to dir.
+func searched(t *testing.T, dir string, opts Options) []string {
+	t.Helper()
+	opts.Query = "needle"
+	var got []string
+	_, err := Search(context.Background(), dir, opts, func(m Match) error {
+		rel, err := filepath.Rel(dir, m.Path)
+		if err != nil {
+			return err
+		}
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestSearchGitignore(t *testing.T) {
+	repo := t.TempDir()
+	writeTree(t, repo, map[string]string{
+		".git/":      "",
+		".gitignore": "*.log\n!keep.log\nbuild/\n/top.txt\ndocs/*.md\n# comment\n\\#hash\nvendor/\n",
+		"a.txt":      "needle",
+		"debug.log":  "needle",
+		"keep.log":   "needle",
+		"top.txt":    "needle",
+		"#hash":      "needle",
+		// dir-only pattern: the directory is ignored, a file named build
+		// is not
+		"build/out.txt": "needle",
+		"src/build":     "needle",
+		// an anchored pattern only applies at the top
+		"src/top.txt":   "needle",
+		"docs/a.md":     "needle",
+		"docs/sub/b.md": "needle",
+		// nested .gitignore files add rules and override parent ones
+		"src/.gitignore":     "gen/\n!important.log\n",
+		"src/gen/x.txt":      "needle",
+		"src/important.log":  "needle",
+		"src/other.log":      "needle",
+		"src/lib/.gitignore": "*.txt\n",
+		"src/lib/lib.txt":    "needle",
+		"src/lib/lib.go":     "needle",
+		// a file below an ignored directory cannot be re-included
+		"vendor/.keep":    "",
+		"vendor/keep.log": "needle",
+	})
+
+	want := []string{
+		"a.txt",
+		"docs/sub/b.md",
+		"keep.log",
+		"src/build",
+		"src/important.log",
+		"src/lib/lib.go",
+		"src/top.txt",
+	}
+	if got := searched(t, repo, Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("searched %q\nwant %q", got, want)
+	}
+
+	// searching a subdirectory still applies the .gitignore files of the
+	// repository above it
+	src := filepath.Join(repo, "src")
+	want = []string{"build", "important.log", "lib/lib.go", "top.txt"}
+	if got := searched(t, src, Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("searched in src %q\nwant %q", got, want)
+	}
+
+	if got := searched(t, src, Options{NoIgnore: true}); len(got) != 7 {
+		t.Errorf("NoIgnore searched %q, want all 7 files", got)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"lightdev/internal/auth"
+)
+
+// Cookies set by /api/login for browsers. The session cookie is HttpOnly;
+// the CSRF cookie is readable by the frontend, which echoes it in the
+// X-CSRF-Token header on state-changing requests.
+const (
+	SessionCookie = "mlcremote_session"
+	CSRFCookie    = "mlcremote_csrf"
+	CSRFHeader    = "X-CSRF-Token"
+)
+
+// DefaultAllowedOrigins are the origins of the desktop app's webview
+// (wails://wails on macOS/Linux, http://wails.localhost on Windows).
+// Same-origin requests are always allowed.
+var DefaultAllowedOrigins = []string{
+	"wails://wails",
+	"wails://wails.localhost",
+	"http://wails.localhost",
+}
+
+// originAllowed reports whether a browser request may reach the API.
+// Requests without an Origin header (curl, the desktop app's Go code,
+// same-origin GETs) are allowed; authentication still applies to them.
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || sameOrigin(r) {
+		return true
+	}
+	origin = strings.TrimSuffix(origin, "/")
+	for _, o := range DefaultAllowedOrigins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	for _, o := range s.AllowedOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether r comes from a page served by the agent
+// itself, which can always rely on the session cookie.
+func sameOrigin(r *http.Request) bool {
+	u, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// requestCredential returns the token of r and whether it came from the
+// session cookie. Explicit tokens (header, query) take precedence.
+func requestCredential(r *http.Request) (string, bool) {
+	if token := requestToken(r); token != "" {
+		return token, false
+	}
+	if c, err := r.Cookie(SessionCookie); err == nil && c.Value != "" {
+		return c.Value, true
+	}
+	return "", false
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// validCSRF checks the X-CSRF-Token header against the token bound to the
+// session.
+func validCSRF(r *http.Request, sess *auth.SessionInfo) bool {
+	got := r.Header.Get(CSRFHeader)
+	if got == "" || sess == nil || sess.CSRFToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(sess.CSRFToken)) == 1
+}
+
+// setSessionCookies stores a login session in the browser.
+func (s *Server) setSessionCookies(w http.ResponseWriter, secret string, sess auth.SessionInfo) {
+	maxAge := int(time.Until(sess.CreatedAt.Add(s.Sessions.MaxLifetime)).Seconds())
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    secret,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   s.TLS,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    sess.CSRFToken,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   s.TLS,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookies removes the login cookies from the browser.
+func (s *Server) clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{SessionCookie, CSRFCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == SessionCookie,
+			Secure:   s.TLS,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
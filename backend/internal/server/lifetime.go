@@ -0,0 +1,121 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"lightdev/internal/auth"
+	"lightdev/internal/handlers"
+)
+
+// trackActivity records authenticated requests for the idle timeout. It
+// runs inside authMiddleware, so public paths such as /health and
+// requests that fail authentication, e.g. from port scanners, do not
+// count. Requests still being served, such as event streams and terminal
+// websockets, keep the agent busy. Metrics scrapes do not count either, so
+// a scraper does not keep an abandoned agent alive.
+func (s *Server) trackActivity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" || auth.FromContext(r.Context()) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.inflight.Add(1)
+		s.lastActivity.Store(time.Now().UnixNano())
+		defer func() {
+			s.lastActivity.Store(time.Now().UnixNano())
+			s.inflight.Add(-1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// busy reports whether a request, terminal websocket or event stream
+// subscriber is active.
+func (s *Server) busy() bool {
+	if s.inflight.Load() > 0 || handlers.AttachedConnCount() > 0 {
+		return true
+	}
+	return s.Watcher != nil && s.Watcher.Subscribers() > 0
+}
+
+// IdleFor returns how long the agent has been without activity.
+func (s *Server) IdleFor() time.Duration {
+	if s.busy() {
+		return 0
+	}
+	return time.Since(time.Unix(0, s.lastActivity.Load()))
+}
+
+// Expired receives the reason once IdleTimeout or MaxLifetime is reached.
+func (s *Server) Expired() <-chan string {
+	return s.expired
+}
+
+// watchLimits sends on s.expired when the idle timeout or the maximum
+// lifetime is reached.
+func (s *Server) watchLimits() {
+	if s.IdleTimeout <= 0 && s.MaxLifetime <= 0 {
+		return
+	}
+	started := time.Now()
+	s.lastActivity.Store(started.UnixNano())
+
+	// check often enough to stop within a few percent of the limit
+	interval := 30 * time.Second
+	for _, d := range []time.Duration{s.IdleTimeout, s.MaxLifetime} {
+		if d > 0 && d/20 < interval {
+			interval = d / 20
+		}
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	go func() {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for range tick.C {
+			if s.MaxLifetime > 0 && time.Since(started) >= s.MaxLifetime {
+				s.expired <- fmt.Sprintf("maximum lifetime of %s reached", s.MaxLifetime)
+				return
+			}
+			if s.IdleTimeout > 0 {
+				if idle := s.IdleFor(); idle >= s.IdleTimeout {
+					s.expired <- fmt.Sprintf("idle for %s (idle timeout %s)", idle.Round(time.Second), s.IdleTimeout)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// RemoveRunFiles removes the pid and token files written by the desktop
+// app when starting the agent, if they belong to this agent. It is called
+// whenever the agent shuts down, except when an upgrade replaced it. Parallel
+// agents share the directory, so files written for another agent are
+// left alone.
+func (s *Server) RemoveRunFiles() {
+	pidFile, ok := ownPidFile()
+	if !ok {
+		return
+	}
+	if err := os.Remove(pidFile); err == nil {
+		log.Printf("[INFO] removed %s", pidFile)
+	}
+	tokenFile := filepath.Join(filepath.Dir(pidFile), "token")
+	if data, err := os.ReadFile(tokenFile); err == nil && s.AuthToken != "" && strings.TrimSpace(string(data)) == s.AuthToken {
+		if err := os.Remove(tokenFile); err == nil {
+			log.Printf("[INFO] removed %s", tokenFile)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lightdev/internal/auth"
+	"lightdev/internal/handlers"
+	"lightdev/internal/metrics"
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics adds the gauges that read server state at scrape time.
+func (s *Server) registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		reg := metrics.Default
+		reg.GaugeFunc("mlcremote_terminal_sessions", "Running terminal sessions.", func() float64 {
+			return float64(handlers.ActiveSessionCount())
+		})
+		if s.Logins != nil {
+			reg.GaugeFunc("mlcremote_login_locked_hosts", "Hosts currently locked out of password login.", func() float64 {
+				return float64(s.Logins.LockedCount())
+			})
+		}
+		if s.Sessions != nil {
+			reg.GaugeFunc("mlcremote_login_sessions", "Active login sessions.", func() float64 {
+				return float64(len(s.Sessions.List()))
+			})
+		}
+		if s.Watcher != nil {
+			reg.GaugeFunc("mlcremote_sse_subscribers", "Clients subscribed to /api/events.", func() float64 {
+				return float64(s.Watcher.Subscribers())
+			})
+			reg.CounterFunc("mlcremote_watcher_events_dropped_total", "File watcher events dropped for slow subscribers.", func() float64 {
+				return float64(s.Watcher.Dropped())
+			})
+		}
+		reg.Register(metrics.HostCollector{Root: s.Root})
+	})
+}
+
+// metricsPrincipal is the caller authenticated by MetricsToken.
+func metricsPrincipal() *auth.Principal {
+	return &auth.Principal{ID: "metrics", Name: "metrics token", Kind: auth.KindToken, Scopes: []string{auth.ScopeStats}}
+}
+
+// validMetricsToken reports whether r carries MetricsToken, either as a
+// bearer token (as sent by Prometheus) or like any other token.
+func (s *Server) validMetricsToken(r *http.Request) bool {
+	if s.MetricsToken == "" {
+		return false
+	}
+	token := requestToken(r)
+	if h := r.Header.Get("Authorization"); token == "" && strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimPrefix(h, "Bearer ")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) == 1
+}
+
+// instrument counts requests and their latency per registered route.
+// Requests that match no API route are counted as route "other" to keep
+// the number of series bounded.
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &metricsWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		_, route := s.Mux.Handler(r)
+		if route == "" || route == "/" {
+			route = "other"
+		}
+		code := rec.status
+		if code == 0 {
+			code = http.StatusOK
+		}
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(code))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// metricsWriter records the response status. It passes through Flush and
+// Hijack, which the event stream and the terminal websocket rely on.
+type metricsWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *metricsWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *metricsWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *metricsWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *metricsWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
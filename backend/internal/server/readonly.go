@@ -0,0 +1,170 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/handlers"
+)
+
+// maxReadOnlyUnlock bounds how long read-only mode can be lifted at once.
+const maxReadOnlyUnlock = 8 * time.Hour
+
+// ReadOnlyActive reports whether ReadOnly is set and not lifted by an
+// unlock.
+func (s *Server) ReadOnlyActive() bool {
+	if !s.ReadOnly {
+		return false
+	}
+	s.roMu.Lock()
+	defer s.roMu.Unlock()
+	return !time.Now().Before(s.unlockedUntil)
+}
+
+// UnlockedUntil returns when a lifted read-only mode applies again, or
+// the zero time if it is not lifted.
+func (s *Server) UnlockedUntil() time.Time {
+	s.roMu.Lock()
+	defer s.roMu.Unlock()
+	if !s.ReadOnly || !time.Now().Before(s.unlockedUntil) {
+		return time.Time{}
+	}
+	return s.unlockedUntil
+}
+
+// mutating wraps a handler that changes files or starts terminals so that
+// it is refused while the server is read-only.
+func (s *Server) mutating(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ReadOnlyActive() {
+			http.Error(w, "forbidden: server is read-only", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unlock lifts read-only mode for d. When it expires, terminals opened
+// in the meantime are closed.
+func (s *Server) unlock(d time.Duration) time.Time {
+	s.roMu.Lock()
+	defer s.roMu.Unlock()
+	s.unlockedUntil = time.Now().Add(d)
+	if s.relock != nil {
+		s.relock.Stop()
+	}
+	s.relock = time.AfterFunc(d, func() {
+		s.lock("unlock expired")
+		audit.Record(audit.Event{Action: audit.ActionReadOnlyLock, Result: audit.ResultOK, Detail: "unlock expired"})
+	})
+	return s.unlockedUntil
+}
+
+// lock ends an unlock early or after it expired.
+func (s *Server) lock(reason string) {
+	s.roMu.Lock()
+	if s.relock != nil {
+		s.relock.Stop()
+		s.relock = nil
+	}
+	s.unlockedUntil = time.Time{}
+	s.roMu.Unlock()
+
+	log.Printf("[INFO] Security: read-only mode active again (%s)", reason)
+	if n := handlers.ActiveSessionCount(); n > 0 {
+		log.Printf("[INFO] read-only: closing %d terminal sessions", n)
+		handlers.ShutdownAllSessions()
+	}
+}
+
+type readOnlyStatus struct {
+	// ReadOnly is whether mutating requests are refused right now.
+	ReadOnly bool `json:"readOnly"`
+	// Configured is whether the server was started read-only.
+	Configured bool `json:"configured"`
+	// UnlockedUntil is set while read-only mode is lifted.
+	UnlockedUntil *time.Time `json:"unlockedUntil,omitempty"`
+}
+
+type unlockRequest struct {
+	// Duration such as "30m", at most 8h.
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func (s *Server) readOnlyStatus() readOnlyStatus {
+	st := readOnlyStatus{ReadOnly: s.ReadOnlyActive(), Configured: s.ReadOnly}
+	if until := s.UnlockedUntil(); !until.IsZero() {
+		st.UnlockedUntil = &until
+	}
+	return st
+}
+
+// readOnlyHandler reports and temporarily lifts read-only mode.
+// @Summary Manage read-only mode
+// @Description GET reports whether the server is read-only. POST lifts read-only mode for the given duration (at most 8h); DELETE restores it early. When read-only mode applies again, terminals opened in the meantime are closed. Unlocks are recorded in the audit log with the token used. Requires the admin scope.
+// @ID readOnly
+// @Tags system
+// @Security TokenAuth
+// @Accept json
+// @Produce json
+// @Param body body unlockRequest false "Unlock duration and reason (POST)"
+// @Success 200 {object} readOnlyStatus
+// @Failure 400
+// @Failure 409 "Server is not in read-only mode"
+// @Router /api/read-only [get]
+// @Router /api/read-only [post]
+// @Router /api/read-only [delete]
+func (s *Server) readOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !s.ReadOnly {
+			http.Error(w, "server is not in read-only mode", http.StatusConflict)
+			return
+		}
+		var req unlockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			http.Error(w, "duration required, e.g. 30m", http.StatusBadRequest)
+			return
+		}
+		if d > maxReadOnlyUnlock {
+			http.Error(w, fmt.Sprintf("duration exceeds %s", maxReadOnlyUnlock), http.StatusBadRequest)
+			return
+		}
+		until := s.unlock(d)
+		detail := "until " + until.UTC().Format(time.RFC3339)
+		if req.Reason != "" {
+			detail += fmt.Sprintf(" reason=%q", req.Reason)
+		}
+		log.Printf("[WARNING] Security: read-only mode lifted %s", detail)
+		auditEvent(r, audit.ActionReadOnlyUnlock, detail)
+	case http.MethodDelete:
+		if !s.ReadOnly {
+			http.Error(w, "server is not in read-only mode", http.StatusConflict)
+			return
+		}
+		if !s.UnlockedUntil().IsZero() {
+			s.lock("locked by request")
+			auditEvent(r, audit.ActionReadOnlyLock, "")
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.readOnlyStatus())
+}
@@ -6,15 +6,26 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
 	"lightdev/internal/handlers"
+	"lightdev/internal/index"
+	"lightdev/internal/metrics"
 	"lightdev/internal/stats"
 	"lightdev/internal/watcher"
 
@@ -39,21 +50,83 @@ type Server struct {
 	Password string
 	// AllowDelete controls if file deletion is permitted
 	AllowDelete bool
+	// ReadOnly refuses file changes and new terminals, see readonly.go
+	ReadOnly bool
 	// TrashDir is the directory where deleted files are moved
 	TrashDir string
+	// MaxUploadSize caps resumable uploads; 0 means the handler's default
+	MaxUploadSize int64
 	// DebugTerminal controls verbose logging for terminal sessions
 	DebugTerminal bool
 
+	// TLS enables HTTPS. Without TLSCert/TLSKey a self-signed certificate
+	// is generated in ~/.mlcremote/tls.
+	TLS     bool
+	TLSCert string
+	TLSKey  string
+	// TLSFingerprint is the SHA-256 fingerprint of the served certificate,
+	// set by Start when TLS is enabled.
+	TLSFingerprint string
+	// SocketPath makes Start listen on this Unix socket instead of a TCP
+	// port. Start replaces it with the absolute path.
+	SocketPath string
+	// AllowedOrigins lists browser origins, besides the server itself and
+	// the desktop app, that may call the API. "*" allows any origin.
+	AllowedOrigins []string
+	// MetricsToken, if set, grants access to /metrics only, so scrapers
+	// do not need a full API token.
+	MetricsToken string
+	// LogFile is the agent's log file served by /api/logs. If empty the
+	// log captured by the deployment script is served.
+	LogFile string
+	// IdleTimeout, if positive, shuts the agent down after this long
+	// without requests, attached terminals or event stream subscribers.
+	IdleTimeout time.Duration
+	// MaxLifetime, if positive, shuts the agent down this long after it
+	// was started.
+	MaxLifetime time.Duration
+	// Listener, if set, is used by Start instead of opening a socket,
+	// e.g. the one inherited from the agent replaced by an upgrade.
+	Listener net.Listener
+
 	// RootFallback indicates if the server fell back to default root due to invalid config
 	RootFallback bool
 
+	// Tokens holds the named, scoped API tokens accepted besides AuthToken
+	Tokens *auth.TokenStore
+	// Sessions holds the short-lived tokens minted by /api/login
+	Sessions *auth.SessionStore
+	// TerminalTokens holds the tokens handed to terminal sessions
+	TerminalTokens *auth.TerminalTokens
+	// Logins throttles failed password logins per remote host
+	Logins *auth.LoginLimiter
+
+	// pwMu guards Password, which can be changed at runtime via /api/password
+	pwMu sync.RWMutex
+
 	Mux        *http.ServeMux
 	httpServer *http.Server
 	// clients
 	listener       net.Listener
+	rawListener    net.Listener
 	Watcher        *watcher.Service
 	StatsCollector stats.Collector
 	Port           int
+
+	// upgrade state, see upgrade.go
+	upgradeMu sync.Mutex
+	upgrading bool
+	retired   chan struct{}
+
+	// activity state for IdleTimeout, see lifetime.go
+	inflight     atomic.Int64
+	lastActivity atomic.Int64
+	expired      chan string
+
+	// read-only unlock state, see readonly.go
+	roMu          sync.Mutex
+	unlockedUntil time.Time
+	relock        *time.Timer
 }
 
 // New creates a Server with the provided root, static directory, auth token, and password.
@@ -63,6 +136,15 @@ func New(host, root, staticDir string, openapiPath string, authToken string, pas
 		log.Printf("[ERROR] failed to create watcher: %v", err)
 	}
 
+	tokensPath := filepath.Join(root, ".mlcremote", "tokens.json")
+	if home, err := os.UserHomeDir(); err == nil {
+		tokensPath = filepath.Join(home, ".mlcremote", "tokens.json")
+	}
+	tokens, err := auth.NewTokenStore(tokensPath)
+	if err != nil {
+		log.Printf("[ERROR] failed to load api tokens from %s: %v", tokensPath, err)
+	}
+
 	return &Server{
 		Host:           host,
 		Root:           root,
@@ -75,34 +157,52 @@ func New(host, root, staticDir string, openapiPath string, authToken string, pas
 		DebugTerminal:  debugTerminal,
 		Mux:            http.NewServeMux(),
 		Watcher:        w,
+		Tokens:         tokens,
+		Sessions:       auth.NewSessionStore(0, 0),
+		TerminalTokens: auth.NewTerminalTokens(),
+		Logins:         auth.NewLoginLimiter(0, 0),
 		StatsCollector: stats.NewCollector(filepath.Join(root, ".mlcremote")),
+		retired:        make(chan struct{}),
+		expired:        make(chan string, 1),
 	}
 }
 
-// allowCORS adds headers for Wails and other local prototyping origins
+// allowCORS adds CORS headers for allowed cross-origin callers, such as
+// the desktop app's webview. Other origins get no CORS headers.
 func (s *Server) allowCORS(w http.ResponseWriter, r *http.Request) {
-	origin := r.Header.Get("Origin")
-	if origin == "" {
-		origin = "*"
-	}
-	// For prototype, allow all/relevant origins.
-	w.Header().Set("Access-Control-Allow-Origin", origin)
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Auth-Token")
 	if s.RootFallback {
 		w.Header().Set("X-Root-Fallback", "true")
 	}
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" || !s.originAllowed(r) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS, PUT, PATCH, DELETE")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Auth-Token, Upload-Offset, Range, If-Range, If-Match, If-None-Match, If-Modified-Since, Content-MD5, X-Checksum-SHA256, X-File-Mode, X-File-Mtime, "+CSRFHeader)
+	w.Header().Set("Access-Control-Expose-Headers", "X-Session-Expires, X-Root-Fallback, Location, Upload-Offset, Upload-Length, Upload-Expires, ETag, X-File-Version, X-File-Encoding, X-File-BOM, X-File-EOL, X-Checksum-SHA256, Accept-Ranges, Content-Range")
 }
 
 // authMiddleware wraps an http.Handler and checks for the valid AuthToken.
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// TRACE LOGGING
-		if !strings.Contains(r.URL.Path, "/api/logs") {
+		if !strings.Contains(r.URL.Path, "/api/logs") && r.URL.Path != "/metrics" {
 			log.Printf("[ACCESS] %s %s (Remote: %s)", r.Method, r.URL.String(), r.RemoteAddr)
 		}
 		s.allowCORS(w, r)
 
+		// Browsers send Origin on cross-site requests. Pages from origins
+		// outside the allowlist must not reach the API, even with a token.
+		isAPI := strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/ws/")
+		if isAPI && !s.originAllowed(r) {
+			log.Printf("[WARNING] rejected request from origin %s to %s", r.Header.Get("Origin"), r.URL.Path)
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -111,138 +211,292 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 		// Public endpoints:
 		// 1. Health check
 		// 2. Login endpoint
-		// 3. Version & Settings (often used for bootstrapping frontend)
+		// 3. Version & reading settings (used for bootstrapping frontend)
 		// 4. Static files (everything NOT starting with /api/ or /ws/)
 		publicPaths := map[string]bool{
-			"/health":       true,
-			"/api/login":    true,
-			"/api/settings": true,
-			"/api/version":  true,
+			"/health":      true,
+			"/api/login":   true,
+			"/api/version": true,
 		}
-		if publicPaths[r.URL.Path] {
+		if publicPaths[r.URL.Path] || (r.URL.Path == "/api/settings" && isSafeMethod(r.Method)) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Check if it's a static file request
-		if !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/ws/") {
-			next.ServeHTTP(w, r)
+		if r.URL.Path == "/metrics" && s.validMetricsToken(r) {
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), metricsPrincipal())))
 			return
 		}
 
-		token := r.Header.Get("X-Auth-Token")
-		if token == "" {
-			token = r.URL.Query().Get("token")
+		// Check if it's a static file request
+		if !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/ws/") && r.URL.Path != "/metrics" {
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		if s.AuthToken != "" && token != s.AuthToken {
+		principal, sess, fromCookie := s.authenticate(w, r)
+		if principal == nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		// The cookie is sent by the browser automatically, so requests
+		// relying on it must prove they come from our own frontend.
+		if fromCookie && !isSafeMethod(r.Method) && !validCSRF(r, sess) {
+			http.Error(w, "missing or invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
 
+// authenticate resolves the caller of r from the X-Auth-Token header, the
+// token query parameter or the session cookie. It returns nil if the token
+// is missing or invalid. Session tokens are refreshed and their new expiry
+// is reported in the X-Session-Expires header. fromCookie is set if the
+// session cookie was used; only session tokens are accepted from it.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (p *auth.Principal, sess *auth.SessionInfo, fromCookie bool) {
+	if s.AuthToken == "" {
+		return auth.AnonymousPrincipal(), nil, false
+	}
+
+	token, fromCookie := requestCredential(r)
+	if token == "" {
+		return nil, nil, false
+	}
+
+	if !fromCookie && subtle.ConstantTimeCompare([]byte(token), []byte(s.AuthToken)) == 1 {
+		return auth.MasterPrincipal(), nil, false
+	}
+	if s.Sessions != nil && strings.HasPrefix(token, auth.SessionPrefix) {
+		p, sess = s.Sessions.Authenticate(token)
+		if sess != nil {
+			w.Header().Set("X-Session-Expires", sess.ExpiresAt.Format(time.RFC3339))
+		}
+		return p, sess, fromCookie
+	}
+	if s.TerminalTokens != nil && !fromCookie && strings.HasPrefix(token, auth.TerminalPrefix) {
+		return s.TerminalTokens.Authenticate(token), nil, false
+	}
+	if s.Tokens != nil && !fromCookie {
+		return s.Tokens.Authenticate(token), nil, false
+	}
+	return nil, nil, false
+}
+
+// auditEvent records action by the caller of r.
+func auditEvent(r *http.Request, action, detail string) {
+	ev := audit.FromRequest(r, action)
+	ev.Detail = detail
+	audit.Record(ev)
+}
+
+// requireScope wraps next so that it is only served to principals granted scope.
+func (s *Server) requireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.FromContext(r.Context()).Has(scope) {
+			http.Error(w, "forbidden: missing scope "+scope, http.StatusForbidden)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 type loginRequest struct {
 	Password string `json:"password"`
+	// ReturnToken asks for the token in the response body even on the
+	// agent's own pages, for pages framed by another origin (the desktop
+	// app) that never see the SameSite=Strict cookie.
+	ReturnToken bool `json:"returnToken,omitempty"`
+}
+
+type loginResponse struct {
+	// Token is omitted for the agent's own pages, which get it only in
+	// the HttpOnly cookie.
+	Token     string    `json:"token,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CSRFToken string    `json:"csrfToken"`
 }
 
 // @Summary Login
-// @Description Exchange password for auth token.
+// @Description Exchange password for a short-lived session token. The session expiry slides with every request and is reported in the X-Session-Expires response header. Browsers also receive the token in an HttpOnly cookie; requests authenticated by the cookie must send the CSRF token in the X-CSRF-Token header. Pages served by the agent itself (Origin equal to the agent's host) get the token only in the cookie, not in the response body, unless they set returnToken because they are framed by another origin.
 // @ID login
 // @Tags auth
 // @Accept json
 // @Param body body loginRequest true "Password"
 // @Produce json
-// @Success 200 {object} map[string]string
+// @Success 200 {object} loginResponse
 // @Failure 401
+// @Failure 429 "Too many failed attempts, see Retry-After"
 // @Router /api/login [post]
 func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	password := s.currentPassword()
 	// if no password configured, login is disabled/irrelevant
-	if s.Password == "" {
+	if password == "" {
 		http.Error(w, "login not configured", http.StatusForbidden)
 		return
 	}
 
+	host := auth.HostOf(r.RemoteAddr)
+	if ok, wait := s.Logins.Allow(host); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "too many failed login attempts", http.StatusTooManyRequests)
+		return
+	}
+
 	var req loginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.Logins.Release(host)
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
 
-	// simple constant-time-ish comparison not strictly needed for this scope
-	// but good habit. here we just do simple string check.
-	if req.Password != s.Password {
+	if !auth.CheckPassword(req.Password, password) {
+		st := s.Logins.Fail(host)
+		ev := audit.FromRequest(r, audit.ActionLogin)
+		ev.Result, ev.Status = audit.ResultDenied, http.StatusUnauthorized
+		ev.Detail = fmt.Sprintf("failures=%d", st.Failures)
+		audit.Record(ev)
+		if st.LockedUntil != nil {
+			log.Printf("[WARNING] login locked for %s until %s after %d failures", host, st.LockedUntil.Format(time.RFC3339), st.Failures)
+			auditEvent(r, audit.ActionLockout, "host="+host+" until "+st.LockedUntil.Format(time.RFC3339))
+		}
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	s.Logins.Succeed(host)
 
-	resp := map[string]string{"token": s.AuthToken}
+	sess, secret, err := s.Sessions.Create(r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	s.setSessionCookies(w, secret, sess)
 	w.Header().Set("Content-Type", "application/json")
+	resp := loginResponse{ExpiresAt: sess.ExpiresAt, CSRFToken: sess.CSRFToken}
+	// keep the token out of reach of scripts where the cookie works
+	if !sameOrigin(r) || req.ReturnToken {
+		resp.Token = secret
+	}
 	_ = json.NewEncoder(w).Encode(resp)
 
-	handlers.WriteAuditLog("Login Success from %s", r.RemoteAddr)
+	ev := audit.FromRequest(r, audit.ActionLogin)
+	ev.Actor, ev.ActorName = sess.ID, "login session"
+	audit.Record(ev)
+}
+
+// currentPassword returns the login password, which may change at runtime.
+func (s *Server) currentPassword() string {
+	s.pwMu.RLock()
+	defer s.pwMu.RUnlock()
+	return s.Password
+}
+
+// passwordAuth reports whether password login is configured.
+func (s *Server) passwordAuth() bool {
+	return s.currentPassword() != ""
 }
 
 // Routes registers all HTTP handlers on the server mux.
+// API routes are wrapped with requireScope so scoped tokens only reach the
+// routes they were granted.
 func (s *Server) Routes() {
-	s.Mux.HandleFunc("/health", handlers.Health(s.Password != "", s.AuthToken != ""))
+	read := func(h http.Handler) http.Handler { return s.requireScope(auth.ScopeFilesRead, h) }
+	write := func(h http.Handler) http.Handler { return s.requireScope(auth.ScopeFilesWrite, s.mutating(h)) }
+	term := func(h http.Handler) http.Handler { return s.requireScope(auth.ScopeTerminal, h) }
+	admin := func(h http.Handler) http.Handler { return s.requireScope(auth.ScopeAdmin, h) }
+
+	s.Mux.HandleFunc("/health", handlers.Health(s.passwordAuth, s.AuthToken != "", s.Logins.LockedCount, s.ReadOnlyActive))
 
 	if s.Watcher != nil {
-		s.Mux.HandleFunc("/api/events", handlers.EventsHandler(s.Watcher))
+		s.Mux.Handle("/api/events", read(handlers.EventsHandler(s.Watcher)))
 	}
 	// Stats
 	if s.StatsCollector != nil {
-		s.Mux.HandleFunc("/api/stats", stats.Handler(s.StatsCollector))
+		s.Mux.Handle("/api/stats", s.requireScope(auth.ScopeStats, stats.Handler(s.StatsCollector)))
 	}
+	s.registerMetrics()
+	s.Mux.Handle("/metrics", s.requireScope(auth.ScopeStats, metrics.Handler(metrics.Default)))
 	// Swagger UI (inline docs)
 	s.Mux.Handle("/docs/", httpSwagger.WrapHandler)
 
 	// APIs
+	// Intentionally unscoped: login is public, and logout and auth/check
+	// only act on the caller's own credential, whatever its scopes.
 	s.Mux.HandleFunc("/api/login", s.loginHandler)
+	s.Mux.HandleFunc("/api/logout", s.logoutHandler)
+	s.Mux.Handle("/api/sessions", admin(http.HandlerFunc(s.sessionsHandler)))
+	s.Mux.Handle("/api/password", admin(http.HandlerFunc(s.passwordHandler)))
+	s.Mux.Handle("/api/auth/lockouts", admin(http.HandlerFunc(s.lockoutsHandler)))
 	s.Mux.HandleFunc("/api/auth/check", handlers.CheckAuthHandler)
+	s.Mux.Handle("/api/tokens", admin(http.HandlerFunc(s.tokensHandler)))
+	s.Mux.Handle("/api/audit", admin(handlers.AuditHandler()))
 	s.Mux.HandleFunc("/api/version", handlers.VersionHandler)
-	s.Mux.Handle("/ws/terminal", handlers.WsTerminalHandler(s.Root, s.DebugTerminal, &s.Port))
-	s.Mux.Handle("/api/tree", handlers.TreeHandler(s.Root))
-	s.Mux.Handle("/api/filetype", handlers.FileTypeHandler(s.Root))
+	handlers.SetTerminalTokens(s.TerminalTokens)
+	s.Mux.Handle("/ws/terminal", term(s.mutating(handlers.WsTerminalHandler(s.Root, s.DebugTerminal, &s.Port, s.originAllowed))))
+	s.Mux.Handle("/api/tree", read(handlers.TreeHandler(s.Root)))
+	s.Mux.Handle("/api/search", read(handlers.SearchHandler(s.Root)))
+	s.Mux.Handle("/api/replace/preview", read(handlers.ReplacePreviewHandler(s.Root)))
+	s.Mux.Handle("/api/replace/apply", write(handlers.ReplaceApplyHandler(s.Root)))
+	s.Mux.Handle("/api/find", read(handlers.FindHandler(s.Root, index.New(s.Root, s.Watcher))))
+	s.Mux.Handle("/api/filetype", read(handlers.FileTypeHandler(s.Root)))
 	// serve file sections for large-file viewing
-	s.Mux.Handle("/api/file/section", handlers.FileSectionHandler(s.Root))
-	s.Mux.Handle("/api/stat", handlers.StatHandler(s.Root))
-	s.Mux.Handle("/api/archive/list", handlers.ListArchiveHandler(s.Root))
+	s.Mux.Handle("/api/file/section", read(handlers.FileSectionHandler(s.Root)))
+	// apply edits or a diff instead of sending the whole file
+	s.Mux.Handle("/api/file/patch", write(handlers.PatchFileHandler(s.Root)))
+	s.Mux.Handle("/api/stat", read(handlers.StatHandler(s.Root)))
+	s.Mux.Handle("/api/archive/list", read(handlers.ListArchiveHandler(s.Root)))
 
 	settingsPath := filepath.Join(s.Root, ".mlcremote", "settings.json")
-	s.Mux.Handle("/api/settings", handlers.SettingsHandler(s.AllowDelete, settingsPath))
-	s.Mux.HandleFunc("/api/trash/recent", handlers.RecentTrashHandler())
-	s.Mux.HandleFunc("/api/trash/restore", handlers.RestoreTrashHandler(s.Root))
-	s.Mux.HandleFunc("/api/trash", handlers.EmptyTrashHandler(s.TrashDir, s.AllowDelete))
-	// Register LogsHandler
-	s.Mux.Handle("/api/logs", handlers.LogsHandler())
-	s.Mux.HandleFunc("/api/terminal/new", handlers.NewTerminalAPI(s.Root, &s.Port))
-	s.Mux.HandleFunc("/api/terminal/status", handlers.TerminalStatusAPI)
-	s.Mux.HandleFunc("/api/terminal/cwd", handlers.UpdateCwdHandler(s.Watcher, s.Root))
-	s.Mux.HandleFunc("/api/command", handlers.SendCommandHandler(s.Watcher, s.Root))
+	settings := handlers.SettingsHandler(s.AllowDelete, s.ReadOnlyActive, settingsPath)
+	// reading the settings is public, changing them is not
+	s.Mux.Handle("/api/settings", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			write(settings).ServeHTTP(w, r)
+			return
+		}
+		settings.ServeHTTP(w, r)
+	}))
+	s.Mux.Handle("/api/trash/recent", read(handlers.RecentTrashHandler()))
+	s.Mux.Handle("/api/trash/restore", write(handlers.RestoreTrashHandler(s.Root)))
+	s.Mux.Handle("/api/trash", s.requireScope(auth.ScopeTrashEmpty, s.mutating(handlers.EmptyTrashHandler(s.TrashDir, s.AllowDelete))))
+	// Register LogsHandler (logs contain the startup token, so admin only)
+	s.Mux.Handle("/api/logs", admin(handlers.LogsHandler(s.LogFile)))
+	s.Mux.Handle("/api/logs/level", admin(handlers.LogLevelHandler()))
+	s.Mux.Handle("/api/agent/upgrade", admin(http.HandlerFunc(s.upgradeHandler)))
+	s.Mux.Handle("/api/read-only", admin(http.HandlerFunc(s.readOnlyHandler)))
+	s.Mux.Handle("/api/terminal/new", term(s.mutating(handlers.NewTerminalAPI(s.Root, &s.Port))))
+	s.Mux.Handle("/api/terminal/status", term(http.HandlerFunc(handlers.TerminalStatusAPI)))
+	s.Mux.Handle("/api/terminal/cwd", term(handlers.UpdateCwdHandler(s.Watcher, s.Root)))
+	s.Mux.Handle("/api/command", term(handlers.SendCommandHandler(s.Watcher, s.Root)))
 	s.Mux.Handle("/api/file", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			handlers.GetFileHandler(s.Root)(w, r)
+			read(handlers.GetFileHandler(s.Root)).ServeHTTP(w, r)
 		case http.MethodPost:
-			handlers.PostFileHandler(s.Root)(w, r)
+			write(handlers.PostFileHandler(s.Root)).ServeHTTP(w, r)
+		case http.MethodPut:
+			write(handlers.PutFileHandler(s.Root)).ServeHTTP(w, r)
 		case http.MethodDelete:
-			handlers.DeleteFileHandler(s.Root, s.TrashDir, s.AllowDelete)(w, r)
+			write(handlers.DeleteFileHandler(s.Root, s.TrashDir, s.AllowDelete)).ServeHTTP(w, r)
 		default:
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
 	// Upload endpoint for drag & drop file uploads
-	s.Mux.HandleFunc("/api/upload", handlers.UploadHandler(s.Root))
-	s.Mux.Handle("/api/rename", handlers.RenameFileHandler(s.Root))
-	s.Mux.Handle("/api/copy", handlers.CopyFileHandler(s.Root))
+	s.Mux.Handle("/api/upload", write(handlers.UploadHandler(s.Root)))
+	// Resumable chunked uploads for large files
+	uploads := write(handlers.UploadsHandler(s.Root, filepath.Join(s.Root, ".mlcremote", "uploads"), s.MaxUploadSize))
+	s.Mux.Handle("/api/uploads", uploads)
+	s.Mux.Handle("/api/uploads/", uploads)
+	s.Mux.Handle("/api/rename", write(handlers.RenameFileHandler(s.Root)))
+	s.Mux.Handle("/api/copy", write(handlers.CopyFileHandler(s.Root)))
 
 	// Static files (for dev)
 	if s.StaticDir != "" {
@@ -262,14 +516,48 @@ func (s *Server) Routes() {
 	}
 }
 
-// Start starts the HTTP server on the given port bound to localhost.
+// Scheme returns "https" when TLS is enabled and "http" otherwise.
+func (s *Server) Scheme() string {
+	if s.TLS {
+		return "https"
+	}
+	return "http"
+}
 
 // Start starts the HTTP server on the given port bound to configured host.
+// If SocketPath is set, the port is ignored, the server listens on the Unix
+// socket and the returned port is 0.
 func (s *Server) Start(port int) (int, error) {
 	addr := fmt.Sprintf("%s:%d", s.Host, port)
-	log.Printf("starting server on http://%s, root=%s", addr, s.Root)
+	if s.SocketPath != "" {
+		addr = "unix:" + s.SocketPath
+	}
+	log.Printf("starting server on %s://%s, root=%s", s.Scheme(), addr, s.Root)
+
+	var tlsConfig *tls.Config
+	if s.TLS {
+		cert, err := s.loadTLSCertificate()
+		if err != nil {
+			return 0, fmt.Errorf("tls: %w", err)
+		}
+		s.TLSFingerprint = Fingerprint(cert.Certificate[0])
+		log.Printf("[INFO] TLS fingerprint (SHA-256): %s", s.TLSFingerprint)
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
 	// create listener first so we can return binding errors synchronously
-	ln, err := net.Listen("tcp", addr)
+	var ln net.Listener
+	var err error
+	if s.Listener != nil {
+		ln = s.Listener
+	} else if s.SocketPath != "" {
+		ln, err = listenUnix(s.SocketPath)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -278,15 +566,26 @@ func (s *Server) Start(port int) (int, error) {
 	actualPort := port
 	if tcpAddr, ok := ln.Addr().(*net.TCPAddr); ok {
 		actualPort = tcpAddr.Port
+		addr = fmt.Sprintf("%s:%d", s.Host, actualPort)
+	} else if unixAddr, ok := ln.Addr().(*net.UnixAddr); ok {
+		actualPort = 0
+		s.SocketPath = unixAddr.Name
+		addr = "unix:" + s.SocketPath
+		writeSocketInfo(s.SocketPath)
+		handlers.SetAPISocket(s.SocketPath)
+	}
+	s.rawListener = ln
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+		handlers.SetAPIFingerprint(s.TLSFingerprint)
 	}
 
-	addr = fmt.Sprintf("%s:%d", s.Host, actualPort)
-	log.Printf("[INFO] server listening on http://%s", addr)
+	log.Printf("[INFO] server listening on %s://%s", s.Scheme(), addr)
 	s.listener = ln
 	s.Port = actualPort
 
-	// Wrap mux with auth middleware
-	handler := s.authMiddleware(s.Mux)
+	// Wrap mux with auth middleware, counting every request for /metrics
+	handler := s.instrument(s.authMiddleware(s.trackActivity(s.Mux)))
 
 	s.httpServer = &http.Server{Addr: addr, Handler: handler}
 	go func() {
@@ -301,6 +600,7 @@ func (s *Server) Start(port int) (int, error) {
 	if s.StatsCollector != nil {
 		s.StatsCollector.Start()
 	}
+	s.watchLimits()
 
 	return actualPort, nil
 }
@@ -315,6 +615,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 			s.httpServer.Close()
 		}
 	}
+	if s.SocketPath != "" {
+		removeSocketInfo(s.SocketPath)
+	}
 	// cleanup terminal sessions
 	handlers.ShutdownAllSessions()
 	if s.Watcher != nil {
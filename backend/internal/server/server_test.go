@@ -0,0 +1,303 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
+)
+
+const testToken = "master-secret"
+
+// newTestServer returns a server rooted in a temporary directory, with
+// HOME pointing at another one, and the handler chain requests go through.
+func newTestServer(t *testing.T, password string) (*Server, http.Handler) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	prev := audit.Default()
+	audit.SetDefault(audit.New(filepath.Join(home, "audit.jsonl"), 0, 0))
+	t.Cleanup(func() { audit.SetDefault(prev) })
+	root := filepath.Join(home, "work")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := New("127.0.0.1", root, "", "", testToken, password, false, filepath.Join(home, "trash"), false)
+	if s.Watcher != nil {
+		t.Cleanup(s.Watcher.Stop)
+	}
+	s.Routes()
+	return s, s.authMiddleware(s.Mux)
+}
+
+func serve(h http.Handler, method, target, token string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("X-Auth-Token", token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRouteScopes(t *testing.T) {
+	s, h := newTestServer(t, "")
+
+	secrets := map[string]string{"master": testToken}
+	for _, role := range []string{"read-only", "editor", "developer", "admin"} {
+		_, secret, err := s.Tokens.Create(role, role, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[role] = secret
+	}
+	_, secrets["terminal"], _ = s.Tokens.Create("terminal only", "", []string{"terminal"})
+
+	routes := []struct {
+		method, target string
+		// allowed lists the tokens granted the route's scope
+		allowed []string
+	}{
+		{"GET", "/api/tree?path=/", []string{"read-only", "editor", "developer", "admin", "master"}},
+		{"GET", "/api/file?path=/missing", []string{"read-only", "editor", "developer", "admin", "master"}},
+		{"POST", "/api/file", []string{"editor", "developer", "admin", "master"}},
+		{"POST", "/api/rename", []string{"editor", "developer", "admin", "master"}},
+		{"GET", "/api/stats", []string{"read-only", "editor", "developer", "admin", "master"}},
+		{"GET", "/api/terminal/status", []string{"developer", "terminal", "admin", "master"}},
+		{"DELETE", "/api/trash", []string{"admin", "master"}},
+		{"GET", "/api/tokens", []string{"admin", "master"}},
+		{"GET", "/api/logs", []string{"admin", "master"}},
+	}
+	for _, rt := range routes {
+		for name, secret := range secrets {
+			allowed := false
+			for _, a := range rt.allowed {
+				allowed = allowed || a == name
+			}
+			w := serve(h, rt.method, rt.target, secret, "{}")
+			// handlers may refuse the request for other reasons, so only
+			// the scope check's own response counts as denied
+			denied := w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), "missing scope")
+			if denied == allowed {
+				t.Errorf("%s %s with %s token: %d %q", rt.method, rt.target, name, w.Code, strings.TrimSpace(w.Body.String()))
+			}
+		}
+	}
+
+	for _, secret := range []string{"", "wrong", "mlc_0123"} {
+		if w := serve(h, "GET", "/api/tree?path=/", secret, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want 401", secret, w.Code)
+		}
+	}
+	for _, target := range []string{"/health", "/api/version"} {
+		if w := serve(h, "GET", target, "", ""); w.Code != http.StatusOK {
+			t.Errorf("public %s: status %d, want 200", target, w.Code)
+		}
+	}
+}
+
+func login(t *testing.T, h http.Handler, password string) (loginResponse, *httptest.ResponseRecorder) {
+	t.Helper()
+	w := serve(h, "POST", "/api/login", "", `{"password":"`+password+`"}`)
+	var resp loginResponse
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, w
+}
+
+func TestSessionLogout(t *testing.T) {
+	s, h := newTestServer(t, "pw")
+
+	resp, w := login(t, h, "pw")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d", w.Code)
+	}
+	if !strings.HasPrefix(resp.Token, auth.SessionPrefix) || resp.Token == testToken {
+		t.Fatalf("login returned %q, want a session token", resp.Token)
+	}
+	w = serve(h, "GET", "/api/tree?path=/", resp.Token, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("session token: status %d", w.Code)
+	}
+	if w.Header().Get("X-Session-Expires") == "" {
+		t.Error("no X-Session-Expires header")
+	}
+
+	if w := serve(h, "POST", "/api/logout", resp.Token, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d", w.Code)
+	}
+	if w := serve(h, "GET", "/api/tree?path=/", resp.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status %d, want 401", w.Code)
+	}
+	if w := serve(h, "POST", "/api/logout", testToken, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("logout with the master token: status %d, want 400", w.Code)
+	}
+
+	// an admin revokes another session by id
+	resp, _ = login(t, h, "pw")
+	sessions := s.Sessions.List()
+	if len(sessions) != 1 {
+		t.Fatalf("%d sessions, want 1", len(sessions))
+	}
+	if w := serve(h, "DELETE", "/api/sessions?id="+sessions[0].ID, testToken, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d", w.Code)
+	}
+	if w := serve(h, "GET", "/api/tree?path=/", resp.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("after revocation: status %d, want 401", w.Code)
+	}
+
+	if _, w := login(t, h, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password: status %d, want 401", w.Code)
+	}
+}
+
+func TestHealthPasswordAuth(t *testing.T) {
+	s, h := newTestServer(t, "")
+	passwordAuth := func() bool {
+		t.Helper()
+		w := serve(h, "GET", "/health", "", "")
+		var info struct {
+			PasswordAuth bool `json:"password_auth"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		return info.PasswordAuth
+	}
+	if passwordAuth() {
+		t.Error("password_auth reported without a password")
+	}
+	// the password may be set after the routes are registered
+	s.pwMu.Lock()
+	s.Password = "pw"
+	s.pwMu.Unlock()
+	if !passwordAuth() {
+		t.Error("password_auth not reported after setting a password")
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	_, h := newTestServer(t, "pw")
+	resp, w := login(t, h, "pw")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d", w.Code)
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookie {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly || session.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("session cookie %+v, want HttpOnly and SameSite=Strict", session)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		token  string
+		csrf   string
+		origin string
+		// rejected is the expected status, 0 if the request must pass
+		rejected int
+	}{
+		{"cookie GET", "GET", session.Value, "", "", "", 0},
+		{"cookie POST without CSRF token", "POST", session.Value, "", "", "", http.StatusForbidden},
+		{"cookie POST with wrong CSRF token", "POST", session.Value, "", "0123", "", http.StatusForbidden},
+		{"cookie POST with CSRF token", "POST", session.Value, "", resp.CSRFToken, "", 0},
+		{"header token POST", "POST", "", testToken, "", "", 0},
+		{"master token in cookie", "GET", testToken, "", "", "", http.StatusUnauthorized},
+		{"foreign origin", "GET", session.Value, "", "", "https://evil.example", http.StatusForbidden},
+		{"foreign origin with token", "GET", "", testToken, "", "https://evil.example", http.StatusForbidden},
+		{"desktop origin", "GET", "", testToken, "", "wails://wails", 0},
+		{"same origin", "POST", session.Value, "", resp.CSRFToken, "http://example.com", 0},
+	}
+	for _, tt := range tests {
+		target := "/api/tree?path=/"
+		if tt.method == "POST" {
+			target = "/api/rename"
+		}
+		r := httptest.NewRequest(tt.method, target, strings.NewReader("{}"))
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+		}
+		if tt.token != "" {
+			r.Header.Set("X-Auth-Token", tt.token)
+		}
+		if tt.csrf != "" {
+			r.Header.Set(CSRFHeader, tt.csrf)
+		}
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		// the empty rename request fails in the handler once it gets past
+		// the middleware, but never with 401 or 403
+		passed := w.Code != http.StatusUnauthorized && w.Code != http.StatusForbidden
+		if tt.rejected == 0 && !passed || tt.rejected != 0 && w.Code != tt.rejected {
+			t.Errorf("%s: status %d %q, want %d", tt.name, w.Code, strings.TrimSpace(w.Body.String()), tt.rejected)
+		}
+	}
+}
+
+func TestTerminalToken(t *testing.T) {
+	s, h := newTestServer(t, "")
+	secret, err := s.TerminalTokens.Issue(auth.MasterPrincipal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without a session id the status handler answers 404 once the
+	// request gets past the middleware
+	if w := serve(h, "GET", "/api/terminal/status", secret, ""); w.Code != http.StatusNotFound {
+		t.Errorf("terminal status: status %d, want 404", w.Code)
+	}
+	if w := serve(h, "GET", "/api/tree?path=/", secret, ""); w.Code != http.StatusForbidden {
+		t.Errorf("tree: status %d, want 403", w.Code)
+	}
+	s.TerminalTokens.Revoke(secret)
+	if w := serve(h, "GET", "/api/terminal/status", secret, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("after revocation: status %d, want 401", w.Code)
+	}
+}
+
+func TestLoginReturnToken(t *testing.T) {
+	_, h := newTestServer(t, "pw")
+	for _, tt := range []struct {
+		body   string
+		origin string
+		want   bool
+	}{
+		{`{"password":"pw"}`, "", true},
+		{`{"password":"pw"}`, "http://example.com", false},
+		{`{"password":"pw","returnToken":true}`, "http://example.com", true},
+	} {
+		r := httptest.NewRequest("POST", "/api/login", strings.NewReader(tt.body))
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		var resp loginResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s from %q: status %d: %v", tt.body, tt.origin, w.Code, err)
+		}
+		if got := resp.Token != ""; got != tt.want {
+			t.Errorf("%s from %q: token returned %v, want %v", tt.body, tt.origin, got, tt.want)
+		}
+	}
+}
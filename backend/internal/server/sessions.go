@@ -0,0 +1,183 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
+)
+
+// requestToken returns the raw token the request authenticated with.
+func requestToken(r *http.Request) string {
+	token := r.Header.Get("X-Auth-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return token
+}
+
+// logoutHandler revokes the session token used for the request.
+// @Summary Logout
+// @Description Revokes the session token sent with the request and clears the session cookies.
+// @ID logout
+// @Tags auth
+// @Security TokenAuth
+// @Success 204
+// @Failure 400 "Not a session token"
+// @Router /api/logout [post]
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, fromCookie := requestCredential(r)
+	if fromCookie {
+		s.clearSessionCookies(w)
+	}
+	if !strings.HasPrefix(token, auth.SessionPrefix) {
+		http.Error(w, "not a session token", http.StatusBadRequest)
+		return
+	}
+	p := auth.FromContext(r.Context())
+	s.Sessions.Logout(token)
+	auditEvent(r, audit.ActionLogout, "session="+p.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionsHandler lists or revokes login sessions.
+// @Summary Manage login sessions
+// @Description GET lists active login sessions. DELETE revokes the session given by id, or every session with all=true. Requires the admin scope.
+// @ID manageSessions
+// @Tags auth
+// @Security TokenAuth
+// @Produce json
+// @Param id query string false "Session id to revoke (DELETE)"
+// @Param all query bool false "Revoke all sessions (DELETE)"
+// @Success 200 {array} auth.SessionInfo
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /api/sessions [get]
+// @Router /api/sessions [delete]
+func (s *Server) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Sessions.List())
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			if r.URL.Query().Get("all") != "true" {
+				http.Error(w, "id or all=true required", http.StatusBadRequest)
+				return
+			}
+			n := s.Sessions.RevokeAll()
+			auditEvent(r, audit.ActionSessionRevoke, fmt.Sprintf("all (%d)", n))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !s.Sessions.Revoke(id) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		auditEvent(r, audit.ActionSessionRevoke, "session="+id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type passwordRequest struct {
+	Current string `json:"current"`
+	New     string `json:"new"`
+}
+
+// passwordHandler changes the login password of the running agent and
+// revokes all login sessions. The change is not written to config.ini.
+// @Summary Change login password
+// @Description Replaces the login password until the agent restarts and revokes every login session. Requires the admin scope.
+// @ID changePassword
+// @Tags auth
+// @Security TokenAuth
+// @Accept json
+// @Param body body passwordRequest true "Current and new password"
+// @Success 204
+// @Failure 400
+// @Failure 401 "Current password does not match"
+// @Failure 403 "Login not configured"
+// @Router /api/password [post]
+func (s *Server) passwordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req passwordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.New == "" {
+		http.Error(w, "new password must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	s.pwMu.Lock()
+	if s.Password == "" {
+		s.pwMu.Unlock()
+		http.Error(w, "login not configured", http.StatusForbidden)
+		return
+	}
+	if !auth.CheckPassword(req.Current, s.Password) {
+		s.pwMu.Unlock()
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	s.Password = req.New
+	s.pwMu.Unlock()
+
+	n := s.Sessions.RevokeAll()
+	auditEvent(r, audit.ActionPasswordChange, fmt.Sprintf("%d session(s) revoked", n))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lockoutsHandler lists or clears the login throttling state.
+// @Summary Manage login lockouts
+// @Description GET lists remote hosts with failed password logins and their lockout state. DELETE clears the host given by host. Requires the admin scope.
+// @ID manageLockouts
+// @Tags auth
+// @Security TokenAuth
+// @Produce json
+// @Param host query string false "Host to unlock (DELETE)"
+// @Success 200 {array} auth.LockoutInfo
+// @Success 204
+// @Failure 404
+// @Router /api/auth/lockouts [get]
+// @Router /api/auth/lockouts [delete]
+func (s *Server) lockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Logins.List())
+	case http.MethodDelete:
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			http.Error(w, "host required", http.StatusBadRequest)
+			return
+		}
+		if !s.Logins.Unlock(host) {
+			http.Error(w, "host not found", http.StatusNotFound)
+			return
+		}
+		auditEvent(r, audit.ActionLockoutClear, "host="+host)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultSocketPath returns the socket path used by -socket without an
+// explicit -socket-path. The pid keeps parallel agents apart.
+func DefaultSocketPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.TempDir()
+	}
+	return filepath.Join(home, ".mlcremote", fmt.Sprintf("agent-%d.sock", os.Getpid()))
+}
+
+// SocketInfoFile returns the file in which a socket-mode agent records its
+// socket path, so clients can find a running agent.
+func SocketInfoFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mlcremote", "socket")
+}
+
+// listenUnix creates a Unix socket at path that only the current user can
+// connect to. A stale socket left behind by a crashed agent is replaced;
+// a socket that still accepts connections is an error.
+func listenUnix(path string) (net.Listener, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("another agent is listening on %s", path)
+		}
+		log.Printf("[INFO] removing stale socket %s", path)
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// writeSocketInfo records the socket path in SocketInfoFile.
+func writeSocketInfo(path string) {
+	info := SocketInfoFile()
+	if info == "" {
+		return
+	}
+	if err := os.WriteFile(info, []byte(path+"\n"), 0600); err != nil {
+		log.Printf("[WARNING] failed to write %s: %v", info, err)
+	}
+}
+
+// removeSocketInfo deletes SocketInfoFile if it still points to path,
+// leaving the record of a newer agent alone.
+func removeSocketInfo(path string) {
+	info := SocketInfoFile()
+	if info == "" {
+		return
+	}
+	if data, err := os.ReadFile(info); err == nil && strings.TrimSpace(string(data)) == path {
+		os.Remove(info)
+	}
+}
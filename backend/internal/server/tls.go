@@ -0,0 +1,144 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfSignedValidity is the lifetime of generated certificates. Expired
+// certificates are regenerated on the next start.
+const selfSignedValidity = 2 * 365 * 24 * time.Hour
+
+// DefaultTLSDir returns the directory used for the generated certificate.
+func DefaultTLSDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".mlcremote", "tls")
+	}
+	return filepath.Join(home, ".mlcremote", "tls")
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER certificate as
+// lowercase hex, the format expected by the desktop client for pinning.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadTLSCertificate loads the configured key pair. Without explicit paths
+// a self-signed ECDSA certificate in DefaultTLSDir is used, and created if
+// it does not exist yet or has expired.
+func (s *Server) loadTLSCertificate() (tls.Certificate, error) {
+	certFile, keyFile := s.TLSCert, s.TLSKey
+	generated := false
+	if certFile == "" && keyFile == "" {
+		dir := DefaultTLSDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile = filepath.Join(dir, "key.pem")
+		if !validCertFile(certFile) {
+			if err := generateSelfSigned(certFile, keyFile, s.Host); err != nil {
+				return tls.Certificate{}, fmt.Errorf("generate self-signed certificate: %w", err)
+			}
+			generated = true
+		}
+	} else if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, fmt.Errorf("both -tls-cert and -tls-key are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if generated {
+		log.Printf("[INFO] generated self-signed TLS certificate %s", certFile)
+	} else {
+		log.Printf("[INFO] using TLS certificate %s", certFile)
+	}
+	return cert, nil
+}
+
+// validCertFile reports whether path holds a certificate that is not
+// expired (or about to expire within a day).
+func validCertFile(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false
+	}
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+	return time.Now().Add(24 * time.Hour).Before(c.NotAfter)
+}
+
+// generateSelfSigned writes a new P-256 key and a certificate valid for
+// localhost, the loopback addresses, the hostname and host.
+func generateSelfSigned(certFile, keyFile, host string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"MLCRemote"}, CommonName: "mlcremote agent"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hn, err := os.Hostname(); err == nil && hn != "" {
+		tmpl.DNSNames = append(tmpl.DNSNames, hn)
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+		tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+	} else if ip == nil && host != "" && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/auth"
+)
+
+type createTokenRequest struct {
+	Name   string   `json:"name"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+}
+
+type createTokenResponse struct {
+	Token string         `json:"token"`
+	Info  auth.TokenInfo `json:"info"`
+}
+
+// tokensHandler lists, creates and revokes named API tokens.
+// @Summary Manage API tokens
+// @Description GET lists tokens, POST creates one (the secret is only returned once), DELETE revokes the token given by id. Requires the admin scope.
+// @ID manageTokens
+// @Tags auth
+// @Security TokenAuth
+// @Accept json
+// @Produce json
+// @Param body body createTokenRequest false "Token to create (POST)"
+// @Param id query string false "Token id to revoke (DELETE)"
+// @Success 200 {array} auth.TokenInfo
+// @Success 201 {object} createTokenResponse
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /api/tokens [get]
+// @Router /api/tokens [post]
+// @Router /api/tokens [delete]
+func (s *Server) tokensHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Tokens == nil {
+		http.Error(w, "token store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Tokens.List())
+
+	case http.MethodPost:
+		var req createTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		info, secret, err := s.Tokens.Create(req.Name, req.Role, req.Scopes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		auditEvent(r, audit.ActionTokenCreate, fmt.Sprintf("id=%s name=%q scopes=%v", info.ID, info.Name, info.Scopes))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(createTokenResponse{Token: secret, Info: info})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		if err := s.Tokens.Revoke(id); err != nil {
+			if errors.Is(err, auth.ErrTokenNotFound) {
+				http.Error(w, "token not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to revoke token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		auditEvent(r, audit.ActionTokenRevoke, "id="+id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
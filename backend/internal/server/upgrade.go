@@ -0,0 +1,352 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"lightdev/internal/audit"
+	"lightdev/internal/handlers"
+)
+
+// Environment variables used to hand the listening socket to an upgraded
+// agent. EnvInheritToken carries a generated token, so clients keep
+// working with the token they already have.
+const (
+	envListenFD     = "MLCREMOTE_LISTEN_FD"
+	envReadyFD      = "MLCREMOTE_READY_FD"
+	EnvInheritToken = "MLCREMOTE_INHERIT_TOKEN"
+)
+
+const (
+	// maxUpgradeSize bounds the size of an uploaded agent binary.
+	maxUpgradeSize = 512 << 20
+	// upgradeReadyTimeout is how long the new agent may take to start
+	// serving before the upgrade is rolled back.
+	upgradeReadyTimeout = 20 * time.Second
+	// upgradeDrainTimeout is how long a replaced agent keeps serving open
+	// terminals before it exits anyway.
+	upgradeDrainTimeout = 24 * time.Hour
+)
+
+type upgradeResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	PID     int    `json:"pid"`
+}
+
+// upgradeHandler replaces the agent binary and restarts the agent on the
+// same socket.
+// @Summary Upgrade the agent
+// @Description Replaces the agent binary with the request body and restarts the agent in place. The SHA-256 of the binary must be given as sha256 query parameter or X-Checksum-SHA256 header. The new binary is checked with -version, swapped in atomically (the old one is kept as <binary>.old) and started with the listening socket, so port, socket and token stay the same. If it does not come up, the old binary is restored. Open terminals stay with the old agent until they close. Login sessions live in memory and are dropped, so browser users have to log in again; API tokens keep working. Requires the admin scope. Not available on Windows.
+// @ID upgradeAgent
+// @Tags system
+// @Security TokenAuth
+// @Accept application/octet-stream
+// @Produce json
+// @Param sha256 query string true "SHA-256 of the binary (hex)"
+// @Success 200 {object} upgradeResponse
+// @Failure 400 "Missing or mismatching checksum, or binary rejected"
+// @Failure 409 "Upgrade already in progress"
+// @Failure 500 "Upgrade failed and was rolled back"
+// @Router /api/agent/upgrade [post]
+func (s *Server) upgradeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if runtime.GOOS == "windows" {
+		http.Error(w, "in-place upgrade is not supported on Windows", http.StatusNotImplemented)
+		return
+	}
+	want := r.URL.Query().Get("sha256")
+	if want == "" {
+		want = r.Header.Get("X-Checksum-SHA256")
+	}
+	want = strings.ToLower(strings.TrimSpace(want))
+	if b, err := hex.DecodeString(want); err != nil || len(b) != sha256.Size {
+		http.Error(w, "sha256 checksum required", http.StatusBadRequest)
+		return
+	}
+
+	s.upgradeMu.Lock()
+	if s.upgrading {
+		s.upgradeMu.Unlock()
+		http.Error(w, "upgrade already in progress", http.StatusConflict)
+		return
+	}
+	s.upgrading = true
+	s.upgradeMu.Unlock()
+	done := false
+	defer func() {
+		if !done {
+			s.upgradeMu.Lock()
+			s.upgrading = false
+			s.upgradeMu.Unlock()
+		}
+	}()
+
+	w, ev := audit.Begin(w, r, audit.ActionAgentUpgrade)
+	defer ev.Done()
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		http.Error(w, "cannot locate agent binary: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ev.Path = exe
+
+	newPath := exe + ".new"
+	sum, err := receiveBinary(newPath, http.MaxBytesReader(w, r.Body, maxUpgradeSize))
+	if err != nil {
+		os.Remove(newPath)
+		http.Error(w, "failed to receive binary: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sum != want {
+		os.Remove(newPath)
+		ev.Detail = "checksum mismatch"
+		http.Error(w, fmt.Sprintf("checksum mismatch: got %s", sum), http.StatusBadRequest)
+		return
+	}
+
+	version, err := binaryVersion(newPath)
+	if err != nil {
+		os.Remove(newPath)
+		ev.Detail = "binary rejected"
+		http.Error(w, "new binary rejected: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ev.Detail = "version=" + version
+
+	oldPath := exe + ".old"
+	if err := os.Rename(exe, oldPath); err != nil {
+		os.Remove(newPath)
+		http.Error(w, "failed to keep old binary: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.Rename(newPath, exe); err != nil {
+		os.Rename(oldPath, exe)
+		os.Remove(newPath)
+		http.Error(w, "failed to install new binary: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[INFO] upgrade: starting agent %s (version %s)", exe, version)
+	pid, err := s.handOver(exe)
+	if err != nil {
+		log.Printf("[ERROR] upgrade: new agent failed, rolling back: %v", err)
+		if rbErr := os.Rename(oldPath, exe); rbErr != nil {
+			log.Printf("[ERROR] upgrade: rollback failed: %v", rbErr)
+			http.Error(w, fmt.Sprintf("new agent failed (%v) and rollback failed: %v", err, rbErr), http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, fmt.Sprintf("new agent failed, rolled back: %v", err), http.StatusInternalServerError)
+		return
+	}
+	done = true
+	updatePidFile(pid)
+
+	log.Printf("[INFO] upgrade: agent pid %d is serving, retiring", pid)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(upgradeResponse{Status: "upgraded", Version: version, PID: pid})
+	// send the response before the listener is handed over
+	_ = http.NewResponseController(w).Flush()
+	go s.retire()
+}
+
+// updatePidFile points ~/.mlcremote/pid, through which the desktop app
+// checks the agent, to the upgraded agent.
+func updatePidFile(pid int) {
+	pidFile, ok := ownPidFile()
+	if !ok {
+		return
+	}
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		log.Printf("[WARNING] upgrade: failed to update %s: %v", pidFile, err)
+	}
+}
+
+// ownPidFile returns the path of ~/.mlcremote/pid and whether it holds
+// the pid of this process.
+func ownPidFile() (string, bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	pidFile := filepath.Join(home, ".mlcremote", "pid")
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return pidFile, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pidFile, err == nil && pid == os.Getpid()
+}
+
+// receiveBinary writes body to path and returns its hex SHA-256.
+func receiveBinary(path string, body io.Reader) (string, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), body); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// binaryVersion runs path -version to check that it is an agent binary
+// that runs on this host.
+func binaryVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "-version").Output()
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(out))
+	if version == "" || strings.ContainsAny(version, "\n ") {
+		return "", errors.New("unexpected -version output")
+	}
+	return version, nil
+}
+
+// waitReady waits until the new agent writes to ready, exits, or the
+// timeout passes.
+func waitReady(ready io.Reader, exited <-chan error) error {
+	got := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(ready).ReadString('\n')
+		if err == nil && strings.TrimSpace(line) != "ready" {
+			err = fmt.Errorf("unexpected ready message %q", line)
+		}
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			// the pipe closes without a message when the agent exits
+			select {
+			case exitErr := <-exited:
+				return fmt.Errorf("new agent exited: %v", exitErr)
+			case <-time.After(time.Second):
+			}
+		}
+		return err
+	case err := <-exited:
+		return fmt.Errorf("new agent exited: %v", err)
+	case <-time.After(upgradeReadyTimeout):
+		return errors.New("new agent did not become ready in time")
+	}
+}
+
+// retire stops serving HTTP after the listener was handed to the new
+// agent. Hijacked connections, i.e. open terminals, keep working until
+// DrainTerminals returns.
+func (s *Server) retire() {
+	// the socket file now belongs to the new agent
+	if ul, ok := s.rawListener.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		// event streams do not end on their own, clients reconnect
+		s.httpServer.Close()
+	}
+	close(s.retired)
+}
+
+// Retired is closed once the agent was replaced by an upgrade.
+func (s *Server) Retired() <-chan struct{} {
+	return s.retired
+}
+
+// DrainTerminals is called after an upgrade. It waits until the open
+// terminals are closed, stop receives a value or upgradeDrainTimeout
+// passes, then closes the remaining sessions and stops the background
+// services. Unlike Shutdown it leaves the socket to the new agent.
+func (s *Server) DrainTerminals(stop <-chan os.Signal) {
+	deadline := time.After(upgradeDrainTimeout)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+wait:
+	for handlers.ActiveSessionCount() > 0 {
+		select {
+		case <-stop:
+			log.Printf("[INFO] upgrade: signal received, closing %d terminal sessions", handlers.ActiveSessionCount())
+			break wait
+		case <-deadline:
+			break wait
+		case <-tick.C:
+		}
+	}
+	handlers.ShutdownAllSessions()
+	if s.Watcher != nil {
+		s.Watcher.Stop()
+	}
+	if s.StatsCollector != nil {
+		s.StatsCollector.Stop()
+	}
+}
+
+// InheritedListener returns the listener handed over by the agent that
+// started this one during an upgrade, or nil if there is none.
+func InheritedListener() (net.Listener, error) {
+	v := os.Getenv(envListenFD)
+	if v == "" {
+		return nil, nil
+	}
+	os.Unsetenv(envListenFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", envListenFD, err)
+	}
+	f := os.NewFile(uintptr(fd), "listener")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// NotifyReady tells the agent that started this one during an upgrade
+// that the new agent is serving.
+func NotifyReady() {
+	v := os.Getenv(envReadyFD)
+	if v == "" {
+		return
+	}
+	os.Unsetenv(envReadyFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, _ = f.WriteString("ready\n")
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build !windows
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// handOver starts exe with the agent's arguments, passing it the
+// listening socket, and waits until it reports ready. It returns the pid
+// of the new agent; on error the new agent has been stopped.
+func (s *Server) handOver(exe string) (int, error) {
+	var lf *os.File
+	var err error
+	switch ln := s.rawListener.(type) {
+	case *net.TCPListener:
+		lf, err = ln.File()
+	case *net.UnixListener:
+		lf, err = ln.File()
+	default:
+		err = errors.New("listener cannot be handed over")
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer lf.Close()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// ExtraFiles start at fd 3
+	cmd.ExtraFiles = []*os.File{lf, readyW}
+	cmd.Env = append(os.Environ(), envListenFD+"=3", envReadyFD+"=4")
+	if s.AuthToken != "" {
+		cmd.Env = append(cmd.Env, EnvInheritToken+"="+s.AuthToken)
+	}
+	// keep the new agent alive when this one exits
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		readyW.Close()
+		return 0, fmt.Errorf("start: %w", err)
+	}
+	readyW.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	if err := waitReady(readyR, exited); err != nil {
+		cmd.Process.Kill()
+		return 0, err
+	}
+	return cmd.Process.Pid, nil
+}
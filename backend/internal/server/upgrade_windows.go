@@ -0,0 +1,15 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build windows
+
+package server
+
+import "errors"
+
+// handOver is not supported on Windows: sockets cannot be inherited
+// through os/exec there.
+func (s *Server) handOver(exe string) (int, error) {
+	return 0, errors.New("in-place upgrade is not supported on Windows")
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+var backups atomic.Bool
+
+// SetBackups enables keeping the previous content of a file replaced by
+// an AtomicFile as <name>.bak.
+func SetBackups(enabled bool) {
+	backups.Store(enabled)
+}
+
+// BackupsEnabled reports whether backups are enabled.
+func BackupsEnabled() bool {
+	return backups.Load()
+}
+
+// AtomicFile writes a file so that readers see either the old or the new
+// content, never a partial write. Data goes to a temporary file in the
+// same directory, which Commit syncs, gives the mode, owner and extended
+// attributes of the file it replaces, and renames into place.
+//
+// If no temporary file can be created in the directory, e.g. because only
+// the file itself is writable, the data is spooled to a temporary file
+// elsewhere and copied over the file by Commit. The file is untouched
+// until then, so Abort still leaves it intact, but the copy itself is not
+// atomic.
+type AtomicFile struct {
+	*os.File
+	target string
+	tmp    string
+	// inPlace is set if tmp is a spool file copied into target on Commit
+	inPlace bool
+	done    bool
+}
+
+// CreateAtomic starts writing name. perm is used if the file does not
+// exist yet. The caller must call Commit or Abort.
+func CreateAtomic(name string, perm os.FileMode) (*AtomicFile, error) {
+	// replace the file a symlink points to, not the symlink
+	if fi, err := os.Lstat(name); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if resolved, err := filepath.EvalSymlinks(name); err == nil {
+			name = resolved
+		}
+	}
+	f, tmp, err := createTemp(name, perm)
+	if err == nil {
+		return &AtomicFile{File: f, target: name, tmp: tmp}, nil
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		return nil, err
+	}
+	// the directory is not writable, but the file may be; find out now
+	// rather than after the data was received
+	t, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.Close()
+	f, err = os.CreateTemp("", "mlcremote-spool-*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: f, target: name, tmp: f.Name(), inPlace: true}, nil
+}
+
+// tempInfix marks the temporary files of an AtomicFile.
+const tempInfix = ".tmp-"
+
+// IsAtomicTemp reports whether name is the temporary file of an
+// AtomicFile.
+func IsAtomicTemp(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, ".") && strings.Contains(base, tempInfix)
+}
+
+// createTemp creates a new file next to name. Unlike os.CreateTemp it
+// uses perm, so the umask applies as for a regular create. It is a
+// variable so tests can simulate a directory that is not writable.
+var createTemp = func(name string, perm os.FileMode) (*os.File, string, error) {
+	dir, base := filepath.Split(name)
+	b := make([]byte, 6)
+	for i := 0; ; i++ {
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		tmp := filepath.Join(dir, "."+base+tempInfix+hex.EncodeToString(b))
+		f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err == nil || !os.IsExist(err) || i == 10 {
+			return f, tmp, err
+		}
+	}
+}
+
+// Commit makes the written data the content of the file.
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return errors.New("atomic file already committed or aborted")
+	}
+	f.done = true
+	if f.inPlace {
+		err := f.copyInPlace()
+		f.File.Close()
+		f.remove()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.File.Close()
+		f.remove()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		f.remove()
+		return err
+	}
+	if fi, err := os.Stat(f.target); err == nil {
+		copyAttrs(f.target, f.tmp, fi)
+		if err := backup(f.target, true); err != nil {
+			f.remove()
+			return err
+		}
+	}
+	if err := os.Rename(f.tmp, f.target); err != nil {
+		f.remove()
+		return err
+	}
+	syncDir(filepath.Dir(f.target))
+	return nil
+}
+
+// CommitNew is like Commit, but only creates the file: if it exists, the
+// data is discarded and an error matching fs.ErrExist is returned. The
+// check and the creation are one step, so of two concurrent calls only
+// one succeeds.
+func (f *AtomicFile) CommitNew() error {
+	if f.done {
+		return errors.New("atomic file already committed or aborted")
+	}
+	if f.inPlace {
+		// only existing files are written in place
+		f.Abort()
+		return &fs.PathError{Op: "create", Path: f.target, Err: fs.ErrExist}
+	}
+	f.done = true
+	defer f.remove()
+	if err := f.Sync(); err != nil {
+		f.File.Close()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	err := os.Link(f.tmp, f.target)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		// no hard links on this filesystem: reserve the name, then
+		// replace the empty file
+		var t *os.File
+		if t, err = os.OpenFile(f.target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err == nil {
+			t.Close()
+			if err = os.Rename(f.tmp, f.target); err != nil {
+				os.Remove(f.target)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(f.target))
+	return nil
+}
+
+// Abort discards the written data. It does nothing after Commit, so it
+// can be deferred.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	f.remove()
+}
+
+func (f *AtomicFile) remove() {
+	os.Remove(f.tmp)
+}
+
+// copyInPlace overwrites the target with the spooled data.
+func (f *AtomicFile) copyInPlace() error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	// a backup cannot be created by linking in this directory either
+	if err := backup(f.target, false); err != nil {
+		log.Printf("[WARNING] cannot back up %s: %v", f.target, err)
+	}
+	out, err := os.OpenFile(f.target, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, f.File); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// WriteFileAtomic is like os.WriteFile, but writes through an AtomicFile.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(name, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// ReplaceFile moves src, a file in the same filesystem, to name, keeping
+// the mode, owner and extended attributes of the file it replaces.
+func ReplaceFile(src, name string) error {
+	if fi, err := os.Lstat(name); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if resolved, err := filepath.EvalSymlinks(name); err == nil {
+			name = resolved
+		}
+	}
+	if fi, err := os.Stat(name); err == nil {
+		copyAttrs(name, src, fi)
+		if err := backup(name, true); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(src, name); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(name))
+	return nil
+}
+
+// copyAttrs gives dst the mode, owner and extended attributes of src,
+// which fi describes. Failures are logged: a file that cannot be chowned
+// is still better saved than not.
+func copyAttrs(src, dst string, fi os.FileInfo) {
+	if err := os.Chmod(dst, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		log.Printf("[WARNING] cannot keep mode of %s: %v", src, err)
+	}
+	if err := copyOwner(dst, fi); err != nil {
+		log.Printf("[DEBUG] cannot keep owner of %s: %v", src, err)
+	}
+	if err := copyXattrs(src, dst); err != nil {
+		log.Printf("[DEBUG] cannot keep extended attributes of %s: %v", src, err)
+	}
+}
+
+// backup keeps the current content of name as name.bak if backups are
+// enabled. If name is about to be replaced, link allows a hard link;
+// when it is rewritten in place, or links are not supported, it is copied.
+func backup(name string, link bool) error {
+	if !BackupsEnabled() {
+		return nil
+	}
+	fi, err := os.Stat(name)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+	bak := name + ".bak"
+	os.Remove(bak)
+	if link {
+		if err := os.Link(name, bak); err == nil {
+			return nil
+		}
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(bak, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAtomicInPlace writes a file whose directory does not allow a
+// temporary file. The file must keep its content until Commit.
+func TestAtomicInPlace(t *testing.T) {
+	defer func(f func(string, os.FileMode) (*os.File, string, error)) { createTemp = f }(createTemp)
+	createTemp = func(string, os.FileMode) (*os.File, string, error) {
+		return nil, "", fs.ErrPermission
+	}
+	name := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(name, []byte("old content\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check := func(when, want string) {
+		t.Helper()
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: file holds %q, want %q", when, got, want)
+		}
+	}
+
+	f, err := CreateAtomic(name, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool := f.File.Name()
+	if _, err := f.WriteString("new"); err != nil {
+		t.Fatal(err)
+	}
+	check("while writing", "old content\n")
+	f.Abort()
+	check("after Abort", "old content\n")
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Errorf("spool file %s left after Abort", spool)
+	}
+
+	f, err = CreateAtomic(name, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool = f.File.Name()
+	if _, err := f.WriteString("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	check("after Commit", "new")
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Errorf("spool file %s left after Commit", spool)
+	}
+
+	// without a file to write in place the error is reported up front
+	if _, err := CreateAtomic(filepath.Join(filepath.Dir(name), "missing.txt"), 0644); err == nil {
+		t.Error("CreateAtomic of a missing file in a read-only directory succeeded")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build !windows
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner gives name the owner and group in fi, if they differ from
+// its own. Only root, or the owner for a group it belongs to, may do so.
+func copyOwner(name string, fi os.FileInfo) error {
+	want, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	cur, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if have, ok := cur.Sys().(*syscall.Stat_t); ok && have.Uid == want.Uid && have.Gid == want.Gid {
+		return nil
+	}
+	return os.Lchown(name, int(want.Uid), int(want.Gid))
+}
+
+// syncDir flushes a rename in dir to disk.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
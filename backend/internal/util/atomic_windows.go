@@ -0,0 +1,18 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build windows
+
+package util
+
+import "os"
+
+// copyOwner does nothing on Windows, where new files inherit the ACL of
+// their directory.
+func copyOwner(name string, fi os.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing on Windows, which cannot sync directories.
+func syncDir(dir string) {}
@@ -30,15 +30,18 @@ func copyFile(src, dst string, mode os.FileMode) error {
 	}
 	defer in.Close()
 
-	out, err := os.Create(dst)
+	out, err := CreateAtomic(dst, mode.Perm())
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	defer out.Abort()
 
 	if _, err := io.Copy(out, in); err != nil {
 		return err
 	}
+	if err := out.Commit(); err != nil {
+		return err
+	}
 	return os.Chmod(dst, mode)
 }
 
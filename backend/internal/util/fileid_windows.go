@@ -0,0 +1,15 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build windows
+
+package util
+
+import "os"
+
+// fileID returns 0 on Windows: the file index needs an open handle and
+// size and modification time identify a version well enough there.
+func fileID(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -6,39 +6,150 @@ package util
 
 import (
 	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
+// Confinement selects which paths SanitizePath accepts.
+type Confinement int
+
+const (
+	// ConfineHome accepts paths inside the root or the user's home
+	// directory.
+	ConfineHome Confinement = iota
+	// ConfineJail accepts only paths that resolve inside the root,
+	// following symlinks.
+	ConfineJail
+	// ConfineUnrestricted accepts any path. It is the default, as
+	// agents always accepted any path before confinement was added.
+	ConfineUnrestricted
+)
+
+var confinementNames = []string{"home", "jail", "unrestricted"}
+
+func (c Confinement) String() string {
+	if c < ConfineHome || c > ConfineUnrestricted {
+		return fmt.Sprintf("confinement(%d)", int(c))
+	}
+	return confinementNames[c]
+}
+
+// ParseConfinement parses "jail", "home" or "unrestricted".
+func ParseConfinement(s string) (Confinement, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "home":
+		return ConfineHome, nil
+	case "jail":
+		return ConfineJail, nil
+	case "unrestricted", "":
+		return ConfineUnrestricted, nil
+	}
+	return ConfineUnrestricted, fmt.Errorf("unknown confinement %q (use jail, home or unrestricted)", s)
+}
+
+// ErrOutsideRoot is returned by SanitizePath for paths outside the area
+// allowed by the confinement.
+var ErrOutsideRoot = errors.New("access denied: path outside root")
+
+var (
+	confMu      sync.RWMutex
+	confinement = ConfineUnrestricted
+)
+
+// SetConfinement sets the confinement used by SanitizePath.
+func SetConfinement(c Confinement) {
+	confMu.Lock()
+	confinement = c
+	confMu.Unlock()
+}
+
+// CurrentConfinement returns the confinement used by SanitizePath.
+func CurrentConfinement() Confinement {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return confinement
+}
+
+// allowedDirs returns the resolved directories paths must lie in, or nil
+// if any path is allowed.
+func allowedDirs(root string) []string {
+	c := CurrentConfinement()
+	if c == ConfineUnrestricted {
+		return nil
+	}
+	dirs := []string{resolvePath(root)}
+	if c == ConfineHome {
+		if home, err := os.UserHomeDir(); err == nil {
+			dirs = append(dirs, resolvePath(home))
+		}
+	}
+	return dirs
+}
+
+// maxLinks bounds the symlinks followed by resolvePath.
+const maxLinks = 40
+
+// resolvePath returns the absolute path p with all symlinks resolved. For
+// a path that does not exist yet, the nearest existing ancestor is
+// resolved; a dangling symlink is followed to where it points, since
+// creating the file would create it there.
+func resolvePath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	for links := 0; ; {
+		cur, rest := abs, ""
+		for {
+			if r, err := filepath.EvalSymlinks(cur); err == nil {
+				return filepath.Join(r, rest)
+			}
+			if fi, err := os.Lstat(cur); err == nil && fi.Mode()&os.ModeSymlink != 0 && links < maxLinks {
+				target, err := os.Readlink(cur)
+				if err == nil {
+					if !filepath.IsAbs(target) {
+						target = filepath.Join(filepath.Dir(cur), target)
+					}
+					links++
+					abs = filepath.Join(target, rest)
+					break
+				}
+			}
+			parent := filepath.Dir(cur)
+			if parent == cur {
+				return abs
+			}
+			rest = filepath.Join(filepath.Base(cur), rest)
+			cur = parent
+		}
+	}
+}
+
+// within reports whether p is dir or lies below it.
+func within(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // SanitizePath resolves a requested path against the configured root and
-// ensures the target path is within root by resolving symlinks.
-// It supports both absolute paths (if they are within root) and relative paths.
+// checks it against the confinement set with SetConfinement, following
+// symlinks. It supports both absolute paths and paths relative to root.
+// Paths outside the allowed area yield ErrOutsideRoot.
 func SanitizePath(root string, req string) (string, error) {
 	if req == "" {
 		req = "."
 	}
+	dirs := allowedDirs(root)
 
 	// Helper to validate a candidate path
 	validate := func(candidate string) (string, error) {
-		resolved, err := filepath.EvalSymlinks(candidate)
-		if err != nil {
-			resolved = candidate
-		}
-		abs, err := filepath.Abs(resolved)
-		if err != nil {
-			return "", err
-		}
-		// rootAbs, err := filepath.Abs(root)
-		// if err != nil {
-		// 	return "", err
-		// }
-		// rel, err := filepath.Rel(rootAbs, abs)
-		// if err != nil {
-		// 	return "", err
-		// }
-		// if strings.HasPrefix(rel, "..") {
-		// 	return "", errors.New("path outside root")
-		// }
+		abs := resolvePath(candidate)
 
 		// Check for protected files
 		base := strings.ToLower(filepath.Base(abs))
@@ -56,24 +167,40 @@ func SanitizePath(root string, req string) (string, error) {
 				return "", errors.New("access denied: protected system file")
 			}
 		}
-		return abs, nil
+
+		if dirs == nil {
+			return abs, nil
+		}
+		for _, dir := range dirs {
+			if within(abs, dir) {
+				return abs, nil
+			}
+		}
+		return "", ErrOutsideRoot
 	}
 
 	// Strategy 1: If path is absolute, try to use it directly
 	if filepath.IsAbs(req) {
 		// Clean the path first
 		clean := filepath.Clean(req)
-		if res, err := validate(clean); err == nil {
+		res, err := validate(clean)
+		if err == nil {
 			return res, nil
 		}
 		// If verification failed (e.g. outside root), fall through to relative strategy
 		// This handles the case where frontend requests "/" meaning "workspace root"
 		// which is strictly outside filesystem root, but valid as a relative request.
+		// A path outside root that does not exist below root either is refused.
+		if errors.Is(err, ErrOutsideRoot) {
+			rel := filepath.Join(root, filepath.Clean(strings.TrimPrefix(req, "/")))
+			if _, statErr := os.Stat(filepath.Dir(rel)); statErr != nil {
+				return "", err
+			}
+		}
 	}
 
 	// Strategy 2: Treat as relative to root
 	clean := filepath.Clean(strings.TrimPrefix(req, "/"))
 	candidate := filepath.Join(root, clean)
-	// fmt.Printf("[DEBUG] SanitizePath Strategy 2: req=%s clean=%s candidate=%s\n", req, clean, candidate)
 	return validate(candidate)
 }
@@ -0,0 +1,89 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "root")
+	home := filepath.Join(base, "home")
+	out := filepath.Join(base, "out")
+	for _, d := range []string{filepath.Join(root, "sub"), home, out} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(root, "a.txt"), filepath.Join(home, "h.txt"), filepath.Join(out, "f")} {
+		if err := os.WriteFile(f, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(out, filepath.Join(root, "link-out")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := os.Symlink(filepath.Join(out, "new"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	prev := CurrentConfinement()
+	t.Cleanup(func() { SetConfinement(prev) })
+
+	tests := []struct {
+		conf Confinement
+		req  string
+		// want is relative to base; empty means an error
+		want    string
+		outside bool
+	}{
+		{ConfineJail, "", "root", false},
+		{ConfineJail, "a.txt", "root/a.txt", false},
+		{ConfineJail, "/a.txt", "root/a.txt", false},
+		{ConfineJail, "sub/new.txt", "root/sub/new.txt", false},
+		{ConfineJail, root + "/sub", "root/sub", false},
+		{ConfineJail, "../out/f", "", true},
+		{ConfineJail, "/../out/f", "", true},
+		{ConfineJail, "link-out/f", "", true},
+		{ConfineJail, "dangling", "", true},
+		{ConfineJail, home + "/h.txt", "", true},
+		{ConfineJail, "pagefile.sys", "", false},
+		{ConfineHome, "a.txt", "root/a.txt", false},
+		{ConfineHome, home + "/h.txt", "home/h.txt", false},
+		{ConfineHome, "link-out/f", "", true},
+		{ConfineHome, out + "/f", "", true},
+		{ConfineUnrestricted, "link-out/f", "out/f", false},
+		{ConfineUnrestricted, "dangling", "out/new", false},
+		{ConfineUnrestricted, out + "/f", "out/f", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conf.String()+" "+tt.req, func(t *testing.T) {
+			SetConfinement(tt.conf)
+			got, err := SanitizePath(root, tt.req)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("SanitizePath(%q) = %q, want error", tt.req, got)
+				}
+				if errors.Is(err, ErrOutsideRoot) != tt.outside {
+					t.Fatalf("SanitizePath(%q) error %v, outside root = %v", tt.req, err, tt.outside)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SanitizePath(%q): %v", tt.req, err)
+			}
+			if want := filepath.Join(base, filepath.FromSlash(tt.want)); got != want {
+				t.Fatalf("SanitizePath(%q) = %q, want %q", tt.req, got, want)
+			}
+		})
+	}
+}
@@ -115,13 +115,15 @@ func StartShellPTY(shell, cwd string, extraEnv []string) (io.ReadWriteCloser, *e
 		cmd := exec.Command(exe, args...)
 		cmd.Env = append(os.Environ(), "TERM=xterm-256color")
 		if len(extraEnv) > 0 {
-			log.Printf("StartShellPTY: Appending extraEnv: %v", extraEnv) // Added debug log
 			cmd.Env = append(cmd.Env, extraEnv...)
 		}
-		// Log specific vars to verify presence in final env
+		// Log specific vars to verify presence in final env; the token
+		// is a credential and only its presence is logged
 		for _, e := range cmd.Env {
-			if strings.Contains(e, "MLCREMOTE_API_URL") || strings.Contains(e, "MLCREMOTE_TOKEN") {
+			if strings.Contains(e, "MLCREMOTE_API_URL") {
 				log.Printf("StartShellPTY: Env contains: %s", e)
+			} else if strings.HasPrefix(e, "MLCREMOTE_TOKEN=") {
+				log.Printf("StartShellPTY: Env contains: MLCREMOTE_TOKEN")
 			}
 		}
 		if cwd != "" {
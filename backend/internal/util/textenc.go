@@ -0,0 +1,433 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Text encodings recognised by DetectText.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingLatin1      = "iso-8859-1"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// Line ending styles reported by DetectEOL.
+const (
+	EOLLF    = "lf"
+	EOLCRLF  = "crlf"
+	EOLCR    = "cr"
+	EOLMixed = "mixed"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// TextInfo describes how a text file is encoded.
+type TextInfo struct {
+	Encoding string `json:"encoding"`
+	// BOM is whether the file starts with a byte order mark.
+	BOM bool `json:"bom"`
+	// EOL is the line ending style, empty for a single line.
+	EOL string `json:"eol,omitempty"`
+}
+
+// NormalizeEncoding returns the canonical name of a supported encoding.
+func NormalizeEncoding(name string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", "-")) {
+	case "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "utf-16le", "utf16le", "utf-16", "utf16":
+		return EncodingUTF16LE, nil
+	case "utf-16be", "utf16be":
+		return EncodingUTF16BE, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1":
+		return EncodingLatin1, nil
+	case "windows-1252", "cp1252":
+		return EncodingWindows1252, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q", name)
+}
+
+// NormalizeEOL returns the canonical name of a line ending style that
+// content can be converted to.
+func NormalizeEOL(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "lf", "\n", "unix":
+		return EOLLF, nil
+	case "crlf", "\r\n", "windows", "dos":
+		return EOLCRLF, nil
+	case "cr", "\r", "mac":
+		return EOLCR, nil
+	}
+	return "", fmt.Errorf("unsupported line ending %q (use lf, crlf or cr)", name)
+}
+
+// DetectText detects the encoding and line endings of data. It reports
+// false for data that does not look like text.
+//
+// A BOM decides the encoding. Without one, NUL bytes in every other
+// position indicate UTF-16; data that is not valid UTF-8 is taken as
+// Windows-1252 if it uses the bytes 0x80-0x9F, and as ISO-8859-1
+// otherwise. If data is only the beginning of a file, a multi-byte
+// character cut off at the end does not count as invalid.
+func DetectText(data []byte) (TextInfo, bool) {
+	info := TextInfo{Encoding: EncodingUTF8}
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		info.BOM = true
+	case bytes.HasPrefix(data, bomUTF16LE):
+		info.Encoding, info.BOM = EncodingUTF16LE, true
+	case bytes.HasPrefix(data, bomUTF16BE):
+		info.Encoding, info.BOM = EncodingUTF16BE, true
+	default:
+		if enc := guessUTF16(data); enc != "" {
+			info.Encoding = enc
+		} else if bytes.IndexByte(data, 0) >= 0 {
+			return TextInfo{}, false
+		} else if !validUTF8Prefix(data) {
+			info.Encoding = EncodingLatin1
+			for _, b := range data {
+				if b >= 0x80 && b <= 0x9F {
+					info.Encoding = EncodingWindows1252
+					break
+				}
+			}
+		}
+	}
+	if info.Encoding == EncodingUTF16LE || info.Encoding == EncodingUTF16BE {
+		// an odd byte may be the first half of a unit cut off at the end
+		data = data[:len(data)&^1]
+	} else if info.BOM || info.Encoding == EncodingUTF8 {
+		data = trimPartialRune(data)
+	}
+	text, err := DecodeText(data, info)
+	if err != nil || !looksLikeText(text) {
+		return TextInfo{}, false
+	}
+	info.EOL = DetectEOL(text)
+	return info, true
+}
+
+// looksLikeText reports whether text has no NUL and few other control
+// characters.
+func looksLikeText(text string) bool {
+	controls := 0
+	for _, r := range text {
+		switch {
+		case r == 0:
+			return false
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != '\v' && r != '\b' && r != 0x1B:
+			controls++
+		}
+	}
+	return controls*100 <= len(text)
+}
+
+// guessUTF16 recognises UTF-16 without a BOM by the zero high bytes of
+// ASCII characters.
+func guessUTF16(data []byte) string {
+	n := len(data) &^ 1
+	if n < 4 {
+		return ""
+	}
+	var even, odd int
+	for i := 0; i < n; i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+	units := n / 2
+	switch {
+	case odd*10 >= units*4 && even*20 < units:
+		return EncodingUTF16LE
+	case even*10 >= units*4 && odd*20 < units:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// validUTF8Prefix reports whether data is valid UTF-8 apart from a
+// character cut off at the end.
+func validUTF8Prefix(data []byte) bool {
+	return utf8.Valid(trimPartialRune(data))
+}
+
+// trimPartialRune removes an incomplete UTF-8 sequence at the end of data.
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < 0x80 {
+			return data
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			return data
+		}
+	}
+	return data
+}
+
+// DetectFileText is DetectText for the first max bytes of the file name.
+func DetectFileText(name string, max int64) (TextInfo, bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return TextInfo{}, false, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, max))
+	if err != nil {
+		return TextInfo{}, false, err
+	}
+	info, ok := DetectText(data)
+	return info, ok, nil
+}
+
+// ScanUTF8 is DetectText for UTF-8 data read from r, which it checks in
+// chunks instead of holding it in memory. It reports false if the data is
+// not UTF-8 text, including text in another encoding.
+func ScanUTF8(r io.Reader) (TextInfo, bool, error) {
+	info := TextInfo{Encoding: EncodingUTF8}
+	buf := make([]byte, 64<<10)
+	var lf, crlf, cr, controls, total int
+	keep, first, prevCR := 0, true, false
+	for {
+		n, err := io.ReadFull(r, buf[keep:])
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return TextInfo{}, false, err
+		}
+		data := buf[:keep+n]
+		if first {
+			first = false
+			if bytes.HasPrefix(data, bomUTF8) {
+				info.BOM = true
+				data = data[len(bomUTF8):]
+			}
+		}
+		chunk := data
+		if !eof {
+			// a character cut off here continues in the next chunk
+			chunk = trimPartialRune(data)
+		}
+		if !utf8.Valid(chunk) {
+			return TextInfo{}, false, nil
+		}
+		// the same checks as looksLikeText and DetectEOL; a line break
+		// may span two chunks
+		for _, c := range chunk {
+			if prevCR && c != '\n' {
+				cr++
+			}
+			switch {
+			case c == '\n' && prevCR:
+				crlf++
+			case c == '\n':
+				lf++
+			case c == 0:
+				return TextInfo{}, false, nil
+			case c < 0x20 && c != '\t' && c != '\r' && c != '\f' && c != '\v' && c != '\b' && c != 0x1B:
+				controls++
+			}
+			prevCR = c == '\r'
+		}
+		total += len(chunk)
+		keep = copy(buf, data[len(chunk):])
+		if eof {
+			break
+		}
+	}
+	if prevCR {
+		cr++
+	}
+	if controls*100 > total {
+		return TextInfo{}, false, nil
+	}
+	info.EOL = eolStyle(lf, crlf, cr)
+	return info, true, nil
+}
+
+// DetectEOL returns the line ending style of text: EOLLF, EOLCRLF, EOLCR,
+// EOLMixed if it uses several, or "" if it has no line breaks.
+func DetectEOL(text string) string {
+	var lf, crlf, cr int
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			lf++
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				crlf++
+				i++
+			} else {
+				cr++
+			}
+		}
+	}
+	return eolStyle(lf, crlf, cr)
+}
+
+// eolStyle returns the line ending style for the given numbers of line
+// breaks of each kind.
+func eolStyle(lf, crlf, cr int) string {
+	style := ""
+	for _, c := range []struct {
+		n     int
+		style string
+	}{{lf, EOLLF}, {crlf, EOLCRLF}, {cr, EOLCR}} {
+		if c.n == 0 {
+			continue
+		}
+		if style != "" {
+			return EOLMixed
+		}
+		style = c.style
+	}
+	return style
+}
+
+// ConvertEOL returns text with all line breaks replaced by the style eol.
+func ConvertEOL(text, eol string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	switch eol {
+	case EOLCRLF:
+		return strings.ReplaceAll(text, "\n", "\r\n")
+	case EOLCR:
+		return strings.ReplaceAll(text, "\n", "\r")
+	}
+	return text
+}
+
+// DecodeText converts data in the encoding described by info to UTF-8,
+// dropping the BOM.
+func DecodeText(data []byte, info TextInfo) (string, error) {
+	switch info.Encoding {
+	case EncodingUTF8:
+		return string(bytes.TrimPrefix(data, bomUTF8)), nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+		if info.Encoding == EncodingUTF16LE {
+			data = bytes.TrimPrefix(data, bomUTF16LE)
+		} else {
+			data = bytes.TrimPrefix(data, bomUTF16BE)
+		}
+		if len(data)%2 != 0 {
+			return "", fmt.Errorf("%s data has odd length", info.Encoding)
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if info.Encoding == EncodingUTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	case EncodingLatin1, EncodingWindows1252:
+		var sb strings.Builder
+		sb.Grow(len(data) + len(data)/4)
+		for _, b := range data {
+			r := rune(b)
+			if info.Encoding == EncodingWindows1252 && b >= 0x80 && b <= 0x9F && cp1252[b-0x80] != 0 {
+				r = cp1252[b-0x80]
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q", info.Encoding)
+}
+
+// EncodeText converts text to the encoding described by info, with a BOM
+// if info.BOM is set. Characters the encoding cannot represent are an
+// error.
+func EncodeText(text string, info TextInfo) ([]byte, error) {
+	switch info.Encoding {
+	case EncodingUTF8:
+		if !info.BOM {
+			return []byte(text), nil
+		}
+		return append(append([]byte{}, bomUTF8...), text...), nil
+	case EncodingUTF16LE, EncodingUTF16BE:
+		units := utf16.Encode([]rune(text))
+		out := make([]byte, 0, 2+2*len(units))
+		le := info.Encoding == EncodingUTF16LE
+		if info.BOM && le {
+			out = append(out, bomUTF16LE...)
+		} else if info.BOM {
+			out = append(out, bomUTF16BE...)
+		}
+		for _, u := range units {
+			if le {
+				out = append(out, byte(u), byte(u>>8))
+			} else {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+		return out, nil
+	case EncodingLatin1, EncodingWindows1252:
+		if info.BOM {
+			return nil, fmt.Errorf("%s has no byte order mark", info.Encoding)
+		}
+		out := make([]byte, 0, len(text))
+		for i, r := range text {
+			b, ok := encodeByte(r, info.Encoding == EncodingWindows1252)
+			if !ok {
+				line := strings.Count(text[:i], "\n") + 1
+				return nil, fmt.Errorf("character %U on line %d cannot be encoded in %s", r, line, info.Encoding)
+			}
+			out = append(out, b)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", info.Encoding)
+}
+
+// encodeByte returns the ISO-8859-1 or Windows-1252 byte for r.
+func encodeByte(r rune, windows bool) (byte, bool) {
+	if windows {
+		for i, c := range cp1252 {
+			if c == r {
+				return byte(0x80 + i), true
+			}
+		}
+		// these runes stand for the bytes 0x80-0x9F that Windows-1252
+		// assigns to other characters
+		if r >= 0x80 && r <= 0x9F && cp1252[r-0x80] != 0 {
+			return 0, false
+		}
+	}
+	if r >= 0 && r <= 0xFF {
+		return byte(r), true
+	}
+	return 0, false
+}
+
+// cp1252 maps the bytes 0x80-0x9F of Windows-1252 to runes. The five
+// unassigned bytes are 0 and decode to the C1 control of the same value,
+// as in ISO-8859-1, so that any byte sequence round-trips.
+var cp1252 = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
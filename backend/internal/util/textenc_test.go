@@ -0,0 +1,88 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+// textCases are files in the encodings and line ending styles DetectText
+// recognises, with what it should report for them.
+var textCases = []struct {
+	name string
+	data []byte
+	want TextInfo
+	text string
+}{
+	{"utf-8", []byte("héllo\nwörld\n"), TextInfo{EncodingUTF8, false, EOLLF}, "héllo\nwörld\n"},
+	{"utf-8 bom crlf", []byte("\xef\xbb\xbfa\r\nb\r\n"), TextInfo{EncodingUTF8, true, EOLCRLF}, "a\r\nb\r\n"},
+	{"utf-16le bom", []byte("\xff\xfea\x00\r\x00\n\x00\xe9\x00"), TextInfo{EncodingUTF16LE, true, EOLCRLF}, "a\r\né"},
+	{"utf-16be bom", []byte("\xfe\xff\x00a\x00\n\x00b\xd8\x3d\xde\x00"), TextInfo{EncodingUTF16BE, true, EOLLF}, "a\nb😀"},
+	{"utf-16le without bom", []byte("h\x00i\x00\n\x00"), TextInfo{EncodingUTF16LE, false, EOLLF}, "hi\n"},
+	{"latin-1", []byte("caf\xe9\n\xfc\n"), TextInfo{EncodingLatin1, false, EOLLF}, "café\nü\n"},
+	{"windows-1252", []byte("\x93quote\x94 \x80\r\n"), TextInfo{EncodingWindows1252, false, EOLCRLF}, "“quote” €\r\n"},
+	{"cr", []byte("a\rb\r"), TextInfo{EncodingUTF8, false, EOLCR}, "a\rb\r"},
+	{"mixed", []byte("a\nb\r\nc\rd"), TextInfo{EncodingUTF8, false, EOLMixed}, "a\nb\r\nc\rd"},
+	{"single line", []byte("abc"), TextInfo{EncodingUTF8, false, ""}, "abc"},
+}
+
+func TestDetectText(t *testing.T) {
+	for _, tt := range textCases {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok := DetectText(tt.data)
+			if !ok {
+				t.Fatal("not detected as text")
+			}
+			if info != tt.want {
+				t.Fatalf("DetectText = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+	for _, data := range [][]byte{[]byte("\x00\x01\x02abc"), []byte("\x7fELF\x02\x01\x01\x00\x00\x00")} {
+		if info, ok := DetectText(data); ok {
+			t.Errorf("DetectText(%q) = %+v, want binary", data, info)
+		}
+	}
+}
+
+// TestTextRoundTrip checks that decoding a file for the editor and
+// encoding the unchanged text again gives the original bytes.
+func TestTextRoundTrip(t *testing.T) {
+	for _, tt := range textCases {
+		t.Run(tt.name, func(t *testing.T) {
+			info, _ := DetectText(tt.data)
+			text, err := DecodeText(tt.data, info)
+			if err != nil {
+				t.Fatalf("DecodeText: %v", err)
+			}
+			if text != tt.text {
+				t.Fatalf("DecodeText = %q, want %q", text, tt.text)
+			}
+			data, err := EncodeText(text, info)
+			if err != nil {
+				t.Fatalf("EncodeText: %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Fatalf("EncodeText = %q, want %q", data, tt.data)
+			}
+		})
+	}
+}
+
+func TestConvertEOL(t *testing.T) {
+	tests := []struct {
+		text, eol, want string
+	}{
+		{"a\nb\r\nc\rd", EOLLF, "a\nb\nc\nd"},
+		{"a\nb\r\nc\rd", EOLCRLF, "a\r\nb\r\nc\r\nd"},
+		{"a\nb\r\nc\rd", EOLCR, "a\rb\rc\rd"},
+	}
+	for _, tt := range tests {
+		if got := ConvertEOL(tt.text, tt.eol); got != tt.want {
+			t.Errorf("ConvertEOL(%q, %s) = %q, want %q", tt.text, tt.eol, got, tt.want)
+		}
+	}
+}
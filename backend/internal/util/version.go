@@ -0,0 +1,34 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileVersion returns a token that changes whenever the file described by
+// fi is written or replaced. It is derived from the inode, size and
+// modification time and is used as ETag and for conflict detection.
+//
+// The agent replaces files, which gives them a new inode, so its own saves
+// always change the version. A write in place by another program that
+// keeps the size within the filesystem's timestamp resolution (2s on FAT,
+// 1s on some network filesystems) goes unnoticed. On Windows, where the
+// inode is not available, the same holds for a replaced file.
+func FileVersion(fi os.FileInfo) string {
+	return fmt.Sprintf("%x-%x-%x", fileID(fi), fi.Size(), fi.ModTime().UnixNano())
+}
+
+// ParseVersion returns the version in an ETag or If-Match value, without
+// quotes and the suffix of the BOM-stripped or decoded view.
+func ParseVersion(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "W/")
+	s = strings.Trim(s, `"`)
+	s = strings.TrimSuffix(s, "-nobom")
+	return strings.TrimSuffix(s, "-utf8")
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build linux
+
+package util
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// copyXattrs copies the extended attributes of src, such as SELinux
+// labels and ACLs, to dst.
+func copyXattrs(src, dst string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+	names := make([]byte, size)
+	size, err = syscall.Listxattr(src, names)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		n, err := syscall.Getxattr(src, attr, nil)
+		if err != nil {
+			continue
+		}
+		val := make([]byte, n)
+		if n, err = syscall.Getxattr(src, attr, val); err != nil {
+			continue
+		}
+		if err := syscall.Setxattr(dst, attr, val[:n], 0); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package util
+
+// copyXattrs is only implemented on Linux.
+func copyXattrs(src, dst string) error {
+	return nil
+}
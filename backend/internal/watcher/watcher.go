@@ -6,8 +6,11 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"lightdev/internal/util"
+
 	"github.com/fsnotify/fsnotify"
 )
 
@@ -21,8 +24,11 @@ const (
 
 // Event is the payload sent to clients
 type Event struct {
-	Type    EventType   `json:"type"`
-	Path    string      `json:"path"`
+	Type EventType `json:"type"`
+	Path string    `json:"path"`
+	// Version is the file's version after a file_change, as returned by
+	// /api/stat. It is empty if the file was removed.
+	Version string      `json:"version,omitempty"`
 	Payload interface{} `json:"payload,omitempty"`
 }
 
@@ -33,6 +39,11 @@ type Service struct {
 	clients map[chan Event]bool
 	mu      sync.Mutex
 	done    chan struct{}
+	// dropped counts events not delivered to subscribers that fell behind
+	dropped atomic.Uint64
+	// debounced counts events swallowed because the same path changed
+	// shortly before
+	debounced atomic.Uint64
 }
 
 // New creates a new watcher service
@@ -56,7 +67,7 @@ func New(root string) (*Service, error) {
 func (s *Service) Start() {
 	// Add recursive watches
 	if err := s.addRecursive(s.root); err != nil {
-		log.Printf("[WATCHER] error adding watches: %v", err)
+		log.Printf("[ERROR] watcher: error adding watches: %v", err)
 	}
 
 	go s.loop()
@@ -77,6 +88,25 @@ func (s *Service) Subscribe() chan Event {
 	return ch
 }
 
+// Subscribers returns the number of active subscribers.
+func (s *Service) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Dropped returns the number of events dropped for slow subscribers.
+func (s *Service) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Debounced returns the number of events not broadcast because the same
+// path had an event less than 500ms before. Subscribers that track the
+// tree may have missed the final state of such a path.
+func (s *Service) Debounced() uint64 {
+	return s.debounced.Load()
+}
+
 // Unsubscribe removes a listener
 func (s *Service) Unsubscribe(ch chan Event) {
 	s.mu.Lock()
@@ -125,23 +155,32 @@ func (s *Service) loop() {
 			if strings.Contains(relPath, "/.git/") || strings.Contains(relPath, "/.mlcremote/") {
 				continue
 			}
+			// the rename of an atomic save is reported for the file itself
+			if util.IsAtomicTemp(event.Name) {
+				continue
+			}
 
 			// Debounce
 			if time.Since(lastEvent[relPath]) < 500*time.Millisecond {
 				log.Printf("[WATCHER] Debounced: %s", relPath)
+				s.debounced.Add(1)
 				continue
 			}
 			lastEvent[relPath] = time.Now()
 
 			// Handle new directories (Watcher doesn't auto-watch new dirs on Linux)
 			// But fsnotify usually handles it if we react to Create
+			fi, statErr := os.Stat(event.Name)
 			if event.Op&fsnotify.Create == fsnotify.Create {
-				fi, err := os.Stat(event.Name)
-				if err == nil && fi.IsDir() {
+				if statErr == nil && fi.IsDir() {
 					log.Printf("[WATCHER] Watching new dir: %s", event.Name)
 					s.watcher.Add(event.Name)
 				}
 			}
+			version := ""
+			if statErr == nil && !fi.IsDir() {
+				version = util.FileVersion(fi)
+			}
 
 			// Determine event type
 			// Ideally we want to know if it's a file or dir for the UI
@@ -157,8 +196,9 @@ func (s *Service) loop() {
 
 			// Let's Broadcast
 			s.Broadcast(Event{
-				Type: evtType,
-				Path: relPath,
+				Type:    evtType,
+				Path:    relPath,
+				Version: version,
 			})
 
 			// If a new directory was created, we might want to also signal the *parent* changed?
@@ -190,7 +230,7 @@ func (s *Service) loop() {
 			if !ok {
 				return
 			}
-			log.Printf("[WATCHER] error: %v", err)
+			log.Printf("[ERROR] watcher: %v", err)
 		}
 	}
 }
@@ -204,6 +244,7 @@ func (s *Service) Broadcast(e Event) {
 		case ch <- e:
 		default:
 			// Drop event if client too slow
+			s.dropped.Add(1)
 		}
 	}
 }
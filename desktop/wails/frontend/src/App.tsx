@@ -8,7 +8,7 @@ import {
   InstallBackend, DeployAgent, CheckBackend, ProbeConnection,
   ListProfiles, SaveProfile, DeleteProfile,
   SetMasterPassword, VerifyMasterPassword, HasMasterPassword, RunTask, StopBackend,
-  StartTunnelWithProfile
+  StartTunnelWithProfile, WebviewURL
 } from './wailsjs/go/app/App'
 import { I18nProvider, useI18n } from './utils/i18n'
 // @ts-ignore
@@ -144,12 +144,14 @@ function AppContent() {
 
   const [initialTask, setInitialTask] = useState<TaskDef | undefined>(undefined)
 
-  const handleConnected = (p: Profile, token?: string, task?: TaskDef) => {
+  const handleConnected = async (p: Profile, token?: string, task?: TaskDef) => {
     setCurrentProfile(p)
     setInitialTask(task)
     setProfileName(`${p.user}@${p.host}`)
     setProfileColor(p.color || '')
-    let url = `http://localhost:${p.localPort || 8443}`
+    // TLS agents are served through the app's pinned proxy, since the
+    // webview would reject their self-signed certificate
+    let url = await WebviewURL(p.localPort || 8443)
     if (token) {
       url += `?token=${encodeURIComponent(token)}`
     } else {
@@ -243,7 +245,10 @@ function AppContent() {
         identityFile: p.identityFile,
         extraArgs: [...(p.extraArgs || [])],
         mode: p.mode,
-        rootPath: p.rootPath
+        rootPath: p.rootPath,
+        tls: p.tls,
+        tlsFingerprint: p.tlsFingerprint,
+        socket: p.socket
       }
       if (p.port && p.port !== 22) {
         backendProfile.extraArgs.push('-p', String(p.port))
@@ -255,9 +260,13 @@ function AppContent() {
       // Refresh iframe by forcing update or just notify user
       // Toggle view to force reload if needed, or simply rely on iframe retry?
       // Best to reload the iframe to be sure.
-      const current = remoteUrl
+      // The proxy of a TLS agent is restarted if its certificate changed
+      const current = new URL(remoteUrl)
+      const base = new URL(await WebviewURL(p.localPort || 8443))
+      current.protocol = base.protocol
+      current.host = base.host
       setRemoteUrl('')
-      setTimeout(() => setRemoteUrl(current), 100)
+      setTimeout(() => setRemoteUrl(current.toString()), 100)
 
     } catch (e: any) {
       console.error("Reconnect failed", e)
@@ -167,7 +167,10 @@ export default function LaunchScreen({ onConnected, onLocked, onOpenSettings }:
                 identityFile: p.identityFile,
                 extraArgs: [...(p.extraArgs || [])],
                 mode: p.mode,
-                rootPath: p.rootPath
+                rootPath: p.rootPath,
+                tls: p.tls,
+                tlsFingerprint: p.tlsFingerprint,
+                socket: p.socket
             }
 
             // Handle non-standard SSH port via extra args
@@ -191,13 +194,15 @@ export default function LaunchScreen({ onConnected, onLocked, onOpenSettings }:
 
                 let token = undefined;
                 let actualPort = p.localPort || 8443;
+                let https = false;
 
                 if (res.startsWith('started:')) {
                     const parts = res.split(':');
-                    if (parts.length === 3) {
-                        // started:PORT:TOKEN
+                    if (parts.length >= 3) {
+                        // started:PORT:TOKEN[:https]
                         actualPort = parseInt(parts[1], 10);
                         token = parts[2].trim();
+                        https = parts[3] === 'https';
                     } else {
                         // Legacy started:TOKEN
                         token = res.substring(8).trim();
@@ -233,7 +238,7 @@ export default function LaunchScreen({ onConnected, onLocked, onOpenSettings }:
                     user: p.user, host: p.host, localPort: actualPort, remoteHost: '127.0.0.1', remotePort: 8443,
                     identityFile: p.identityFile, extraArgs: p.extraArgs,
                     remoteOS: p.remoteOS, remoteArch: p.remoteArch, remoteVersion: p.remoteVersion,
-                    id: p.id, color: p.color, tasks: p.tasks
+                    id: p.id, color: p.color, tasks: p.tasks, tls: https, socket: p.socket
                 }, token, task)
             } else {
                 // Check if it's an auth error
@@ -33,6 +33,9 @@ export default function ProfileEditor({ profile, onSave, onCancel, isPremium }:
     const [defaultShell, setDefaultShell] = useState(profile?.defaultShell || '')
     const [rootPath, setRootPath] = useState(profile?.rootPath || '')
     const [showDeveloperControls, setShowDeveloperControls] = useState(profile?.showDeveloperControls || false)
+    const [useTLS, setUseTLS] = useState(profile?.tls || false)
+    const [tlsFingerprint, setTlsFingerprint] = useState(profile?.tlsFingerprint || '')
+    const [useSocket, setUseSocket] = useState(profile?.socket || false)
     const [monitoringEnabled, setMonitoringEnabled] = useState(profile?.monitoring?.enabled || false)
     const [monitoringInterval, setMonitoringInterval] = useState(profile?.monitoring?.interval || 10)
 
@@ -61,6 +64,9 @@ export default function ProfileEditor({ profile, onSave, onCancel, isPremium }:
             setDefaultShell(profile.defaultShell || '')
             setRootPath(profile.rootPath || '')
             setShowDeveloperControls(profile.showDeveloperControls || false)
+            setUseTLS(profile.tls || false)
+            setTlsFingerprint(profile.tlsFingerprint || '')
+            setUseSocket(profile.socket || false)
             setMonitoringEnabled(profile.monitoring?.enabled || false)
             setMonitoringInterval(profile.monitoring?.interval || 10)
             setTasks(profile.tasks || [])
@@ -152,6 +158,9 @@ export default function ProfileEditor({ profile, onSave, onCancel, isPremium }:
             defaultShell: defaultShell,
             rootPath: rootPath,
             showDeveloperControls: showDeveloperControls,
+            tls: useTLS,
+            tlsFingerprint: tlsFingerprint.trim(),
+            socket: useSocket,
             monitoring: {
                 enabled: monitoringEnabled,
                 interval: monitoringInterval < 10 ? 10 : monitoringInterval
@@ -420,6 +429,45 @@ export default function ProfileEditor({ profile, onSave, onCancel, isPremium }:
                             </div>
                         </div>
 
+                        {/* Unix socket */}
+                        <div style={{ marginTop: 16 }}>
+                            <label style={{ display: 'flex', alignItems: 'center', gap: 8, cursor: 'pointer' }}>
+                                <input
+                                    type="checkbox"
+                                    checked={useSocket}
+                                    onChange={e => setUseSocket(e.target.checked)}
+                                />
+                                <span>{t('use_socket') || "Use Unix Socket"}</span>
+                            </label>
+                            <div style={{ fontSize: '0.8rem', color: 'var(--text-muted)', marginTop: 4, marginLeft: 24 }}>
+                                {t('use_socket_desc') || "Run the agent on a private Unix socket instead of a TCP port (Linux/macOS hosts). Takes precedence over TLS."}
+                            </div>
+                        </div>
+
+                        {/* TLS */}
+                        <div style={{ marginTop: 16 }}>
+                            <label style={{ display: 'flex', alignItems: 'center', gap: 8, cursor: 'pointer' }}>
+                                <input
+                                    type="checkbox"
+                                    checked={useTLS}
+                                    onChange={e => setUseTLS(e.target.checked)}
+                                />
+                                <span>{t('use_tls') || "Use TLS (HTTPS)"}</span>
+                            </label>
+                            <div style={{ fontSize: '0.8rem', color: 'var(--text-muted)', marginTop: 4, marginLeft: 24 }}>
+                                {t('use_tls_desc') || "Start the agent with a self-signed certificate. The certificate fingerprint is verified on connect."}
+                            </div>
+                            {useTLS && (
+                                <input
+                                    className="input"
+                                    style={{ marginTop: 8 }}
+                                    value={tlsFingerprint}
+                                    onChange={e => setTlsFingerprint(e.target.value)}
+                                    placeholder={t('tls_fingerprint_placeholder') || "Optional SHA-256 fingerprint to pin"}
+                                />
+                            )}
+                        </div>
+
                         {/* Monitoring */}
                         <div style={{ marginTop: 16, borderTop: '1px solid var(--border)', paddingTop: 16 }}>
                             <h3 style={{ margin: 0, fontSize: '1rem', marginBottom: 12 }}>{t('monitoring') || "Server Monitoring"}</h3>
@@ -140,6 +140,11 @@ export const translations = {
         custom_color: "Custom Color",
         show_developer_controls: "Show Developer Controls",
         show_developer_controls_desc: "Enable screenshot, server logs, and session key controls.",
+        use_socket: "Use Unix Socket",
+        use_socket_desc: "Run the agent on a private Unix socket instead of a TCP port (Linux/macOS hosts). Takes precedence over TLS.",
+        use_tls: "Use TLS (HTTPS)",
+        use_tls_desc: "Start the agent with a self-signed certificate. The certificate fingerprint is verified on connect.",
+        tls_fingerprint_placeholder: "Optional SHA-256 fingerprint to pin",
         // Monitoring
         monitoring: "Server Monitoring",
         enable_monitoring: "Enable Health Monitoring",
@@ -285,6 +290,11 @@ export const translations = {
         reconnect_failed: "Verbindung fehlgeschlagen",
         show_developer_controls: "Entwicklersteuerung anzeigen",
         show_developer_controls_desc: "Screenshot, Server-Logs und Sitzungs-Schlüssel-Steuerung aktivieren.",
+        use_socket: "Unix-Socket verwenden",
+        use_socket_desc: "Startet den Agenten auf einem privaten Unix-Socket statt auf einem TCP-Port (Linux/macOS). Hat Vorrang vor TLS.",
+        use_tls: "TLS verwenden (HTTPS)",
+        use_tls_desc: "Startet den Agenten mit einem selbstsignierten Zertifikat. Der Fingerabdruck wird beim Verbinden geprüft.",
+        tls_fingerprint_placeholder: "Optionaler SHA-256-Fingerabdruck zum Festlegen",
         // Monitoring
         monitoring: "Server-Überwachung",
         enable_monitoring: "Gesundheitsüberwachung aktivieren",
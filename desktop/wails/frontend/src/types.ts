@@ -25,6 +25,12 @@ interface BaseProfile {
     tasks: TaskDef[]
     defaultShell?: string
     rootPath?: string
+    /** If true, the agent serves HTTPS with a self-signed certificate */
+    tls?: boolean
+    /** Optional SHA-256 fingerprint the agent certificate must match */
+    tlsFingerprint?: string
+    /** If true, the agent listens on a Unix socket instead of a TCP port (Linux/macOS hosts) */
+    socket?: boolean
     /** If true, shows developer UI controls (screenshot, server logs, session key) */
     showDeveloperControls?: boolean
 }
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mlechner911/mlcremote/desktop/wails/internal/backend"
@@ -30,6 +32,13 @@ type App struct {
 	SSH        *ssh.Manager
 	Backend    *backend.Manager
 	Monitoring *monitoring.Service
+
+	// tlsPins maps local tunnel ports to the certificate fingerprint of
+	// the TLS-enabled agent behind them.
+	pinMu   sync.Mutex
+	tlsPins map[int]string
+	// proxies serve the TLS agents to the webview, see WebviewURL
+	proxies map[int]*webviewProxy
 }
 
 // SSHDeployRequest contains credentials for SSH operations
@@ -85,6 +94,7 @@ func (a *App) BeforeClose(ctx context.Context) (prevent bool) {
 func (a *App) cleanup() {
 	fmt.Println("Gracefully stopping tunnel...")
 	_, _ = a.SSH.StopTunnel()
+	a.stopProxies()
 	if a.Monitoring != nil {
 		a.Monitoring.Stop()
 	}
@@ -194,8 +204,10 @@ func (a *App) PickIdentityFile() (string, error) {
 }
 
 // HealthCheck checks whether the backend at the given URL responds to /health
+// For https URLs of a tunnel started with TLS, the agent certificate must
+// match the fingerprint pinned when the agent was deployed.
 func (a *App) HealthCheck(url string, token string, timeoutSeconds int) (string, error) {
-	client := http.Client{Timeout: time.Duration(timeoutSeconds) * time.Second}
+	client := a.httpClient(url, time.Duration(timeoutSeconds)*time.Second)
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/health", url), nil)
 	if err != nil {
 		return "not-found", err
@@ -267,7 +279,7 @@ func (a *App) ClipboardCopy(remotePaths []string, token string) error {
 	}
 
 	var localPaths []string
-	client := http.Client{Timeout: 30 * time.Second} // Timeout per file?
+	client := a.httpClient(a.localBaseURL(port), 30*time.Second) // Timeout per file?
 
 	for i, rPath := range remotePaths {
 		// Emit start progress
@@ -279,7 +291,7 @@ func (a *App) ClipboardCopy(remotePaths []string, token string) error {
 		})
 
 		// Download file
-		url := fmt.Sprintf("http://localhost:%d/api/file?path=%s&download=true", port, rPath)
+		url := fmt.Sprintf("%s/api/file?path=%s&download=true", a.localBaseURL(port), rPath)
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
 			return err
@@ -463,9 +475,9 @@ func (a *App) ClipboardPasteTo(remoteDir string, token string) error {
 		return fmt.Errorf("no active connection")
 	}
 
-	// Just use a simple multipart upload for each file
+	// Upload each file with a raw PUT
 	// Optimally we'd do parallel or batch
-	client := http.Client{Timeout: 60 * time.Second}
+	client := a.httpClient(a.localBaseURL(port), 60*time.Second)
 
 	for _, localPath := range files {
 		// Open local file
@@ -486,7 +498,7 @@ func (a *App) ClipboardPasteTo(remoteDir string, token string) error {
 			// Check if exists
 			// We can use HEAD or just GET /api/stat (simpler as we generally use stat)
 			// GET /api/stat?path=...
-			checkUrl := fmt.Sprintf("http://localhost:%d/api/stat?path=%s", port, filepath.Join(remoteDir, finalName))
+			checkUrl := fmt.Sprintf("%s/api/stat?path=%s", a.localBaseURL(port), url.QueryEscape(path.Join(remoteDir, finalName)))
 			req, _ := http.NewRequest("GET", checkUrl, nil)
 			if token != "" {
 				req.Header.Set("X-Auth-Token", token)
@@ -507,29 +519,22 @@ func (a *App) ClipboardPasteTo(remoteDir string, token string) error {
 			counter++
 		}
 
-		// Start multipart upload
-		r, w := io.Pipe()
-		m := multipart.NewWriter(w)
-
-		go func() {
-			defer w.Close()
-			defer m.Close()
-			// Use finalName here so backend saves it with new name
-			part, err := m.CreateFormFile("file", finalName)
-			if err != nil {
-				return
-			}
-			if _, err := io.Copy(part, f); err != nil {
-				return
-			}
-		}()
-
-		url := fmt.Sprintf("http://localhost:%d/api/upload?path=%s", port, remoteDir)
-		req, err := http.NewRequest("POST", url, r)
+		// Stream the raw file; mode=create fails instead of replacing a
+		// file that appeared since the check above
+		q := url.Values{}
+		// remote paths use slashes, also from a Windows client
+		q.Set("path", path.Join(remoteDir, finalName))
+		q.Set("mode", "create")
+		putUrl := fmt.Sprintf("%s/api/file?%s", a.localBaseURL(port), q.Encode())
+		req, err := http.NewRequest("PUT", putUrl, f)
 		if err != nil {
 			return err
 		}
-		req.Header.Set("Content-Type", m.FormDataContentType())
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if fi, err := f.Stat(); err == nil {
+			req.ContentLength = fi.Size()
+			req.Header.Set("X-File-Mtime", fi.ModTime().UTC().Format(time.RFC3339))
+		}
 		if token != "" {
 			req.Header.Set("X-Auth-Token", token)
 		}
@@ -539,7 +544,7 @@ func (a *App) ClipboardPasteTo(remoteDir string, token string) error {
 			return fmt.Errorf("failed to upload %s: %w", localPath, err)
 		}
 		resp.Body.Close()
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
 			return fmt.Errorf("upload failed with status %s", resp.Status)
 		}
 	}
@@ -0,0 +1,97 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NormalizeFingerprint converts a SHA-256 fingerprint to lowercase hex
+// without separators, so "AB:CD:..." (openssl) and "abcd..." (agent log)
+// compare equal.
+func NormalizeFingerprint(fp string) string {
+	fp = strings.ToLower(strings.TrimSpace(fp))
+	fp = strings.TrimPrefix(fp, "sha256:")
+	return strings.ReplaceAll(fp, ":", "")
+}
+
+// pinnedTLSConfig returns a TLS config that accepts exactly the certificate
+// with the given fingerprint. The agent certificate is usually self-signed,
+// so the normal chain verification is replaced by the pin check.
+func pinnedTLSConfig(fingerprint string) *tls.Config {
+	want := NormalizeFingerprint(fingerprint)
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("tls: agent sent no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if got := hex.EncodeToString(sum[:]); got != want {
+				return fmt.Errorf("tls: certificate fingerprint mismatch (got %s, pinned %s)", got, want)
+			}
+			return nil
+		},
+	}
+}
+
+// setTLSPin remembers the certificate fingerprint of the agent reachable
+// on the given local port. An empty fingerprint removes the pin.
+func (a *App) setTLSPin(port int, fingerprint string) {
+	a.pinMu.Lock()
+	defer a.pinMu.Unlock()
+	if a.tlsPins == nil {
+		a.tlsPins = make(map[int]string)
+	}
+	if fingerprint == "" {
+		delete(a.tlsPins, port)
+		if p := a.proxies[port]; p != nil {
+			p.close()
+			delete(a.proxies, port)
+		}
+		return
+	}
+	a.tlsPins[port] = NormalizeFingerprint(fingerprint)
+}
+
+func (a *App) tlsPin(port int) string {
+	a.pinMu.Lock()
+	defer a.pinMu.Unlock()
+	return a.tlsPins[port]
+}
+
+// localBaseURL returns the URL of the agent behind the local tunnel port,
+// using https if the agent was started with TLS.
+func (a *App) localBaseURL(port int) string {
+	if a.tlsPin(port) != "" {
+		return fmt.Sprintf("https://localhost:%d", port)
+	}
+	return fmt.Sprintf("http://localhost:%d", port)
+}
+
+// httpClient returns a client for requests to rawURL. For https URLs on a
+// pinned local port the agent certificate is checked against the pin.
+func (a *App) httpClient(rawURL string, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return client
+	}
+	port, _ := strconv.Atoi(u.Port())
+	if fp := a.tlsPin(port); fp != "" {
+		client.Transport = &http.Transport{TLSClientConfig: pinnedTLSConfig(fp)}
+	}
+	return client
+}
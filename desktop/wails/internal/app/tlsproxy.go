@@ -0,0 +1,101 @@
+// Copyright (c) 2025 MLCRemote authors
+// All rights reserved. Use of this source code is governed by an
+// MIT-style license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// webviewProxy makes a TLS agent reachable for the webview. The webview
+// cannot verify the agent's self-signed certificate, so it talks plain
+// HTTP to a loopback listener that forwards to the agent through a
+// transport checking the pinned fingerprint.
+type webviewProxy struct {
+	fingerprint string
+	url         string
+	srv         *http.Server
+}
+
+// secureAttr matches the Secure attribute of a Set-Cookie header.
+var secureAttr = regexp.MustCompile(`(?i);\s*Secure(;|$)`)
+
+// WebviewURL returns the base URL under which the webview reaches the agent
+// behind the local tunnel port. For TLS agents this is a loopback proxy
+// that checks the pinned certificate; it is started on first use.
+func (a *App) WebviewURL(port int) (string, error) {
+	a.pinMu.Lock()
+	defer a.pinMu.Unlock()
+	fp := a.tlsPins[port]
+	if fp == "" {
+		return fmt.Sprintf("http://localhost:%d", port), nil
+	}
+	if p := a.proxies[port]; p != nil {
+		if p.fingerprint == fp {
+			return p.url, nil
+		}
+		p.close()
+		delete(a.proxies, port)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to start TLS proxy: %w", err)
+	}
+	target := &url.URL{Scheme: "https", Host: fmt.Sprintf("localhost:%d", port)}
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.Transport = &http.Transport{TLSClientConfig: pinnedTLSConfig(fp)}
+	// stream search results and events as they arrive
+	rp.FlushInterval = -1
+	rp.ModifyResponse = func(resp *http.Response) error {
+		// the webview sees http://127.0.0.1, where it would drop Secure
+		// cookies; the hop to the agent is still TLS
+		cookies := resp.Header.Values("Set-Cookie")
+		resp.Header.Del("Set-Cookie")
+		for _, c := range cookies {
+			resp.Header.Add("Set-Cookie", secureAttr.ReplaceAllString(c, "$1"))
+		}
+		return nil
+	}
+	p := &webviewProxy{
+		fingerprint: fp,
+		url:         "http://" + ln.Addr().String(),
+		srv:         &http.Server{Handler: rp, ReadHeaderTimeout: 10 * time.Second},
+	}
+	go func() {
+		if err := p.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("TLS proxy for port %d stopped: %v\n", port, err)
+		}
+	}()
+	if a.proxies == nil {
+		a.proxies = make(map[int]*webviewProxy)
+	}
+	a.proxies[port] = p
+	return p.url, nil
+}
+
+// stopProxies closes all webview proxies.
+func (a *App) stopProxies() {
+	a.pinMu.Lock()
+	defer a.pinMu.Unlock()
+	for port, p := range a.proxies {
+		p.close()
+		delete(a.proxies, port)
+	}
+}
+
+func (p *webviewProxy) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.srv.Shutdown(ctx); err != nil {
+		_ = p.srv.Close()
+	}
+}
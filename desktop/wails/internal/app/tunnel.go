@@ -106,21 +106,46 @@ func (a *App) StartTunnelWithProfile(profileJSON string) (string, error) {
 	}
 
 	remotePort := 8443
-	if strings.HasPrefix(deployRes, "deployed:") {
+	remoteSocket := ""
+	fingerprint := ""
+	if strings.HasPrefix(deployRes, "deployed-socket:") {
+		// deployed-socket:TOKEN:SOCKETPATH
+		parts := strings.SplitN(strings.TrimPrefix(deployRes, "deployed-socket:"), ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return "failed", fmt.Errorf("invalid deploy result %q", deployRes)
+		}
+		token = parts[0]
+		remoteSocket = parts[1]
+	} else if strings.HasPrefix(deployRes, "deployed:") {
 		remainder := strings.TrimPrefix(deployRes, "deployed:")
 		if strings.Contains(remainder, ":") {
-			// deployed:PORT:TOKEN
-			parts := strings.SplitN(remainder, ":", 2)
+			// deployed:PORT:TOKEN[:FINGERPRINT]
+			parts := strings.SplitN(remainder, ":", 3)
 			if p, err := strconv.Atoi(parts[0]); err == nil && p > 0 {
 				remotePort = p
 			}
 			token = parts[1]
+			if len(parts) == 3 {
+				fingerprint = parts[2]
+			}
 		} else {
 			// Legacy/Standard: deployed:TOKEN
 			token = remainder
 		}
 	}
 
+	// The fingerprint was read from the agent log over SSH, so it can be
+	// trusted. A fingerprint stored in the profile acts as an additional pin.
+	useTLS := cp.TLS && remoteSocket == ""
+	if useTLS {
+		if fingerprint == "" {
+			return "failed", fmt.Errorf("agent did not report a TLS certificate fingerprint")
+		}
+		if cp.TLSFingerprint != "" && NormalizeFingerprint(cp.TLSFingerprint) != NormalizeFingerprint(fingerprint) {
+			return "tls-mismatch", fmt.Errorf("agent certificate fingerprint %s does not match the pinned %s", fingerprint, cp.TLSFingerprint)
+		}
+	}
+
 	// 6. Start Tunnel via SSH Service
 	runtime.EventsEmit(a.ctx, "connection-status", "starting_tunnel")
 	// Check if LocalPort is available, if not find a free one
@@ -149,6 +174,7 @@ func (a *App) StartTunnelWithProfile(profileJSON string) (string, error) {
 		LocalPort:    targetPort,
 		RemoteHost:   "127.0.0.1", // Agent binds to 127.0.0.1 explicitly now
 		RemotePort:   remotePort,
+		RemoteSocket: remoteSocket,
 		IdentityFile: cp.IdentityFile,
 		ExtraArgs:    cp.ExtraArgs,
 		Mode:         cp.Mode,
@@ -161,6 +187,17 @@ func (a *App) StartTunnelWithProfile(profileJSON string) (string, error) {
 	if err != nil {
 		return res, err
 	}
+	if useTLS {
+		a.setTLSPin(targetPort, fingerprint)
+	} else {
+		a.setTLSPin(targetPort, "")
+	}
+
+	// started:PORT:TOKEN, with ":https" appended for TLS agents
+	started := fmt.Sprintf("started:%d:%s", targetPort, token)
+	if useTLS {
+		started += ":https"
+	}
 
 	// 7. Wait for Healthy (optional, but good UX)
 	runtime.EventsEmit(a.ctx, "connection-status", "verifying_connection")
@@ -168,18 +205,19 @@ func (a *App) StartTunnelWithProfile(profileJSON string) (string, error) {
 	time.Sleep(500 * time.Millisecond) // Wait for tunnel to establish
 	// Check backend health
 	for i := 0; i < 20; i++ {
-		status, _ := a.HealthCheck(fmt.Sprintf("http://localhost:%d", targetPort), token, 1)
+		status, _ := a.HealthCheck(a.localBaseURL(targetPort), token, 1)
 		if status == "ok" {
-			return fmt.Sprintf("started:%d:%s", targetPort, token), nil
+			return started, nil
 		}
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	return fmt.Sprintf("started:%d:%s", targetPort, token), nil // Return started anyway, frontend verifies connectivity
+	return started, nil // Return started anyway, frontend verifies connectivity
 }
 
 // StopTunnel stops the running ssh tunnel process and waits for it to exit
 func (a *App) StopTunnel() (string, error) {
+	a.stopProxies()
 	return a.SSH.StopTunnel()
 }
 
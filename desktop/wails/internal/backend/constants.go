@@ -28,6 +28,7 @@ const (
 	LogFileStartupErr = "startup_err.log"
 	PidFile           = "pid"
 	TokenFile         = "token"
+	SocketFile        = "socket" // written by agents in socket mode, holds the socket path
 	InstallMetaFile   = "install.json"
 
 	// AgentVersion is the current version of the remote agent
@@ -101,6 +101,62 @@ func getRemoteSystem(osType remotesystem.RemoteOS) remotesystem.Remote {
 	return &remotesystem.Linux{}
 }
 
+// tlsFingerprintMarker prefixes the certificate fingerprint in the agent log.
+const tlsFingerprintMarker = "TLS fingerprint (SHA-256): "
+
+// ParseTLSFingerprint returns the last certificate fingerprint reported in
+// an agent log, or "" if there is none.
+func ParseTLSFingerprint(logOutput string) string {
+	fp := ""
+	for _, line := range strings.Split(logOutput, "\n") {
+		if idx := strings.Index(line, tlsFingerprintMarker); idx != -1 {
+			fp = strings.TrimSpace(line[idx+len(tlsFingerprintMarker):])
+		}
+	}
+	return fp
+}
+
+// parseListenPort returns the last TCP port reported in an agent log, or 0
+// if there is none.
+func parseListenPort(logOutput string) int {
+	port := 0
+	for _, line := range strings.Split(logOutput, "\n") {
+		if !strings.Contains(line, "Server started on http") {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		pStr := strings.TrimSpace(parts[len(parts)-1])
+		if endIdx := strings.IndexAny(pStr, " ,"); endIdx != -1 {
+			pStr = pStr[:endIdx]
+		}
+		if p, err := strconv.Atoi(pStr); err == nil {
+			port = p
+		}
+	}
+	return port
+}
+
+// socketStartedMarker prefixes the socket path in the agent log.
+const socketStartedMarker = "Server started on unix://"
+
+// parseSocketPath returns the socket path reported in an agent log.
+func parseSocketPath(logOutput string) string {
+	path := ""
+	for _, line := range strings.Split(logOutput, "\n") {
+		if idx := strings.Index(line, socketStartedMarker); idx != -1 {
+			path = line[idx+len(socketStartedMarker):]
+			if end := strings.Index(path, ","); end != -1 {
+				path = path[:end]
+			}
+			path = strings.TrimSpace(path)
+		}
+	}
+	return path
+}
+
 // DeployAgent ensures the correct binary and assets are on the remote host.
 func (m *Manager) DeployAgent(profileJSON string, targetOS remotesystem.RemoteOS, targetArch remotesystem.RemoteArch, token string, forceNew bool) (string, error) {
 	target, sshBaseArgs, err := m.prepareSSHArgs(profileJSON)
@@ -115,9 +171,20 @@ func (m *Manager) DeployAgent(profileJSON string, targetOS remotesystem.RemoteOS
 		return strings.TrimSpace(string(out)), err
 	}
 
+	var p ssh.TunnelProfile
+	json.Unmarshal([]byte(profileJSON), &p)
+
 	remoteSys := getRemoteSystem(targetOS)
 	home := remoteSys.GetHomeDir()
 
+	// Socket mode needs Unix sockets on the remote and replaces TLS, since
+	// the socket is only reachable through the SSH forward anyway.
+	useSocket := p.Socket && targetOS != remotesystem.OSWindows
+	useTLS := p.TLS && !useSocket
+	if p.Socket && !useSocket {
+		fmt.Println("Socket mode is not supported on Windows hosts, using a TCP port.")
+	}
+
 	remoteBinDir := remoteSys.JoinPath(RemoteBinDir)
 	remoteFrontendDir := remoteSys.JoinPath(RemoteFrontendDir)
 	binName := remoteSys.GetBinaryName(RemoteBinaryName)
@@ -142,7 +209,7 @@ func (m *Manager) DeployAgent(profileJSON string, targetOS remotesystem.RemoteOS
 
 	// 2. Check Existing Session
 	if !forceNew {
-		if conn, ok := m.checkExistingSession(runRemote, remoteSys); ok {
+		if conn, ok := m.checkExistingSession(runRemote, remoteSys, p.RemotePort, useTLS, useSocket); ok {
 			fmt.Println("Found existing valid session.")
 			return conn, nil
 		}
@@ -161,18 +228,20 @@ func (m *Manager) DeployAgent(profileJSON string, targetOS remotesystem.RemoteOS
 	}
 
 	// 4. Start Backend & Verify
-	var p ssh.TunnelProfile
-	json.Unmarshal([]byte(profileJSON), &p)
-
-	res, err := m.startBackend(runRemote, remoteSys, home, remoteBinDir, binName, token, p.RootPath, forceNew)
-	if err != nil && !forceNew {
+	res, err := m.startBackend(runRemote, remoteSys, home, remoteBinDir, binName, token, p.RootPath, useTLS, useSocket, forceNew)
+	if err != nil && !forceNew && !useSocket {
 		fmt.Printf("Startup on default port failed (%v). Retrying with random port...\n", err)
-		return m.startBackend(runRemote, remoteSys, home, remoteBinDir, binName, token, p.RootPath, true)
+		return m.startBackend(runRemote, remoteSys, home, remoteBinDir, binName, token, p.RootPath, useTLS, false, true)
 	}
 	return res, err
 }
 
-func (m *Manager) checkExistingSession(runRemote func(string) (string, error), remoteSys remotesystem.Remote) (string, bool) {
+// checkExistingSession returns the connection string of a running agent.
+// The port is taken from the agent log, falling back to remotePort. If
+// useTLS is set, the certificate fingerprint is read from the agent log
+// and the session is only reused if the agent serves TLS. If useSocket is
+// set, only an agent in socket mode is reused.
+func (m *Manager) checkExistingSession(runRemote func(string) (string, error), remoteSys remotesystem.Remote, remotePort int, useTLS, useSocket bool) (string, bool) {
 	pidFile := remoteSys.JoinPath(RemoteBaseDir, PidFile)
 	if out, err := runRemote(remoteSys.ReadFile(pidFile)); err == nil {
 		pidStr := strings.TrimSpace(out)
@@ -190,7 +259,30 @@ func (m *Manager) checkExistingSession(runRemote func(string) (string, error), r
 					if fields := strings.Fields(cleanToken); len(fields) > 0 {
 						cleanToken = fields[0]
 					}
-					return fmt.Sprintf("deployed:8443:%s", cleanToken), true
+					if useSocket {
+						sockOut, err := runRemote(remoteSys.ReadFile(remoteSys.JoinPath(RemoteBaseDir, SocketFile)))
+						sockPath := strings.TrimSpace(sockOut)
+						if err != nil || sockPath == "" {
+							return "", false
+						}
+						return fmt.Sprintf("deployed-socket:%s:%s", cleanToken, sockPath), true
+					}
+					logOut, _ := runRemote(remoteSys.ReadFile(remoteSys.JoinPath(RemoteBaseDir, LogFileCurrent)))
+					port := parseListenPort(logOut)
+					if port == 0 {
+						port = remotePort
+					}
+					if port == 0 {
+						port = 8443
+					}
+					if useTLS {
+						fp := ParseTLSFingerprint(logOut)
+						if fp == "" {
+							return "", false
+						}
+						return fmt.Sprintf("deployed:%d:%s:%s", port, cleanToken, fp), true
+					}
+					return fmt.Sprintf("deployed:%d:%s", port, cleanToken), true
 				}
 			} else {
 				fmt.Println("checkExistingSession: Stale PID, cleaning up.")
@@ -367,7 +459,10 @@ func (m *Manager) uploadAssets(runRemote func(string) (string, error), remoteSys
 	return nil
 }
 
-func (m *Manager) startBackend(runRemote func(string) (string, error), remoteSys remotesystem.Remote, home, remoteBinDir, binName, token, rootPath string, forceNew bool) (string, error) {
+// startBackend launches the agent and waits for it to report readiness.
+// The result is "deployed:PORT:TOKEN", with ":FINGERPRINT" appended when
+// useTLS is set, or "deployed-socket:TOKEN:SOCKETPATH" when useSocket is set.
+func (m *Manager) startBackend(runRemote func(string) (string, error), remoteSys remotesystem.Remote, home, remoteBinDir, binName, token, rootPath string, useTLS, useSocket, forceNew bool) (string, error) {
 	if token == "" {
 		return "failed", fmt.Errorf("backend token is required")
 	}
@@ -380,6 +475,12 @@ func (m *Manager) startBackend(runRemote func(string) (string, error), remoteSys
 		portArg = "-port=0"
 	}
 	hostArg := "-host=127.0.0.1"
+	if useSocket {
+		portArg = "-socket"
+	}
+	if useTLS {
+		hostArg += " -tls"
+	}
 
 	// Append root arg if specified
 	rootArg := ""
@@ -436,25 +537,26 @@ func (m *Manager) startBackend(runRemote func(string) (string, error), remoteSys
 					if strings.Contains(out, "Server started") {
 						fmt.Printf("Backend started successfully! PID: %s (verified via log).\n", pidStr)
 
-						if targetPort == 0 {
-							lines := strings.Split(out, "\n")
-							for _, line := range lines {
-								if strings.Contains(line, "Server started on http://") {
-									parts := strings.Split(line, ":")
-									if len(parts) >= 3 {
-										pStr := strings.TrimSpace(parts[len(parts)-1])
-										endIdx := strings.IndexAny(pStr, " ,")
-										if endIdx != -1 {
-											pStr = pStr[:endIdx]
-										}
-										if p, err := strconv.Atoi(pStr); err == nil {
-											targetPort = p
-										}
-									}
-								}
+						if useSocket {
+							sockPath := parseSocketPath(out)
+							if sockPath == "" {
+								return "startup-failed", fmt.Errorf("backend started without reporting its socket (agent too old for socket mode?)")
 							}
+							fmt.Printf("Backend listening on socket: %s\n", sockPath)
+							return fmt.Sprintf("deployed-socket:%s:%s", token, sockPath), nil
+						}
+
+						if targetPort == 0 {
+							targetPort = parseListenPort(out)
 						}
 						fmt.Printf("Backend listening on port: %d\n", targetPort)
+						if useTLS {
+							fp := ParseTLSFingerprint(out)
+							if fp == "" {
+								return "startup-failed", fmt.Errorf("backend started without TLS fingerprint (agent too old for TLS?)")
+							}
+							return fmt.Sprintf("deployed:%d:%s:%s", targetPort, token, fp), nil
+						}
 						return fmt.Sprintf("deployed:%d:%s", targetPort, token), nil
 					}
 				}
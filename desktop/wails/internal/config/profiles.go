@@ -32,6 +32,9 @@ type ConnectionProfile struct {
 	ShowDeveloperControls bool              `json:"showDeveloperControls"` // Show developer UI (screenshot, logs, session copy)
 	Tasks                 []TaskDef         `json:"tasks"`
 	Monitoring            *MonitoringConfig `json:"monitoring,omitempty"`
+	TLS                   bool              `json:"tls"`                      // Run the agent with HTTPS
+	TLSFingerprint        string            `json:"tlsFingerprint,omitempty"` // Expected SHA-256 certificate fingerprint (optional pin)
+	Socket                bool              `json:"socket"`                   // Run the agent on a Unix socket (Linux/macOS hosts)
 }
 
 type MonitoringConfig struct {
@@ -29,6 +29,9 @@ type TunnelProfile struct {
 	Mode         string   `json:"mode"`         // "default" or "parallel"
 	DefaultShell string   `json:"defaultShell"` // e.g. "bash" or "powershell"
 	RootPath     string   `json:"rootPath"`     // Optional root directory override
+	TLS          bool     `json:"tls"`          // Start the agent with -tls
+	Socket       bool     `json:"socket"`       // Start the agent with -socket (Unix hosts only)
+	RemoteSocket string   `json:"remoteSocket"` // Forward to this Unix socket instead of RemoteHost:RemotePort
 }
 
 type Manager struct {
@@ -98,8 +101,13 @@ func (m *Manager) StartTunnel(ctx context.Context, profile TunnelProfile) (strin
 
 	// Construct args
 	// ssh -L 8443:localhost:8443 -N user@host -i identityFile
+	forward := fmt.Sprintf("%d:%s:%d", profile.LocalPort, profile.RemoteHost, profile.RemotePort)
+	if profile.RemoteSocket != "" {
+		// ssh -L 8443:/home/user/.mlcremote/agent.sock
+		forward = fmt.Sprintf("%d:%s", profile.LocalPort, profile.RemoteSocket)
+	}
 	args := []string{
-		"-L", fmt.Sprintf("%d:%s:%d", profile.LocalPort, profile.RemoteHost, profile.RemotePort),
+		"-L", forward,
 		"-N", // Do not execute a remote command
 		// "-v", // verbose
 	}
@@ -1,5 +1,5 @@
 import Axios, { AxiosRequestConfig } from 'axios';
-import { getToken, getApiBaseUrl, makeUrl } from '.';
+import { getToken, getApiBaseUrl, getCsrfToken, makeUrl } from '.';
 
 // Define a custom Axios instance
 export const AXIOS_INSTANCE = Axios.create({ baseURL: getApiBaseUrl() || '' });
@@ -13,6 +13,11 @@ AXIOS_INSTANCE.interceptors.request.use((config) => {
     if (token) {
         config.headers['X-Auth-Token'] = token;
     }
+    const method = (config.method || 'get').toUpperCase();
+    const csrf = getCsrfToken();
+    if (csrf && method !== 'GET' && method !== 'HEAD' && method !== 'OPTIONS') {
+        config.headers['X-CSRF-Token'] = csrf;
+    }
     return config;
 });
 
@@ -28,6 +28,7 @@ export interface HealthInfo {
 
 export interface LoginRequest {
   password?: string;
+  returnToken?: boolean;
 }
 
 export interface LoginResponse {
@@ -2,26 +2,26 @@
 import { info, warn } from '../utils/logger'
 import {
     getToken, setToken, authedFetch, makeUrl,
-    setApiBaseUrl, getApiBaseUrl
+    setApiBaseUrl, getApiBaseUrl, getCsrfToken, isFramed
 } from '../utils/auth'
 
-export { setApiBaseUrl, getApiBaseUrl, makeUrl, getToken }
+export { setApiBaseUrl, getApiBaseUrl, makeUrl, getToken, getCsrfToken }
 
-export async function login(password: string): Promise<string> {
+// login starts a session. The session token lives in an HttpOnly cookie
+// set by the server. Only cross-origin and framed pages, which never see
+// that cookie, get the token in the response and store it.
+export async function login(password: string): Promise<void> {
     info('POST /api/login')
     const r = await fetch(makeUrl('/api/login'), {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ password })
+        credentials: 'include',
+        body: JSON.stringify({ password, returnToken: isFramed() })
     })
     info(`/api/login => ${r.status}`)
     if (!r.ok) throw new Error('login failed')
-    const j = await r.json()
-    if (j && j.token) {
-        setToken(j.token)
-        return j.token
-    }
-    throw new Error('no token in response')
+    const j = await r.json().catch(() => ({}))
+    if (j && j.token) setToken(j.token)
 }
 
 // re-export authedFetch so other modules can call it from api
@@ -4,6 +4,7 @@ import React, { createContext, useContext, useEffect, useState, useCallback } fr
 // generated.ts uses HealthInfo. 
 import { useGetHealth, useGetApiAuthCheck, usePostApiLogin } from '../api/generated'
 import { HealthInfo } from '../api/generated.schemas'
+import { authedFetch, isFramed } from '../utils/auth'
 
 /**
  * Defines the shape of the authentication context.
@@ -138,9 +139,11 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
         const needsPassword = !!health.password_auth
         const needsTokenOnly = !!health.auth_required && !health.password_auth
         const token = localStorage.getItem('mlcremote_token')
+        // a password login is only visible as a successful auth check
+        const hasSession = authCheckQuery.isSuccess
 
-        // If we need auth, but have no token, show chooser
-        if ((needsPassword || needsTokenOnly) && !token) {
+        // If we need auth, but have no token or session, show chooser
+        if ((needsPassword || needsTokenOnly) && !token && !hasSession) {
             // But wait, if we just showed login/token input?
             setShowAuthChooser(true)
         } else {
@@ -153,7 +156,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
             // In App.tsx or AuthOverlay, logic usually handles priority.
             setShowAuthChooser(false)
         }
-    }, [health]) // missing dependencies: showLogin? No, just health logic.
+    }, [health, authCheckQuery.isSuccess]) // missing dependencies: showLogin? No, just health logic.
 
     const refreshHealth = useCallback(async () => {
         await Promise.all([healthQuery.refetch(), authCheckQuery.refetch()])
@@ -161,9 +164,12 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
 
     const login = async (password: string) => {
         try {
-            const res = await loginMutation.mutateAsync({ data: { password } })
-            if (res.status === 200 && res.data.token) {
-                localStorage.setItem('mlcremote_token', res.data.token)
+            // the session token arrives in an HttpOnly cookie; only pages
+            // that never see the cookie (cross-origin or framed) get it in
+            // the response and keep it in localStorage
+            const res = await loginMutation.mutateAsync({ data: { password, returnToken: isFramed() } })
+            if (res.status === 200) {
+                if (res.data.token) localStorage.setItem('mlcremote_token', res.data.token)
                 await refreshHealth()
                 setShowLogin(false)
                 setShowAuthChooser(false)
@@ -181,7 +187,9 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
         setShowAuthChooser(false)
     }
 
-    const logout = () => {
+    const logout = async () => {
+        // revoke the session and clear its cookies
+        try { await authedFetch('/api/logout', { method: 'POST', credentials: 'include' }) } catch (_) { }
         localStorage.removeItem('mlcremote_token')
         refreshHealth()
     }
@@ -9,6 +9,29 @@ export function setToken(t: string) {
   try { localStorage.setItem('mlcremote_token', t) } catch (_) { }
 }
 
+// getCsrfToken returns the CSRF token the server set at login. It must be
+// sent on state-changing requests that rely on the session cookie.
+export function getCsrfToken(): string | null {
+  try {
+    const m = document.cookie.match(/(?:^|;\s*)mlcremote_csrf=([^;]*)/)
+    return m ? decodeURIComponent(m[1]) : null
+  } catch (_) { return null }
+}
+
+// isFramed reports whether the page runs inside a frame of another page,
+// like the desktop app's webview. The SameSite=Strict session cookie is not
+// sent there, so the page has to keep the session token itself.
+export function isFramed(): boolean {
+  try {
+    return window.self !== window.top
+  } catch (_) { return true }
+}
+
+function isSafeMethod(method?: string) {
+  const m = (method || 'GET').toUpperCase()
+  return m === 'GET' || m === 'HEAD' || m === 'OPTIONS'
+}
+
 let apiBaseUrl = ''
 
 export function setApiBaseUrl(url: string) {
@@ -42,6 +65,8 @@ export async function authedFetch(input: RequestInfo, init?: RequestInit): Promi
   const token = getToken()
   const headers = new Headers(init?.headers as HeadersInit)
   if (token) headers.set('X-Auth-Token', token)
+  const csrf = getCsrfToken()
+  if (csrf && !isSafeMethod(init?.method)) headers.set('X-CSRF-Token', csrf)
   const merged: RequestInit = { ...(init || {}), headers }
 
   // Apply base URL if input is a string path